@@ -0,0 +1,257 @@
+package dcache
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/joaovictorsl/dcache/core"
+	"github.com/joaovictorsl/dcache/core/command"
+)
+
+// Command categories users are given permission to.
+const (
+	// GET and HAS
+	CATEGORY_READ = "read"
+	// SET and DELETE
+	CATEGORY_WRITE = "write"
+	// Commands about the server, such as CLIENT LIST, ACL LIST, SAVE, SYNC and STATS
+	CATEGORY_ADMIN = "admin"
+)
+
+// Prefix of passwords stored as the hex encoded SHA-256 of the secret
+const sha256Prefix = "sha256:"
+
+// Format of the ACL file, e.g. in YAML:
+//
+//	users:
+//	  - name: orders
+//	    passwords: ["sha256:5e884898da28..."]
+//	    categories: [read, write]
+//	    keys: ["orders:*", "carts:*"]
+type aclFile struct {
+	Users []aclFileUser `yaml:"users" json:"users" toml:"users"`
+}
+
+type aclFileUser struct {
+	Name string `yaml:"name" json:"name" toml:"name"`
+	// Plain text secrets or their SHA-256 prefixed by "sha256:"
+	Passwords  []string `yaml:"passwords" json:"passwords" toml:"passwords"`
+	Categories []string `yaml:"categories" json:"categories" toml:"categories"`
+	// Exact keys, or key prefixes when ending in "*". "*" alone allows every key.
+	Keys []string `yaml:"keys" json:"keys" toml:"keys"`
+}
+
+type aclUser struct {
+	name       string
+	passwords  []string
+	categories map[string]bool
+	keys       []string
+}
+
+// Allowed to run every command on every key, authenticated by AuthPassword and AuthTokens.
+var defaultUser = &aclUser{
+	name:       DEFAULT_USER,
+	categories: map[string]bool{CATEGORY_READ: true, CATEGORY_WRITE: true, CATEGORY_ADMIN: true},
+	keys:       []string{"*"},
+}
+
+// Reads the users defined in the ACL file at path, an empty path defines no users.
+func loadACL(path string) (map[string]*aclUser, error) {
+	users := make(map[string]*aclUser)
+	if path == "" {
+		return users, nil
+	}
+
+	var f aclFile
+	if err := decodeFile(path, &f); err != nil {
+		return nil, fmt.Errorf("acl: %s", err)
+	}
+
+	for _, fu := range f.Users {
+		u, err := newACLUser(fu)
+		if err != nil {
+			return nil, fmt.Errorf("acl: %s: %s", path, err)
+		}
+
+		if _, ok := users[u.name]; ok {
+			return nil, fmt.Errorf("acl: %s: user %q defined twice", path, u.name)
+		}
+		users[u.name] = u
+	}
+
+	return users, nil
+}
+
+func newACLUser(fu aclFileUser) (*aclUser, error) {
+	if fu.Name == "" {
+		return nil, fmt.Errorf("user without name")
+	} else if fu.Name == DEFAULT_USER {
+		return nil, fmt.Errorf("user %q is defined by auth_password and auth_tokens", DEFAULT_USER)
+	} else if len(fu.Name) > 255 {
+		return nil, fmt.Errorf("user name %q is longer than 255 bytes", fu.Name)
+	} else if len(fu.Passwords) == 0 {
+		return nil, fmt.Errorf("user %q has no passwords", fu.Name)
+	}
+
+	u := &aclUser{
+		name:       fu.Name,
+		passwords:  fu.Passwords,
+		categories: make(map[string]bool, len(fu.Categories)),
+		keys:       fu.Keys,
+	}
+
+	for _, password := range fu.Passwords {
+		if hash, ok := strings.CutPrefix(password, sha256Prefix); ok {
+			if b, err := hex.DecodeString(hash); err != nil || len(b) != sha256.Size {
+				return nil, fmt.Errorf("user %q has an invalid sha256 password", fu.Name)
+			}
+		}
+	}
+
+	for _, category := range fu.Categories {
+		switch category = strings.ToLower(category); category {
+		case CATEGORY_READ, CATEGORY_WRITE, CATEGORY_ADMIN:
+			u.categories[category] = true
+		default:
+			return nil, fmt.Errorf("user %q has unknown category %q", fu.Name, category)
+		}
+	}
+
+	for _, pattern := range fu.Keys {
+		if i := strings.Index(pattern, "*"); i != -1 && i != len(pattern)-1 {
+			return nil, fmt.Errorf("user %q has key pattern %q with \"*\" before its end", fu.Name, pattern)
+		}
+	}
+
+	return u, nil
+}
+
+// Checks secret against every password of the user.
+func (u *aclUser) validSecret(secret string) bool {
+	valid := 0
+	for _, password := range u.passwords {
+		if hash, ok := strings.CutPrefix(password, sha256Prefix); ok {
+			sum := sha256.Sum256([]byte(secret))
+			valid |= subtle.ConstantTimeCompare([]byte(hash), []byte(hex.EncodeToString(sum[:])))
+		} else {
+			valid |= subtle.ConstantTimeCompare([]byte(password), []byte(secret))
+		}
+	}
+
+	return valid == 1
+}
+
+func (u *aclUser) allowsKey(key string) bool {
+	for _, pattern := range u.keys {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok && strings.HasPrefix(key, prefix) {
+			return true
+		} else if pattern == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Checks if the user may access every key starting with prefix.
+func (u *aclUser) allowsPrefix(prefix string) bool {
+	for _, pattern := range u.keys {
+		if p, ok := strings.CutSuffix(pattern, "*"); ok && strings.HasPrefix(prefix, p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Checks if the user may run cmd, commands without a category are allowed to every user.
+func (u *aclUser) allows(cmd command.Command) bool {
+	category := commandCategory(cmd)
+	if category != "" && !u.categories[category] {
+		return false
+	}
+
+	if key, ok := commandKey(cmd); ok {
+		return u.allowsKey(key)
+	}
+
+	return true
+}
+
+func commandCategory(cmd command.Command) string {
+	switch cmd.(type) {
+	case *command.GetCommand, *command.HasCommand, *command.DumpCommand, *command.TrackingCommand, *command.ClientTrackingCommand:
+		return CATEGORY_READ
+	case *command.SetCommand, *command.AddCommand, *command.DeleteCommand:
+		return CATEGORY_WRITE
+	case *command.ClientListCommand, *command.ACLListCommand, *command.SaveCommand, *command.SyncCommand, *command.StatsCommand,
+		*command.ScanCommand, *command.GossipCommand:
+		return CATEGORY_ADMIN
+	}
+
+	return ""
+}
+
+// Describes the user as listed by ACL LIST, e.g.
+//
+//	user=orders categories=read,write keys=orders:*,carts:*
+func (u *aclUser) String() string {
+	categories := make([]string, 0, len(u.categories))
+	for _, category := range []string{CATEGORY_READ, CATEGORY_WRITE, CATEGORY_ADMIN} {
+		if u.categories[category] {
+			categories = append(categories, category)
+		}
+	}
+
+	return fmt.Sprintf("user=%s categories=%s keys=%s", u.name, strings.Join(categories, ","), strings.Join(u.keys, ","))
+}
+
+// Returns the user the connection runs commands as, nil if it must authenticate first.
+//
+// Users are looked up on every command so permission changes apply to open connections, connections
+// of users removed from the ACL file must authenticate again.
+func (s *Server) connUser(cc *clientConn) *aclUser {
+	s.cfgMu.RLock()
+	defer s.cfgMu.RUnlock()
+
+	switch {
+	case cc.username == "" && !s.cfg.authRequired():
+		return defaultUser
+	case cc.username == DEFAULT_USER:
+		return defaultUser
+	case cc.username != "":
+		return s.aclUsers[cc.username]
+	}
+
+	return nil
+}
+
+// Answers ACL WHOAMI with the name of the connection's user.
+func (s *Server) aclWhoami(user *aclUser) []byte {
+	return append([]byte{core.CMD_EXEC_SUCCEEDED}, user.name...)
+}
+
+// Answers ACL LIST with a line per user, starting with the default user.
+func (s *Server) aclList() []byte {
+	s.cfgMu.RLock()
+	users := make([]*aclUser, 0, len(s.aclUsers))
+	for _, u := range s.aclUsers {
+		users = append(users, u)
+	}
+	s.cfgMu.RUnlock()
+
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].name < users[j].name
+	})
+
+	lines := []string{defaultUser.String()}
+	for _, u := range users {
+		lines = append(lines, u.String())
+	}
+
+	return append([]byte{core.CMD_EXEC_SUCCEEDED}, strings.Join(lines, "\n")...)
+}
@@ -0,0 +1,112 @@
+package dcache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/joaovictorsl/dcache/core"
+	"github.com/joaovictorsl/dcache/core/command"
+)
+
+const testACL = `
+users:
+  - name: orders
+    passwords: [orders-secret]
+    categories: [read, write]
+    keys: ["orders:*", "config"]
+  - name: reports
+    # sha256 of "reports-secret"
+    passwords: ["sha256:cba98f988f2e420084bcd5700ca8ebdc8c2a17d47b9ede26e35dd9e0fb25b0c5"]
+    categories: [read]
+    keys: ["*"]
+`
+
+func TestLoadACL(t *testing.T) {
+	users, err := loadACL(writeConfigFile(t, "acl.yaml", testACL))
+	if err != nil {
+		t.Fatalf("loadACL returned error %q", err)
+	}
+
+	orders := users["orders"]
+	if orders == nil || !orders.validSecret("orders-secret") || orders.validSecret("reports-secret") {
+		t.Fatalf("expected orders user authenticated by its password, got %+v", orders)
+	}
+
+	for key, allowed := range map[string]bool{"orders:1": true, "orders:": true, "config": true, "config:1": false, "carts:1": false} {
+		if orders.allowsKey(key) != allowed {
+			t.Errorf("orders.allowsKey(%q) = %v, want %v", key, !allowed, allowed)
+		}
+	}
+
+	if users["reports"].String() != "user=reports categories=read keys=*" {
+		t.Errorf("unexpected reports user %q", users["reports"])
+	}
+
+	invalid := map[string]string{
+		"default.yaml":  "users: [{name: default, passwords: [x]}]",
+		"nopass.yaml":   "users: [{name: a, categories: [read]}]",
+		"category.yaml": "users: [{name: a, passwords: [x], categories: [flush]}]",
+		"pattern.yaml":  "users: [{name: a, passwords: [x], keys: ['*:orders']}]",
+		"hash.yaml":     "users: [{name: a, passwords: ['sha256:abc']}]",
+		"twice.yaml":    "users: [{name: a, passwords: [x]}, {name: a, passwords: [y]}]",
+	}
+
+	for name, content := range invalid {
+		if _, err := loadACL(writeConfigFile(t, name, content)); err == nil {
+			t.Errorf("loadACL(%s) should return error", name)
+		}
+	}
+}
+
+func TestServerACL(t *testing.T) {
+	path := writeConfigFile(t, "acl.yaml", testACL)
+	s, _ := startTestServer(t, WithACLFile(path), WithAuthPassword("admin-secret"))
+	defer s.Shutdown(context.Background())
+
+	conn := dialTestServer(t, s)
+	defer conn.Close()
+
+	expectStatus := func(cmd []byte, status byte) []byte {
+		t.Helper()
+		res, err := conn.exec(cmd)
+		if err != nil || res[0] != status {
+			t.Fatalf("%v = %q, %v, want status %d", cmd, res, err, status)
+		}
+		return res[1:]
+	}
+
+	expectStatus(command.ACLWhoamiCmdAsBytes(), core.AUTH_REQUIRED_CODE)
+	expectStatus(command.AuthCmdAsBytes("orders", "admin-secret"), core.CMD_EXEC_FAILED)
+	expectStatus(command.AuthCmdAsBytes("orders", "orders-secret"), core.CMD_EXEC_SUCCEEDED)
+
+	if user := expectStatus(command.ACLWhoamiCmdAsBytes(), core.CMD_EXEC_SUCCEEDED); string(user) != "orders" {
+		t.Errorf("ACL WHOAMI = %q, want orders", user)
+	}
+
+	expectStatus(command.SetCmdAsBytes("orders:1", []byte("Bar"), 10000), core.CMD_EXEC_SUCCEEDED)
+	expectStatus(command.GetCmdAsBytes("orders:1"), core.CMD_EXEC_SUCCEEDED)
+	expectStatus(command.GetCmdAsBytes("carts:1"), core.NO_PERMISSION_CODE)
+	expectStatus(command.ClientListCmdAsBytes(), core.NO_PERMISSION_CODE)
+	expectStatus(command.ACLListCmdAsBytes(), core.NO_PERMISSION_CODE)
+
+	expectStatus(command.AuthCmdAsBytes("reports", "reports-secret"), core.CMD_EXEC_SUCCEEDED)
+	expectStatus(command.GetCmdAsBytes("orders:1"), core.CMD_EXEC_SUCCEEDED)
+	expectStatus(command.DeleteCmdAsBytes("orders:1"), core.NO_PERMISSION_CODE)
+
+	// Permission changes apply to open connections
+	reloaded := writeConfigFile(t, "acl.yaml", "users: [{name: reports, passwords: [reports-secret], categories: [read, write], keys: ['*']}]")
+	cfg := s.config()
+	cfg.ACLFile = reloaded
+	if err := s.Reload(cfg); err != nil {
+		t.Fatalf("Reload returned error %q", err)
+	}
+	expectStatus(command.DeleteCmdAsBytes("orders:1"), core.CMD_EXEC_SUCCEEDED)
+
+	expectStatus(command.AuthCmdAsBytes("", "admin-secret"), core.CMD_EXEC_SUCCEEDED)
+	list := expectStatus(command.ACLListCmdAsBytes(), core.CMD_EXEC_SUCCEEDED)
+	expected := "user=default categories=read,write,admin keys=*\n" +
+		"user=reports categories=read,write keys=*"
+	if string(list) != expected {
+		t.Errorf("ACL LIST = %q, want %q", list, expected)
+	}
+}
@@ -0,0 +1,160 @@
+package dcache
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/joaovictorsl/dcache/core/command"
+	"github.com/joaovictorsl/dcache/core/persist"
+	"github.com/joaovictorsl/dcache/core/protocol"
+)
+
+// Where the log is moved while being compacted, it's removed once the snapshot is written.
+func aofOldPath(path string) string {
+	return path + ".old"
+}
+
+// Replays the append-only log on top of the loaded snapshot and opens it for appending, returning nil
+// when there's no log. The caller keeps it in s.aof or closes it.
+//
+// A log left behind by a compaction interrupted by a crash is replayed first.
+func (s *Server) openAOF(cfg Config) (*persist.AOF, error) {
+	if cfg.AOFFile == "" {
+		return nil, nil
+	}
+
+	for _, path := range []string{aofOldPath(cfg.AOFFile), cfg.AOFFile} {
+		if err := s.replayAOF(cfg, path); err != nil {
+			return nil, err
+		}
+	}
+
+	aof, err := persist.OpenAOF(cfg.AOFFile, cfg.AOFFsync)
+	if err != nil {
+		return nil, fmt.Errorf("aof: %s", err)
+	}
+
+	return aof, nil
+}
+
+func (s *Server) replayAOF(cfg Config, path string) error {
+	start := time.Now()
+	maxSize := protocol.MaxCommandSize(cfg.MaxKeyLength, cfg.MaxValueLength)
+	n, truncated, err := persist.ReplayAOF(path, maxSize, func(t time.Time, rawCmd []byte) error {
+		cmd, err := protocol.ParseCommand(rawCmd)
+		if err != nil {
+			return fmt.Errorf("invalid command: %s", err)
+		}
+
+		sinceRan(cmd, t, start, cfg.CleanInterval != 0).Execute(s.cache)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("aof: failed to replay %s: %s", path, err)
+	}
+
+	if truncated {
+		s.errorf("aof: %s ended with an incomplete or corrupted command, it was removed\n", path)
+	}
+	if n != 0 {
+		s.infof("replayed %d commands from %s in %s\n", n, path, time.Since(start).Round(time.Millisecond))
+	}
+	return nil
+}
+
+// Returns cmd as if it ran at t instead of now, SETs live for what's left of their TTL and become a DELETE
+// once it's over. TTLs are ignored when the cache doesn't expire keys.
+func sinceRan(cmd command.Command, t, now time.Time, expires bool) command.Command {
+	c, ok := cmd.(*command.SetCommand)
+	if !ok || !expires {
+		return cmd
+	}
+
+	ttl := c.TTL - now.Sub(t)
+	if ttl <= 0 {
+		return command.NewDeleteCommand(c.Key)
+	}
+
+	return &command.SetCommand{Key: c.Key, Value: c.Value, TTL: ttl}
+}
+
+// Moves the log aside before a snapshot is written, returning a function that removes it once the snapshot
+// is on disk. Commands running from then on go to a new log.
+//
+// If a previous compaction failed the old log is kept as is, replaying the new log over a snapshot that
+// already has some of its commands leaves the cache in the same state.
+func (s *Server) rotateAOF() (done func(), err error) {
+	if s.aof == nil {
+		return func() {}, nil
+	}
+
+	oldPath := aofOldPath(s.config().AOFFile)
+	if _, err := os.Stat(oldPath); errors.Is(err, os.ErrNotExist) {
+		if err := s.aof.Rotate(oldPath); err != nil {
+			return nil, fmt.Errorf("aof: %s", err)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("aof: %s", err)
+	}
+
+	return func() {
+		if err := os.Remove(oldPath); err != nil {
+			s.errorf("aof: failed to remove %s: %s\n", oldPath, err)
+		}
+	}, nil
+}
+
+// Syncs the log every second when the policy is persist.FSYNC_EVERYSEC and compacts it once it
+// reaches AOFCompactSize, until the server is shut down.
+func (s *Server) aofLoop() {
+	defer s.background.Done()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if s.aof.Policy() == persist.FSYNC_EVERYSEC {
+				if err := s.aof.Sync(); err != nil {
+					s.errorf("aof: sync failed: %s\n", err)
+				}
+			}
+
+			limit := s.config().AOFCompactSize
+			if limit != 0 && uint64(s.aof.Size()) >= limit && s.compacting.CompareAndSwap(false, true) {
+				s.background.Add(1)
+				go s.compactAOF()
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Compacts the log into a snapshot.
+func (s *Server) compactAOF() {
+	defer s.background.Done()
+	defer s.compacting.Store(false)
+
+	s.infof("compacting aof of %d bytes\n", s.aof.Size())
+	if _, err := s.save(); err != nil {
+		s.errorf("aof: compaction failed: %s\n", err)
+	}
+}
+
+func (s *Server) closeAOF() {
+	s.mu.Lock()
+	aof := s.aof
+	s.mu.Unlock()
+
+	if aof == nil {
+		return
+	}
+
+	if err := aof.Close(); err != nil {
+		s.errorf("aof: failed to close: %s\n", err)
+	}
+}
@@ -0,0 +1,107 @@
+package dcache
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/joaovictorsl/dcache/core"
+	"github.com/joaovictorsl/dcache/core/command"
+	"github.com/joaovictorsl/dcache/core/persist"
+)
+
+func TestServerAOF(t *testing.T) {
+	dir := t.TempDir()
+	snapshotPath, aofPath := filepath.Join(dir, "dcache.snap"), filepath.Join(dir, "dcache.aof")
+	opts := []Option{WithSnapshotFile(snapshotPath, 0), WithAOFFile(aofPath, persist.FSYNC_ALWAYS)}
+
+	s, _ := startTestServer(t, opts...)
+	defer s.Shutdown(context.Background())
+	conn := dialTestServer(t, s)
+	defer conn.Close()
+
+	shortExpires := time.Now().Add(50 * time.Millisecond)
+	for _, cmd := range [][]byte{
+		command.SetCmdAsBytes("Foo", []byte("Bar"), 60000),
+		command.SetCmdAsBytes("Short", []byte("lived"), 50),
+		command.SetCmdAsBytes("Deleted", []byte("value"), 60000),
+		command.DeleteCmdAsBytes("Deleted"),
+		command.AddCmdAsBytes("Added", []byte("value"), 60000),
+	} {
+		if res, err := conn.exec(cmd); err != nil || res[0] != core.CMD_EXEC_SUCCEEDED {
+			t.Fatalf("%v = %q, %v, want success", cmd, res, err)
+		}
+	}
+
+	// Stored keys are left as they are, and the failed ADD is not logged
+	if res, err := conn.exec(command.AddCmdAsBytes("Foo", []byte("Baz"), 60000)); err != nil || res[0] != core.CMD_EXEC_FAILED {
+		t.Fatalf("ADD Foo = %q, %v, want failure", res, err)
+	}
+
+	// Short expires before the log is replayed
+	time.Sleep(time.Until(shortExpires))
+
+	// Starts from the log as left by a crash, nothing was saved to the snapshot
+	if _, err := os.Stat(snapshotPath); err == nil {
+		t.Fatal("expected no snapshot before compaction")
+	}
+
+	restarted, _ := startTestServer(t, append(opts, WithAOFCompactSize(1))...)
+	defer restarted.Shutdown(context.Background())
+	restartedConn := dialTestServer(t, restarted)
+	defer restartedConn.Close()
+
+	expected := map[string]string{"Foo": "Bar", "Short": "", "Deleted": "", "Added": "value"}
+	for key, value := range expected {
+		res, err := restartedConn.exec(command.GetCmdAsBytes(key))
+		if err != nil {
+			t.Fatalf("GET %s returned error %q", key, err)
+		}
+
+		if value == "" && res[0] != core.CMD_EXEC_FAILED {
+			t.Errorf("expected %s not to be replayed, got %q", key, res)
+		} else if value != "" && string(res[1:]) != value {
+			t.Errorf("GET %s = %q, want %q", key, res, value)
+		}
+	}
+
+	// Compacted into the snapshot once over 1 byte
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		info, err := os.Stat(aofPath)
+		if err == nil && info.Size() == 0 {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatalf("expected aof to be compacted, got %v, %v", info, err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if _, err := os.Stat(snapshotPath); err != nil {
+		t.Errorf("expected snapshot to be written on compaction, got %q", err)
+	}
+	if _, err := os.Stat(aofOldPath(aofPath)); err == nil {
+		t.Error("expected old aof to be removed after compaction")
+	}
+}
+
+func TestServerAOFListenError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to take the port: %s", err)
+	}
+	defer ln.Close()
+
+	// The log isn't opened when the port is taken
+	aofPath := filepath.Join(t.TempDir(), "dcache.aof")
+	s := NewServerWithOptions(WithBindAddr("127.0.0.1"), WithPort(uint16(ln.Addr().(*net.TCPAddr).Port)), WithAOFFile(aofPath, persist.FSYNC_ALWAYS), WithLogLevel(LOG_NONE))
+	if err := s.Start(); err == nil {
+		t.Fatalf("expected Start to fail listening")
+	}
+	if _, err := os.Stat(aofPath); !os.IsNotExist(err) {
+		t.Errorf("expected the aof not to be opened, got %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package dcache
+
+import (
+	"crypto/subtle"
+	"fmt"
+
+	"github.com/joaovictorsl/dcache/core"
+	"github.com/joaovictorsl/dcache/core/command"
+)
+
+// Name of the user authenticated by AuthPassword and AuthTokens, an empty username in AUTH means this user.
+// It may run every command on every key.
+const DEFAULT_USER = "default"
+
+const errInvalidCredentials = "invalid username or secret"
+
+func (c Config) authRequired() bool {
+	return c.AuthPassword != "" || len(c.AuthTokens) != 0 || c.ACLFile != ""
+}
+
+// Checks secret against the password and every token, taking the same time whichever matches.
+func (c Config) validSecret(secret string) bool {
+	valid := 0
+	if c.AuthPassword != "" {
+		valid |= subtle.ConstantTimeCompare([]byte(c.AuthPassword), []byte(secret))
+	}
+
+	for _, token := range c.AuthTokens {
+		valid |= subtle.ConstantTimeCompare([]byte(token), []byte(secret))
+	}
+
+	return valid == 1
+}
+
+// Answers AUTH. When authentication is not required it always succeeds, so clients can be given
+// credentials before servers start requiring them.
+func (s *Server) auth(cc *clientConn, cmd *command.AuthCommand) []byte {
+	name := cmd.Username
+	if name == "" {
+		name = DEFAULT_USER
+	}
+
+	s.cfgMu.RLock()
+	required := s.cfg.authRequired()
+	valid := false
+	if name == DEFAULT_USER {
+		valid = s.cfg.validSecret(cmd.Secret)
+	} else if u, ok := s.aclUsers[name]; ok {
+		valid = u.validSecret(cmd.Secret)
+	}
+	s.cfgMu.RUnlock()
+
+	if required && !valid {
+		s.infof("conn %d from %s failed to authenticate as %q\n", cc.id, cc.RemoteAddr(), name)
+		return append([]byte{core.CMD_EXEC_FAILED}, errInvalidCredentials...)
+	}
+
+	if !required {
+		// Any credentials are accepted, so they don't grant other user's permissions
+		name = DEFAULT_USER
+	}
+
+	cc.username = name
+	return []byte{core.CMD_EXEC_SUCCEEDED}
+}
+
+// Answers HELLO with the protocol version and the connection's authentication state, e.g.
+//
+//	proto=1 auth_required=true authenticated=false
+func (s *Server) hello(cc *clientConn) []byte {
+	info := fmt.Sprintf("proto=1 auth_required=%t authenticated=%t", s.config().authRequired(), cc.username != "")
+	return append([]byte{core.CMD_EXEC_SUCCEEDED}, info...)
+}
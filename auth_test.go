@@ -0,0 +1,77 @@
+package dcache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/joaovictorsl/dcache/client"
+	"github.com/joaovictorsl/dcache/core"
+	"github.com/joaovictorsl/dcache/core/command"
+)
+
+func TestServerAuth(t *testing.T) {
+	s, _ := startTestServer(t, WithAuthPassword("s3cret"), WithAuthTokens("svc-token"))
+	defer s.Shutdown(context.Background())
+
+	conn := dialTestServer(t, s)
+	defer conn.Close()
+
+	res, err := conn.exec(command.GetCmdAsBytes("Foo"))
+	if err != nil || res[0] != core.AUTH_REQUIRED_CODE || string(res[1:]) != core.AUTH_REQUIRED {
+		t.Fatalf("GET before AUTH = %q, %v, want auth required", res, err)
+	}
+
+	res, err = conn.exec(command.HelloCmdAsBytes())
+	if err != nil || string(res[1:]) != "proto=1 auth_required=true authenticated=false" {
+		t.Errorf("HELLO = %q, %v, want auth required and not authenticated", res, err)
+	}
+
+	for _, cmd := range [][]byte{command.AuthCmdAsBytes("", "wrong"), command.AuthCmdAsBytes("admin", "s3cret")} {
+		res, err = conn.exec(cmd)
+		if err != nil || res[0] != core.CMD_EXEC_FAILED {
+			t.Errorf("AUTH with invalid credentials = %q, %v, want failure", res, err)
+		}
+	}
+
+	res, err = conn.exec(command.AuthCmdAsBytes(DEFAULT_USER, "svc-token"))
+	if err != nil || res[0] != core.CMD_EXEC_SUCCEEDED {
+		t.Fatalf("AUTH with token = %q, %v, want success", res, err)
+	}
+
+	res, err = conn.exec(command.GetCmdAsBytes("Foo"))
+	if err != nil || res[0] != core.CMD_EXEC_FAILED {
+		t.Errorf("GET after AUTH = %q, %v, want key not found", res, err)
+	}
+
+	t.Run("client authenticates on connect", func(t *testing.T) {
+		c := client.NewWithOptions(client.WithNodes(testAddr(s)), client.WithAuth("", "s3cret"))
+		defer c.End()
+		if err := c.Connect(0, 0); err != nil {
+			t.Fatalf("Connect returned error %q", err)
+		}
+
+		if err := c.Set("Foo", []byte("Bar"), 10000); err != nil {
+			t.Errorf("Set returned error %q", err)
+		}
+	})
+
+	t.Run("client fails to connect with invalid credentials", func(t *testing.T) {
+		c := client.NewWithOptions(client.WithNodes(testAddr(s)), client.WithAuth("", "wrong"))
+		defer c.End()
+		if err := c.Connect(3, 0); err == nil || err.Code() != client.AUTH_FAILED {
+			t.Errorf("Connect = %v, want authentication failure", err)
+		}
+	})
+
+	t.Run("client without credentials gets auth required", func(t *testing.T) {
+		c := client.New(testAddr(s))
+		defer c.End()
+		if err := c.Connect(0, 0); err != nil {
+			t.Fatalf("Connect returned error %q", err)
+		}
+
+		if _, _, err := c.Get("Foo"); err == nil || err.Code() != client.AUTH_REQUIRED {
+			t.Errorf("Get = %v, want auth required", err)
+		}
+	})
+}
@@ -0,0 +1,229 @@
+package client
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/joaovictorsl/dcache/core/command"
+)
+
+// Appended to a key to name the key GetOrLoad locks it with and the one remembering it wasn't found. Suffixes
+// keep them within the ACL key patterns matching the key.
+const (
+	lockSuffix = "\x00lock"
+	missSuffix = "\x00miss"
+)
+
+// Longest key GetOrLoad accepts, so suffixed keys stay within the 255 bytes a key can have
+const maxLoadKeyLength = 255 - len(lockSuffix)
+
+// Interval in which GetOrLoad checks whether a key locked by another process was loaded
+const loadLockPoll = 10 * time.Millisecond
+
+// Loads the value of key from where it's kept, e.g. a database, returning false if it's not there.
+type Loader func(ctx context.Context, key string) ([]byte, bool, error)
+
+// Loads of keys in flight, so concurrent GetOrLoad calls for a key wait for a single load.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flight
+}
+
+type flight struct {
+	// Context of the load, cancelled once every caller waiting for it is gone
+	ctx    context.Context
+	cancel context.CancelFunc
+	// Callers waiting for the load, guarded by the group's mu
+	waiters int
+	// Closed once the load finishes
+	done  chan struct{}
+	value []byte
+	found bool
+	err   error
+}
+
+// Returns the load of key in flight, true if there was none and the caller must make it and call finish.
+// The load keeps the values of ctx but not its cancellation, callers that stop waiting must call leave.
+func (g *flightGroup) join(ctx context.Context, key string) (*flight, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if f, ok := g.calls[key]; ok {
+		f.waiters++
+		return f, false
+	}
+
+	f := &flight{waiters: 1, done: make(chan struct{})}
+	f.ctx, f.cancel = context.WithCancel(detachedContext{ctx})
+	g.calls[key] = f
+	return f, true
+}
+
+// Stops waiting for f, cancelling it if no one else waits. Later calls start a new load.
+func (g *flightGroup) leave(key string, f *flight) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if f.waiters--; f.waiters == 0 {
+		f.cancel()
+		if g.calls[key] == f {
+			delete(g.calls, key)
+		}
+	}
+}
+
+func (g *flightGroup) finish(key string, f *flight) {
+	g.mu.Lock()
+	if g.calls[key] == f {
+		delete(g.calls, key)
+	}
+	g.mu.Unlock()
+
+	f.cancel()
+	close(f.done)
+}
+
+// A context with the values of the one it wraps, but never cancelled.
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+// Returns the value of key, loading it with loader and storing it for ttl milliseconds when it's not stored.
+// Concurrent calls for a key wait for the load of the first one, getting its result, and so do processes with
+// WithLoadLock. With WithNegativeCaching keys loader didn't find are not loaded again for a while.
+//
+// A call returns when ctx is done, but the load goes on while other calls wait for it; its context has the
+// values of the first call's and is cancelled once no call waits.
+//
+// Errors of loader are returned as a *DCacheError with code LOAD_FAILED, which unwraps to them, and are not
+// cached. Failing to store the loaded value is only logged. Keys longer than 250 bytes are rejected with code
+// INVALID_CMD, leaving room for the suffixes of lock and missing keys.
+func (c *DCacheClient) GetOrLoad(ctx context.Context, key string, ttl uint32, loader Loader) ([]byte, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	} else if len(key) > maxLoadKeyLength {
+		return nil, false, dCacheKeyTooLongError(key, maxLoadKeyLength)
+	}
+
+	value, found, missing, err := c.lookup(key)
+	if err != nil {
+		return nil, false, err
+	} else if found || missing {
+		return value, found, nil
+	}
+
+	f, first := c.flights.join(ctx, key)
+	if first {
+		go func() {
+			defer c.flights.finish(key, f)
+			f.value, f.found, f.err = c.load(f.ctx, key, ttl, loader)
+		}()
+	}
+
+	select {
+	case <-f.done:
+		return f.value, f.found, f.err
+	case <-ctx.Done():
+		c.flights.leave(key, f)
+		return nil, false, ctx.Err()
+	}
+}
+
+// Reads key, and with negative caching whether it's known to be missing. Replicas failing is ignored once
+// a quorum answers.
+func (c *DCacheClient) lookup(key string) (value []byte, found, missing bool, err *DCacheError) {
+	value, found, err = c.Get(key)
+	if !quorumReached(err) {
+		return nil, false, false, err
+	} else if found || c.opts.negativeTTL == 0 {
+		return value, found, false, nil
+	}
+
+	missing, err = c.Has(key + missSuffix)
+	if !quorumReached(err) {
+		return nil, false, false, err
+	}
+
+	return nil, false, missing, nil
+}
+
+func (c *DCacheClient) load(ctx context.Context, key string, ttl uint32, loader Loader) ([]byte, bool, error) {
+	if c.opts.loadLockTTL != 0 {
+		locked, err := c.lockLoad(ctx, key)
+		if err != nil {
+			return nil, false, err
+		} else if locked {
+			defer c.unlockLoad(key)
+		}
+
+		// The process holding the lock may have loaded the key by now
+		if value, found, missing, err := c.lookup(key); err == nil && (found || missing) {
+			return value, found, nil
+		}
+	}
+
+	value, found, err := loader(ctx, key)
+	if err != nil {
+		return nil, false, dCacheLoadFailedError(key, err)
+	}
+
+	if found {
+		if err := c.Set(key, value, ttl); err != nil {
+			log.Printf("failed to store loaded key %s: %s\n", key, err)
+		}
+	} else if c.opts.negativeTTL != 0 {
+		if err := c.Set(key+missSuffix, nil, uint32(c.opts.negativeTTL.Milliseconds())); err != nil {
+			log.Printf("failed to store missing key %s: %s\n", key, err)
+		}
+	}
+
+	return value, found, nil
+}
+
+// Takes the lock of key, retrying until it's released, the key is loaded by the process holding it or the
+// lock's TTL passes, in case its holder stopped. Returns false if the lock wasn't taken, the key is loaded
+// without it if locking fails.
+func (c *DCacheClient) lockLoad(ctx context.Context, key string) (bool, error) {
+	lockTTL := uint32(c.opts.loadLockTTL.Milliseconds())
+	deadline := time.Now().Add(c.opts.loadLockTTL)
+	for {
+		locked, err := c.Add(key+lockSuffix, nil, lockTTL)
+		if !quorumReached(err) {
+			log.Printf("failed to lock key %s for loading: %s\n", key, err)
+			return false, nil
+		} else if locked || time.Now().After(deadline) {
+			return locked, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-time.After(loadLockPoll):
+		}
+
+		if _, found, missing, err := c.lookup(key); err == nil && (found || missing) {
+			return false, nil
+		}
+	}
+}
+
+// Releases the lock of key. Locks are deleted without leaving a tombstone, so they can be taken again right
+// away; a replica that missed the delete keeps the lock until its TTL passes.
+func (c *DCacheClient) unlockLoad(key string) {
+	lock := key + lockSuffix
+	c.execReplicated(command.DeleteCmdAsBytes(lock), lock, true)
+}
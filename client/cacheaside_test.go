@@ -0,0 +1,260 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Returns a loader counting its calls that finds keys with value, or none if value is nil.
+func countingLoader(calls *atomic.Int32, value []byte) Loader {
+	return func(ctx context.Context, key string) ([]byte, bool, error) {
+		calls.Add(1)
+		return value, value != nil, nil
+	}
+}
+
+// Connects a client with opts, removing keys left by previous runs since test servers don't expire keys.
+func newLoadTestClient(t *testing.T, keys []string, opts ...Option) *DCacheClient {
+	c := NewWithOptions(append([]Option{WithNodes(s1Addr, s2Addr)}, opts...)...)
+	if err := c.Connect(2, time.Second); err != nil {
+		t.Fatalf("no error was expected on connect, but got: %s", err)
+	}
+
+	for _, key := range keys {
+		c.Delete(key)
+		c.Delete(key + missSuffix)
+	}
+	return c
+}
+
+// Waits until n GetOrLoad calls of c wait for the load of key.
+func waitLoadWaiters(t *testing.T, c *DCacheClient, key string, n int) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		c.flights.mu.Lock()
+		waiters := 0
+		if f, ok := c.flights.calls[key]; ok {
+			waiters = f.waiters
+		}
+		c.flights.mu.Unlock()
+
+		if waiters == n {
+			return
+		} else if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %d calls to wait for the load of %s, got %d", n, key, waiters)
+		}
+	}
+}
+
+func TestAdd(t *testing.T) {
+	c := newLoadTestClient(t, []string{"add"})
+	defer c.End()
+
+	if added, err := c.Add("add", []byte("first"), 60000); !added || err != nil {
+		t.Errorf("expected the key to be added, got %t, %v", added, err)
+	}
+	if added, err := c.Add("add", []byte("second"), 60000); added || err != nil {
+		t.Errorf("expected the key not to be added again, got %t, %v", added, err)
+	}
+	if value, _, _ := c.Get("add"); string(value) != "first" {
+		t.Errorf("expected %q, got %q", "first", value)
+	}
+}
+
+func TestGetOrLoad(t *testing.T) {
+	c := newLoadTestClient(t, []string{"load", "load-missing"})
+	defer c.End()
+
+	ctx := context.Background()
+	var calls atomic.Int32
+	loader := countingLoader(&calls, []byte("loaded"))
+	for i := 0; i < 2; i++ {
+		value, found, err := c.GetOrLoad(ctx, "load", 60000, loader)
+		if err != nil || !found || string(value) != "loaded" {
+			t.Errorf("expected %q, got %q, %t, %v", "loaded", value, found, err)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected the key to be loaded once, it was loaded %d times", calls.Load())
+	}
+	if value, _, _ := c.Get("load"); string(value) != "loaded" {
+		t.Errorf("expected the loaded value to be stored, got %q", value)
+	}
+
+	// Missing keys are loaded every time without negative caching
+	var missCalls atomic.Int32
+	for i := 0; i < 2; i++ {
+		if _, found, err := c.GetOrLoad(ctx, "load-missing", 60000, countingLoader(&missCalls, nil)); found || err != nil {
+			t.Errorf("expected the key not to be found, got %t, %v", found, err)
+		}
+	}
+	if missCalls.Load() != 2 {
+		t.Errorf("expected the key to be loaded twice, it was loaded %d times", missCalls.Load())
+	}
+
+	loadErr := errors.New("database is down")
+	_, _, err := c.GetOrLoad(ctx, "load-failed", 60000, func(context.Context, string) ([]byte, bool, error) {
+		return nil, false, loadErr
+	})
+	var dcerr *DCacheError
+	if !errors.As(err, &dcerr) || dcerr.Code() != LOAD_FAILED || !errors.Is(err, loadErr) {
+		t.Errorf("expected a LOAD_FAILED error wrapping %v, got %v", loadErr, err)
+	}
+	if ok, _ := c.Has("load-failed"); ok {
+		t.Errorf("expected the failed load not to be stored")
+	}
+	// Keys too long to be suffixed are rejected without loading them
+	var longCalls atomic.Int32
+	_, _, err = c.GetOrLoad(ctx, strings.Repeat("k", maxLoadKeyLength+1), 60000, countingLoader(&longCalls, []byte("loaded")))
+	if !errors.As(err, &dcerr) || dcerr.Code() != INVALID_CMD || longCalls.Load() != 0 {
+		t.Errorf("expected an INVALID_CMD error without loading, got %v and %d loads", err, longCalls.Load())
+	}
+}
+
+func TestGetOrLoadSingleflight(t *testing.T) {
+	c := newLoadTestClient(t, []string{"load-concurrent"})
+	defer c.End()
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	loader := func(ctx context.Context, key string) ([]byte, bool, error) {
+		calls.Add(1)
+		<-release
+		return []byte("loaded"), true, nil
+	}
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, found, err := c.GetOrLoad(context.Background(), "load-concurrent", 60000, loader)
+			if err != nil || !found || string(value) != "loaded" {
+				t.Errorf("expected %q, got %q, %t, %v", "loaded", value, found, err)
+			}
+		}()
+	}
+
+	waitLoadWaiters(t, c, "load-concurrent", 20)
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("expected concurrent loads to be coalesced, the key was loaded %d times", calls.Load())
+	}
+}
+
+func TestGetOrLoadCancelled(t *testing.T) {
+	c := newLoadTestClient(t, []string{"load-cancelled"})
+	defer c.End()
+
+	release := make(chan struct{})
+	loader := func(ctx context.Context, key string) ([]byte, bool, error) {
+		select {
+		case <-release:
+			return []byte("loaded"), true, nil
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		}
+	}
+
+	first, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, _, err := c.GetOrLoad(first, "load-cancelled", 60000, loader)
+		firstErr <- err
+	}()
+
+	type result struct {
+		value []byte
+		err   error
+	}
+	waiter := make(chan result, 1)
+	go func() {
+		value, _, err := c.GetOrLoad(context.Background(), "load-cancelled", 60000, loader)
+		waiter <- result{value, err}
+	}()
+
+	// Both calls wait for the same load
+	waitLoadWaiters(t, c, "load-cancelled", 2)
+
+	cancel()
+	if err := <-firstErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the cancelled call to return %v, got %v", context.Canceled, err)
+	}
+
+	close(release)
+	if r := <-waiter; r.err != nil || string(r.value) != "loaded" {
+		t.Errorf("expected the waiting call to get %q after the first one was cancelled, got %q, %v", "loaded", r.value, r.err)
+	}
+}
+
+func TestGetOrLoadNegativeCaching(t *testing.T) {
+	c := newLoadTestClient(t, []string{"load-negative"}, WithNegativeCaching(time.Minute))
+	defer c.End()
+
+	var calls atomic.Int32
+	for i := 0; i < 2; i++ {
+		if _, found, err := c.GetOrLoad(context.Background(), "load-negative", 60000, countingLoader(&calls, nil)); found || err != nil {
+			t.Errorf("expected the key not to be found, got %t, %v", found, err)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected the missing key to be loaded once, it was loaded %d times", calls.Load())
+	}
+	if ok, _ := c.Has("load-negative"); ok {
+		t.Errorf("expected the missing key not to be stored")
+	}
+}
+
+func TestGetOrLoadLock(t *testing.T) {
+	// Separate clients stand for separate processes
+	a := newLoadTestClient(t, []string{"load-locked"}, WithLoadLock(5*time.Second))
+	defer a.End()
+	b := newLoadTestClient(t, nil, WithLoadLock(5*time.Second))
+	defer b.End()
+
+	started, release, aDone := make(chan struct{}), make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(aDone)
+		a.GetOrLoad(context.Background(), "load-locked", 60000, func(context.Context, string) ([]byte, bool, error) {
+			close(started)
+			<-release
+			return []byte("from a"), true, nil
+		})
+	}()
+	<-started
+
+	var calls atomic.Int32
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		value, found, err := b.GetOrLoad(context.Background(), "load-locked", 60000, countingLoader(&calls, []byte("from b")))
+		if err != nil || !found || string(value) != "from a" {
+			t.Errorf("expected %q, got %q, %t, %v", "from a", value, found, err)
+		}
+	}()
+
+	waitLoadWaiters(t, b, "load-locked", 1)
+	close(release)
+	<-done
+	<-aDone
+
+	if calls.Load() != 0 {
+		t.Errorf("expected the key to be loaded by the lock holder only")
+	}
+	if ok, _ := a.Has("load-locked" + lockSuffix); ok {
+		t.Errorf("expected the lock to be released")
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := b.GetOrLoad(canceled, "load-canceled", 60000, countingLoader(&calls, []byte("x"))); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+}
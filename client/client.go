@@ -1,7 +1,9 @@
 package client
 
 import (
+	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/joaovictorsl/dcache/client/ring"
@@ -11,32 +13,95 @@ import (
 
 // Client used to communicate to DCache nodes.
 type DCacheClient struct {
-	dcring *ring.ConsistentHash
+	dcring ring.Ring
+	opts   *options
+	// The ring when it bounds loads, nil otherwise
+	bounded *ring.ConsistentHash
 
 	mu    *sync.RWMutex
 	conns map[string]*dCacheConn
 	done  bool
+
+	// Rings before the membership changes made while migrating, newest first
+	prevRings []ring.Ring
+	// Connections to nodes removed while migrating, kept to read from them until the migration finishes
+	draining map[string]*dCacheConn
+	// Closed by End to stop refreshing the ring and discovering nodes, nil until Connect starts them
+	stopRefresh chan struct{}
+	// Whether a refresh started by a MOVED response is running
+	refreshing atomic.Bool
+	// Loads made by GetOrLoad that haven't finished
+	flights *flightGroup
+	// Values read recently, nil without WithNearCache
+	near *nearCache
 }
 
 func New(nodes ...string) *DCacheClient {
+	return NewWithOptions(WithNodes(nodes...))
+}
+
+// Creates a client applying opts in order. Connections are established by Connect.
+func NewWithOptions(opts ...Option) *DCacheClient {
+	o := &options{replicas: 1, writeAcks: 1, readReplicas: 1, weights: make(map[string]int), maxResponseSize: defaultMaxResponseSize}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.ring == nil {
+		o.ring = ring.NewConsistentHash()
+	}
+
 	c := &DCacheClient{
-		dcring: ring.NewConsistentHash(),
-		mu:     &sync.RWMutex{},
-		done:   false,
+		dcring:   o.ring,
+		opts:     o,
+		mu:       &sync.RWMutex{},
+		done:     false,
+		draining: make(map[string]*dCacheConn),
+		flights:  &flightGroup{calls: make(map[string]*flight)},
+	}
+	if h, ok := o.ring.(*ring.ConsistentHash); ok && h.LoadFactor() != 0 {
+		c.bounded = h
+	}
+	if o.nearCache != nil {
+		c.near = &nearCache{cache: o.nearCache, ttl: o.nearTTL}
+		if o.tracking {
+			c.near.prefixes = o.trackingPrefixes
+		}
 	}
 
 	// Alloc conns map
-	c.conns = make(map[string]*dCacheConn, len(nodes))
-	for _, addr := range nodes {
-		c.conns[addr] = &dCacheConn{addr: addr, active: false, mu: &sync.Mutex{}}
-		c.dcring.Add(addr)
+	c.conns = make(map[string]*dCacheConn, len(o.nodes))
+	for _, addr := range o.nodes {
+		c.conns[addr] = c.newConn(addr)
+		c.addToRing(addr)
+	}
+	if _, ok := c.dcring.(ring.Weighted); !ok && len(o.weights) != 0 {
+		log.Printf("The ring doesn't support node weights, nodes weigh the same\n")
 	}
 
 	return c
 }
 
+func (c *DCacheClient) newConn(addr string) *dCacheConn {
+	return &dCacheConn{addr: addr, opts: c.opts, near: c.near, active: false, mu: &sync.Mutex{}}
+}
+
+// Connects to the node and adds it to the ring, with the weight it was given before if any.
 func (c *DCacheClient) AddNode(addr string, retries uint, retryInterval time.Duration) *DCacheError {
-	nodeConn := &dCacheConn{addr: addr, active: false, mu: &sync.Mutex{}}
+	return c.addNode(addr, 0, retries, retryInterval)
+}
+
+// Adds the node like AddNode with the given weight, see WithWeightedNodes.
+func (c *DCacheClient) AddWeightedNode(addr string, weight int, retries uint, retryInterval time.Duration) *DCacheError {
+	if _, ok := c.dcring.(ring.Weighted); !ok {
+		return dCacheUnweightedRingError()
+	}
+
+	return c.addNode(addr, clamp(weight, 1, ring.TopWeight), retries, retryInterval)
+}
+
+// Adds the node with weight, 0 keeps its current weight.
+func (c *DCacheClient) addNode(addr string, weight int, retries uint, retryInterval time.Duration) *DCacheError {
+	nodeConn := c.newConn(addr)
 	err := nodeConn.establishConn(retries, retryInterval)
 	if err != nil {
 		return err
@@ -45,31 +110,58 @@ func (c *DCacheClient) AddNode(addr string, retries uint, retryInterval time.Dur
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	if c.done {
+		nodeConn.close()
+		return dCacheTerminatedClientError()
+	}
+
+	c.recordRing()
 	c.conns[addr] = nodeConn
-	c.dcring.Add(addr)
+	if weight != 0 {
+		c.opts.weights[addr] = weight
+	}
+	c.addToRing(addr)
 	return nil
 }
 
+// Removes the node from the ring and closes its connection. In migration mode the connection is kept so
+// reads can fall back to the node until the migration finishes.
 func (c *DCacheClient) RemoveNode(addr string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	if dconn, ok := c.conns[addr]; ok && c.opts.migration {
+		c.recordRing()
+		c.dcring.Remove(addr)
+		delete(c.conns, addr)
+		c.draining[addr] = dconn
+		return
+	}
+
 	c.removeNode(addr)
 }
 
 func (c *DCacheClient) removeNode(addr string) {
 	c.dcring.Remove(addr)
-	dconn := c.conns[addr]
-	if dconn != nil {
-		dconn.conn.Close()
-	}
+	c.conns[addr].close()
 	delete(c.conns, addr)
 }
 
 // Establishes all non-initialized or lost connections to nodes in the address list.
 //
-// Active connections are not affected by multiple Connect calls.
+// Active connections are not affected by multiple Connect calls. With WithDiscovery nodes are discovered first,
+// failing only if no node is known afterwards.
 func (c *DCacheClient) Connect(retries uint, retryInterval time.Duration) *DCacheError {
+	if c.opts.discovery != nil {
+		// The nodes found are connected to as they're added, nodes that fail are left for the next discovery
+		if err := c.DiscoverNodes(retries, retryInterval); err != nil {
+			if len(c.Nodes()) == 0 {
+				return err
+			}
+			log.Printf("failed to discover nodes: %s\n", err)
+		}
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -88,47 +180,252 @@ func (c *DCacheClient) Connect(retries uint, retryInterval time.Duration) *DCach
 		}
 	}
 
+	if (c.opts.refreshInterval != 0 || c.opts.discoveryInterval != 0) && c.stopRefresh == nil {
+		c.stopRefresh = make(chan struct{})
+		if c.opts.refreshInterval != 0 {
+			go c.refreshLoop(c.opts.refreshInterval, c.stopRefresh)
+		}
+		if c.opts.discovery != nil && c.opts.discoveryInterval != 0 {
+			go c.discoveryLoop(c.opts.discoveryInterval, c.stopRefresh)
+		}
+	}
+
 	return nil
 }
 
+// Stores the key in its owners, succeeding once W of them acknowledge it when keys are replicated.
 func (c *DCacheClient) Set(key string, value []byte, ttl uint32) *DCacheError {
-	cmd := command.SetCmdAsBytes(key, value, ttl)
-	res, err := c.execCmd(cmd, key)
+	cmd := command.SetCmdAsBytes(key, c.versioned(value), ttl)
+	results, err := c.execReplicated(cmd, key, true)
+	c.near.invalidate(key)
 	if err != nil {
 		return err
-	} else if res[0] == core.CMD_EXEC_FAILED {
-		return dCacheSetCmdFailedError(key)
 	}
 
-	return nil
+	return c.setReplicas(key, results)
 }
 
+// Stores the key in its owners only if it's not stored already, returning false if it was. When keys are
+// replicated it's added once W owners add it, and keys deleted within the hour are still stored as tombstones.
+func (c *DCacheClient) Add(key string, value []byte, ttl uint32) (bool, *DCacheError) {
+	cmd := command.AddCmdAsBytes(key, c.versioned(value), ttl)
+	results, err := c.execReplicated(cmd, key, true)
+	c.near.invalidate(key)
+	if err != nil {
+		return false, err
+	}
+
+	return c.addReplicas(key, results)
+}
+
+// Reads the key from R of its owners when keys are replicated, returning the freshest value.
 func (c *DCacheClient) Get(key string) ([]byte, bool, *DCacheError) {
+	if value, ok := c.near.get(key); ok {
+		return value, true, nil
+	}
+	gen := c.near.generation()
+
 	cmd := command.GetCmdAsBytes(key)
-	res, err := c.execCmd(cmd, key)
+	results, err := c.execReplicated(cmd, key, false)
 	if err != nil {
 		return nil, false, err
-	} else if res[0] == core.CMD_EXEC_FAILED {
-		return nil, false, nil
 	}
 
-	return res[1:], true, nil
+	value, found, err := c.getReplicas(key, results)
+	if !found && quorumReached(err) && c.Migrating() {
+		value, found = c.getPrevious(key)
+	}
+	if found && err == nil {
+		c.near.set(key, value, gen)
+	}
+
+	return value, found, err
 }
 
+// Deletes the key from its owners. When keys are replicated a tombstone is stored in its place for an hour,
+// which Add doesn't replace.
 func (c *DCacheClient) Delete(key string) *DCacheError {
-	cmd := command.DeleteCmdAsBytes(key)
-	_, err := c.execCmd(cmd, key)
-	return err
+	results, err := c.execReplicated(c.deleteCmd(key), key, true)
+	c.near.invalidate(key)
+	if err != nil {
+		return err
+	}
+
+	if c.Migrating() {
+		c.deletePrevious(key)
+	}
+
+	return c.deleteReplicas(key, results)
 }
 
 func (c *DCacheClient) Has(key string) (bool, *DCacheError) {
+	if c.near.has(key) {
+		return true, nil
+	} else if c.replicated() {
+		// Values are read to tell tombstones apart
+		_, found, err := c.Get(key)
+		return found, err
+	}
+
 	cmd := command.HasCmdAsBytes(key)
-	res, err := c.execCmd(cmd, key)
+	results, err := c.execReplicated(cmd, key, false)
 	if err != nil {
 		return false, err
 	}
 
-	return res[0] == core.CMD_EXEC_SUCCEEDED, nil
+	found, err := c.hasReplicas(key, results)
+	if !found && quorumReached(err) && c.Migrating() {
+		_, found = c.getPrevious(key)
+	}
+
+	return found, err
+}
+
+// Interprets a SET response.
+func setResult(key string, res []byte) *DCacheError {
+	if len(res) == 0 {
+		return dCacheInvalidCmdError("set", key)
+	}
+
+	switch res[0] {
+	case core.CMD_EXEC_SUCCEEDED:
+		return nil
+	case core.CMD_EXEC_FAILED:
+		return dCacheSetCmdFailedError(key)
+	default:
+		return dCacheInvalidCmdError("set", key)
+	}
+}
+
+// Interprets a GET response, a failed GET means the key was not found.
+func getResult(key string, res []byte) ([]byte, bool, *DCacheError) {
+	if len(res) == 0 {
+		return nil, false, dCacheInvalidCmdError("get", key)
+	}
+
+	switch res[0] {
+	case core.CMD_EXEC_SUCCEEDED:
+		return res[1:], true, nil
+	case core.CMD_EXEC_FAILED:
+		return nil, false, nil
+	default:
+		return nil, false, dCacheInvalidCmdError("get", key)
+	}
+}
+
+// Interprets a HAS response.
+func hasResult(key string, res []byte) (bool, *DCacheError) {
+	if len(res) == 0 {
+		return false, dCacheInvalidCmdError("has", key)
+	}
+
+	switch res[0] {
+	case core.CMD_EXEC_SUCCEEDED:
+		return true, nil
+	case core.CMD_EXEC_FAILED:
+		return false, nil
+	default:
+		return false, dCacheInvalidCmdError("has", key)
+	}
+}
+
+// Interprets a DELETE response.
+func deleteResult(key string, res []byte) *DCacheError {
+	if len(res) == 0 || res[0] == core.INVALID_COMMAND_CODE {
+		return dCacheInvalidCmdError("delete", key)
+	}
+
+	return nil
+}
+
+// Lists the clients connected to the node with the given address, one per line.
+func (c *DCacheClient) ClientList(addr string) (string, *DCacheError) {
+	res, err := c.execCmdOnNode(command.ClientListCmdAsBytes(), addr)
+	if err != nil {
+		return "", err
+	} else if len(res) == 0 || res[0] != core.CMD_EXEC_SUCCEEDED {
+		return "", dCacheCmdFailedError("client list", addr)
+	}
+
+	return string(res[1:]), nil
+}
+
+// Returns the user the client is authenticated as in the node with the given address.
+func (c *DCacheClient) ACLWhoami(addr string) (string, *DCacheError) {
+	res, err := c.execCmdOnNode(command.ACLWhoamiCmdAsBytes(), addr)
+	if err != nil {
+		return "", err
+	} else if len(res) == 0 || res[0] != core.CMD_EXEC_SUCCEEDED {
+		return "", dCacheCmdFailedError("acl whoami", addr)
+	}
+
+	return string(res[1:]), nil
+}
+
+// Lists the users of the node with the given address and their permissions, one per line.
+func (c *DCacheClient) ACLList(addr string) (string, *DCacheError) {
+	res, err := c.execCmdOnNode(command.ACLListCmdAsBytes(), addr)
+	if err != nil {
+		return "", err
+	} else if len(res) == 0 || res[0] != core.CMD_EXEC_SUCCEEDED {
+		return "", dCacheCmdFailedError("acl list", addr)
+	}
+
+	return string(res[1:]), nil
+}
+
+// Makes the node with the given address write a snapshot to disk, returns once it's written.
+func (c *DCacheClient) Save(addr string) (string, *DCacheError) {
+	res, err := c.execCmdOnNode(command.SaveCmdAsBytes(), addr)
+	if err != nil {
+		return "", err
+	} else if len(res) == 0 {
+		return "", dCacheCmdFailedError("save", addr)
+	} else if res[0] != core.CMD_EXEC_SUCCEEDED {
+		return "", dCacheNodeCmdFailedError("save", addr, string(res[1:]))
+	}
+
+	return string(res[1:]), nil
+}
+
+// Describes the keys, clients and replication state of the node with the given address, one stat per line.
+func (c *DCacheClient) Stats(addr string) (string, *DCacheError) {
+	res, err := c.execCmdOnNode(command.StatsCmdAsBytes(), addr)
+	if err != nil {
+		return "", err
+	} else if len(res) == 0 || res[0] != core.CMD_EXEC_SUCCEEDED {
+		return "", dCacheCmdFailedError("stats", addr)
+	}
+
+	return string(res[1:]), nil
+}
+
+// Returns the address of the node responsible for the given key.
+func (c *DCacheClient) NodeFor(key string) (string, bool) {
+	return c.dcring.Get(key)
+}
+
+// Returns the addresses of the nodes storing the given key, starting with the one NodeFor returns.
+func (c *DCacheClient) NodesFor(key string) []string {
+	return c.dcring.GetN(key, c.opts.replicas)
+}
+
+// Returns a copy of the ring keys are mapped to nodes with.
+func (c *DCacheClient) Ring() ring.Ring {
+	return c.dcring.Clone()
+}
+
+// Maps every node address to wether its connection is active.
+func (c *DCacheClient) Nodes() map[string]bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	nodes := make(map[string]bool, len(c.conns))
+	for addr, dconn := range c.conns {
+		nodes[addr] = dconn.active
+	}
+
+	return nodes
 }
 
 // Ends current client, closes all node connections.
@@ -139,12 +436,16 @@ func (c *DCacheClient) End() {
 	for _, dconn := range c.conns {
 		c.removeNode(dconn.addr)
 	}
+	c.finishMigration()
+	if c.stopRefresh != nil && !c.done {
+		close(c.stopRefresh)
+	}
 
 	c.done = true
 }
 
-// Executes a command in the node responsible for the given key.
-func (c *DCacheClient) execCmd(cmd []byte, key string) ([]byte, *DCacheError) {
+// Executes a command in the node with the given address, which may be a node being drained.
+func (c *DCacheClient) execCmdOnNode(cmd []byte, addr string) ([]byte, *DCacheError) {
 	// Read locking due to use of c.conns
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -153,25 +454,19 @@ func (c *DCacheClient) execCmd(cmd []byte, key string) ([]byte, *DCacheError) {
 		return nil, dCacheTerminatedClientError()
 	}
 
-	dconn, err := c.selectTargetConn(key)
-	if err != nil {
-		return nil, err
+	dconn := c.nodeConn(addr)
+	if dconn == nil {
+		return nil, dCacheNodeNotFoundError(addr)
 	}
 
 	return dconn.execCmd(cmd)
 }
 
-// Selects which node should be responsible for the given key
-func (c *DCacheClient) selectTargetConn(key string) (*dCacheConn, *DCacheError) {
-	addr, ok := c.dcring.Get(key)
-	if !ok {
-		return nil, dCacheConnNotFoundError(key)
-	}
-
-	dconn := c.conns[addr]
-	if !dconn.active {
-		return nil, dCacheNotActiveConnError(addr)
+// Returns the connection to the node with the given address, nil if there's none.
+func (c *DCacheClient) nodeConn(addr string) *dCacheConn {
+	if dconn, ok := c.conns[addr]; ok {
+		return dconn
 	}
 
-	return dconn, nil
+	return c.draining[addr]
 }
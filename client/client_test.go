@@ -2,6 +2,9 @@ package client
 
 import (
 	"bytes"
+	"fmt"
+	"net"
+	"strconv"
 	"testing"
 	"time"
 
@@ -11,37 +14,44 @@ import (
 	"golang.org/x/exp/slices"
 )
 
-const (
-	s1Port uint16 = 3000
-	s2Port uint16 = 3001
-	s3Port uint16 = 3002
-	s4Port uint16 = 3003
-	s5Port uint16 = 3004
-
-	s1Addr = "127.0.0.1:3000"
-	s2Addr = "127.0.0.1:3001"
-	s3Addr = "127.0.0.1:3002"
-	s4Addr = "127.0.0.1:3003"
-	s5Addr = "127.0.0.1:3004"
-)
+// Addresses of the servers started by TestMain, in order
+var s1Addr, s2Addr, s3Addr, s4Addr, s5Addr string
 
 var client *DCacheClient
 
 func TestMain(m *testing.M) {
-	ports := []uint16{s1Port, s2Port, s3Port, s4Port, s5Port}
-	addresses := []string{s1Addr, s2Addr, s3Addr, s4Addr, s5Addr}
-	for _, port := range ports {
-		s := dcache.NewServer(port, fooche.NewSimple(), 1000)
-		go s.Start()
+	addresses := make([]string, 5)
+	for i := range addresses {
+		addresses[i] = startTestServer(dcache.NewServer(0, fooche.NewSimple(), 1000))
 	}
-
-	// Give some time so servers are up
-	time.Sleep(200 * time.Millisecond)
+	slices.Sort(addresses)
+	s1Addr, s2Addr, s3Addr, s4Addr, s5Addr = addresses[0], addresses[1], addresses[2], addresses[3], addresses[4]
 	client = New(addresses...)
 
 	m.Run()
 }
 
+// Starts s and returns its address once it's listening, panicking if it fails to start.
+func startTestServer(s *dcache.Server) string {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.Start()
+	}()
+
+	for deadline := time.Now().Add(5 * time.Second); s.Addr() == nil; time.Sleep(time.Millisecond) {
+		select {
+		case err := <-errs:
+			panic(fmt.Sprintf("server did not start: %s", err))
+		default:
+		}
+		if time.Now().After(deadline) {
+			panic("server did not start")
+		}
+	}
+
+	return net.JoinHostPort("127.0.0.1", strconv.Itoa(s.Addr().(*net.TCPAddr).Port))
+}
+
 func TestNew(t *testing.T) {
 	addrList := []string{s1Addr, s2Addr}
 	c := New(addrList...)
@@ -118,6 +128,40 @@ func TestCommands(t *testing.T) {
 	}
 }
 
+func TestEmptyResults(t *testing.T) {
+	if err := setResult("Foo", nil); err == nil || err.Code() != INVALID_CMD {
+		t.Errorf("expected empty SET response to be invalid, got %v", err)
+	}
+	if _, _, err := getResult("Foo", nil); err == nil || err.Code() != INVALID_CMD {
+		t.Errorf("expected empty GET response to be invalid, got %v", err)
+	}
+	if _, err := hasResult("Foo", nil); err == nil || err.Code() != INVALID_CMD {
+		t.Errorf("expected empty HAS response to be invalid, got %v", err)
+	}
+	if err := deleteResult("Foo", nil); err == nil || err.Code() != INVALID_CMD {
+		t.Errorf("expected empty DELETE response to be invalid, got %v", err)
+	}
+}
+
+func TestMaxResponseSize(t *testing.T) {
+	c := NewWithOptions(WithNodes(s1Addr), WithMaxResponseSize(16))
+	if err := c.Connect(2, 2*time.Second); err != nil {
+		t.Fatalf("failed to connect: %s", err)
+	}
+	defer c.End()
+
+	c.Set("max-response-big", bytes.Repeat([]byte{1}, 100), 10000)
+	c.Set("max-response-small", []byte("Bar"), 10000)
+
+	if _, _, err := c.Get("max-response-big"); err == nil {
+		t.Error("expected GET with a response over the limit to fail")
+	}
+	// The response was skipped, the connection still works
+	if v, found, err := c.Get("max-response-small"); err != nil || !found || string(v) != "Bar" {
+		t.Errorf("GET after a response over the limit = %q, %v, %v, want Bar", v, found, err)
+	}
+}
+
 func TestClose(t *testing.T) {
 	client.Connect(2, 2*time.Second)
 	client.End()
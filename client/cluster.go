@@ -0,0 +1,167 @@
+package client
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/joaovictorsl/dcache/core"
+	"github.com/joaovictorsl/dcache/core/command"
+)
+
+// A cluster member as listed by CLUSTER NODES.
+type ClusterNode struct {
+	Addr string
+	// core.MEMBER_ALIVE, core.MEMBER_SUSPECT or core.MEMBER_DEAD
+	State string
+	// Whether it's the node that listed it
+	Self bool
+}
+
+// Lists the cluster members known by the node with the given address.
+func (c *DCacheClient) ClusterNodes(addr string) ([]ClusterNode, *DCacheError) {
+	res, err := c.execCmdOnNode(command.ClusterNodesCmdAsBytes(), addr)
+	if err != nil {
+		return nil, err
+	} else if len(res) == 0 {
+		return nil, dCacheNodeCmdFailedError("cluster nodes", addr, "empty response")
+	} else if res[0] != core.CMD_EXEC_SUCCEEDED {
+		return nil, dCacheNodeCmdFailedError("cluster nodes", addr, string(res[1:]))
+	}
+
+	nodes := make([]ClusterNode, 0)
+	for _, line := range strings.Split(string(res[1:]), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		nodes = append(nodes, ClusterNode{Addr: fields[0], State: fields[1], Self: len(fields) > 2 && fields[2] == "myself"})
+	}
+
+	return nodes, nil
+}
+
+// Updates the ring with the cluster members listed by the first connected node that answers: alive members
+// are added, connecting to them, and dead members and nodes that are no longer members are removed.
+// Suspected members are left as they are.
+func (c *DCacheClient) RefreshRing(retries uint, retryInterval time.Duration) *DCacheError {
+	nodes := c.Nodes()
+	addrs := make([]string, 0, len(nodes))
+	for addr, active := range nodes {
+		if active {
+			addrs = append(addrs, addr)
+		}
+	}
+	sort.Strings(addrs)
+
+	var members []ClusterNode
+	err := dCacheNotActiveConnError("ring")
+	for _, addr := range addrs {
+		if members, err = c.ClusterNodes(addr); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	listed := make(map[string]string, len(members))
+	for _, m := range members {
+		listed[m.Addr] = m.State
+		if _, ok := nodes[m.Addr]; !ok && m.State == core.MEMBER_ALIVE {
+			if addErr := c.AddNode(m.Addr, retries, retryInterval); addErr != nil {
+				err = addErr
+			}
+		}
+	}
+
+	// Removed in order, as with rings such as ring.JumpHash owners depend on it
+	gone := make([]string, 0)
+	for addr := range nodes {
+		if state, ok := listed[addr]; !ok || state == core.MEMBER_DEAD {
+			gone = append(gone, addr)
+		}
+	}
+	sort.Strings(gone)
+	for _, addr := range gone {
+		c.RemoveNode(addr)
+	}
+
+	return err
+}
+
+// Sends cmd again to the owner named by replicas that answered MOVED, connecting to it if it's not in the
+// ring, and refreshes the ring in the background. Redirects are followed once, and not to nodes cmd was
+// already sent to; MOVED responses left become errors.
+func (c *DCacheClient) followMoved(cmd []byte, results []replicaResult) {
+	moved := false
+	for i, r := range results {
+		if r.err != nil || len(r.res) == 0 || r.res[0] != core.MOVED_CODE {
+			continue
+		}
+
+		moved = true
+		owner := string(r.res[1:])
+		results[i] = replicaResult{addr: r.addr, err: dCacheMovedError(r.addr, owner)}
+		if sentTo(results, owner) {
+			continue
+		}
+
+		if _, ok := c.Nodes()[owner]; !ok {
+			if err := c.AddNode(owner, 0, 0); err != nil {
+				continue
+			}
+		}
+
+		res, err := c.execCmdOnNode(cmd, owner)
+		if err == nil && len(res) != 0 && res[0] == core.MOVED_CODE {
+			err = dCacheMovedError(owner, string(res[1:]))
+		}
+		results[i] = replicaResult{addr: owner, res: res, err: err}
+	}
+
+	if moved {
+		c.refreshSoon()
+	}
+}
+
+func sentTo(results []replicaResult, addr string) bool {
+	for _, r := range results {
+		if r.addr == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// Refreshes the ring in the background, unless a refresh started this way is still running.
+func (c *DCacheClient) refreshSoon() {
+	if !c.refreshing.CompareAndSwap(false, true) {
+		return
+	}
+
+	go func() {
+		defer c.refreshing.Store(false)
+		if err := c.RefreshRing(0, 0); err != nil {
+			log.Printf("failed to refresh ring: %s\n", err)
+		}
+	}()
+}
+
+// Refreshes the ring every interval until the client ends.
+func (c *DCacheClient) refreshLoop(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.RefreshRing(0, 0); err != nil {
+				log.Printf("failed to refresh ring: %s\n", err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package codec
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// A Codec encodes values to the bytes stored in the cache and decodes them back. Implementations are safe for
+// concurrent use.
+type Codec interface {
+	// Marshal returns the encoding of v.
+	Marshal(v any) ([]byte, error)
+	// Unmarshal decodes data into the value v points to.
+	Unmarshal(data []byte, v any) error
+}
+
+var (
+	// JSON encodes values with encoding/json.
+	JSON Codec = jsonCodec{}
+	// Gob encodes values with encoding/gob. Every value carries its type description, so it suits large
+	// values better than small ones.
+	Gob Codec = gobCodec{}
+	// Msgpack encodes values with MessagePack, more compact and faster than JSON for the same struct tags.
+	Msgpack Codec = msgpackCodec{}
+	// Proto encodes values with their own Marshal and Unmarshal methods, such as the protobuf messages
+	// generated by gogo/protobuf or vtprotobuf, or with MarshalBinary and UnmarshalBinary.
+	Proto Codec = protoCodec{}
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Marshal(v any) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v any) error {
+	return msgpack.Unmarshal(data, v)
+}
+
+// Methods of protobuf messages generated by gogo/protobuf and vtprotobuf.
+type protoMarshaler interface {
+	Marshal() ([]byte, error)
+}
+
+type protoUnmarshaler interface {
+	Unmarshal(data []byte) error
+}
+
+type protoCodec struct{}
+
+func (protoCodec) Marshal(v any) ([]byte, error) {
+	// Generated methods have pointer receivers, so messages passed by value are copied to be addressable
+	if rv := reflect.ValueOf(v); rv.IsValid() && rv.Kind() != reflect.Pointer {
+		ptr := reflect.New(rv.Type())
+		ptr.Elem().Set(rv)
+		v = ptr.Interface()
+	}
+
+	switch m := v.(type) {
+	case protoMarshaler:
+		return m.Marshal()
+	case encoding.BinaryMarshaler:
+		return m.MarshalBinary()
+	default:
+		return nil, fmt.Errorf("codec: %T has neither a Marshal nor a MarshalBinary method", v)
+	}
+}
+
+func (protoCodec) Unmarshal(data []byte, v any) error {
+	// Decoding into a pointer to a message pointer, as for Typed[*Message], allocates the message
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer && rv.Elem().Kind() == reflect.Pointer {
+		if rv.Elem().IsNil() {
+			rv.Elem().Set(reflect.New(rv.Elem().Type().Elem()))
+		}
+		v = rv.Elem().Interface()
+	}
+
+	switch m := v.(type) {
+	case protoUnmarshaler:
+		return m.Unmarshal(data)
+	case encoding.BinaryUnmarshaler:
+		return m.UnmarshalBinary(data)
+	default:
+		return fmt.Errorf("codec: %T has neither an Unmarshal nor an UnmarshalBinary method", v)
+	}
+}
@@ -0,0 +1,78 @@
+package codec
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type user struct {
+	Name  string
+	Age   int
+	Tags  []string
+	Admin bool
+}
+
+// Stands in for a generated protobuf message.
+type point struct {
+	X, Y int32
+}
+
+func (p *point) Marshal() ([]byte, error) {
+	data := make([]byte, 8)
+	binary.LittleEndian.PutUint32(data, uint32(p.X))
+	binary.LittleEndian.PutUint32(data[4:], uint32(p.Y))
+	return data, nil
+}
+
+func (p *point) Unmarshal(data []byte) error {
+	if len(data) != 8 {
+		return errors.New("point: expected 8 bytes")
+	}
+	p.X = int32(binary.LittleEndian.Uint32(data))
+	p.Y = int32(binary.LittleEndian.Uint32(data[4:]))
+	return nil
+}
+
+var codecs = map[string]Codec{"json": JSON, "gob": Gob, "msgpack": Msgpack}
+
+func TestCodecRoundTrip(t *testing.T) {
+	want := user{Name: "ana", Age: 31, Tags: []string{"a", "b"}, Admin: true}
+	for name, c := range codecs {
+		data, err := c.Marshal(want)
+		require.NoError(t, err, name)
+
+		var got user
+		require.NoError(t, c.Unmarshal(data, &got), name)
+		assert.Equal(t, want, got, name)
+
+		assert.Error(t, c.Unmarshal([]byte{0xc1, 0xff}, &got), name)
+	}
+}
+
+func TestProto(t *testing.T) {
+	want := point{X: 3, Y: -7}
+
+	data, err := Proto.Marshal(want)
+	require.NoError(t, err)
+	ptrData, err := Proto.Marshal(&want)
+	require.NoError(t, err)
+	assert.Equal(t, data, ptrData)
+
+	var got point
+	require.NoError(t, Proto.Unmarshal(data, &got))
+	assert.Equal(t, want, got)
+
+	var gotPtr *point
+	require.NoError(t, Proto.Unmarshal(data, &gotPtr))
+	assert.Equal(t, &want, gotPtr)
+
+	assert.Error(t, Proto.Unmarshal(data[:3], &got))
+
+	_, err = Proto.Marshal(user{})
+	assert.Error(t, err)
+	assert.Error(t, Proto.Unmarshal(data, &user{}))
+}
@@ -1,27 +1,45 @@
 package client
 
 import (
+	"bufio"
 	"log"
 	"net"
 	"sync"
 	"time"
 
+	"github.com/joaovictorsl/dcache/core"
+	"github.com/joaovictorsl/dcache/core/command"
 	"github.com/joaovictorsl/dcache/core/protocol"
 )
 
 type dCacheConn struct {
-	addr   string
-	conn   net.Conn
-	active bool
-	mu     *sync.Mutex
+	addr string
+	// Shared with the client
+	opts *options
+	near *nearCache
+	// Receives the keys to remove from near while the connection is established, nil without WithTracking
+	tracking *trackingLink
+	conn     net.Conn
+	r        *bufio.Reader
+	active   bool
+	mu       *sync.Mutex
 }
 
-// Attempts to establish tcp connection to node.
+// Closes the connection, dc may be nil and the connection may have never been established.
+func (dc *dCacheConn) close() {
+	if dc != nil && dc.conn != nil {
+		dc.conn.Close()
+		dc.tracking.close()
+	}
+}
+
+// Attempts to establish tcp connection to node, authenticating it if the client has credentials.
 //
 // If not possible to establish connection on first try, then try to reconnect again retries times with a interval of retryInterval between attempts.
+// Failing to authenticate is not retried.
 func (dc *dCacheConn) establishConn(retries uint, retryInterval time.Duration) *DCacheError {
 	for {
-		conn, err := protocol.Connect(dc.addr)
+		conn, err := dc.dial()
 		if err != nil {
 			if retries != 0 {
 				time.Sleep(retryInterval)
@@ -32,14 +50,63 @@ func (dc *dCacheConn) establishConn(retries uint, retryInterval time.Duration) *
 			return dCacheFailedToConnectError(dc.addr, err)
 		}
 
+		r := bufio.NewReader(conn)
+		if err := dc.authenticate(conn, r); err != nil {
+			conn.Close()
+			if err.code == CONN_ERROR && retries != 0 {
+				time.Sleep(retryInterval)
+				retries--
+				continue
+			}
+
+			return err
+		}
+
 		dc.conn = conn
+		dc.r = r
 		dc.active = true
+		dc.startTracking()
 
 		log.Printf("(%s) Connection established\n", dc.addr)
 		return nil
 	}
 }
 
+func (dc *dCacheConn) dial() (net.Conn, error) {
+	if dc.opts.tlsConfig != nil {
+		return protocol.ConnectTLS(dc.addr, dc.opts.tlsConfig)
+	}
+
+	return protocol.Connect(dc.addr)
+}
+
+// Sends AUTH on a new connection, does nothing if the client has no credentials.
+func (dc *dCacheConn) authenticate(conn net.Conn, r *bufio.Reader) *DCacheError {
+	if dc.opts.secret == "" {
+		return nil
+	}
+
+	if err := protocol.WriteFrame(conn, command.AuthCmdAsBytes(dc.opts.username, dc.opts.secret)); err != nil {
+		return dCacheConnError(err)
+	}
+
+	res, err := protocol.ReadFrame(r, dc.opts.maxResponseSize)
+	if err != nil {
+		return dCacheConnError(err)
+	} else if len(res) == 0 {
+		return dCacheAuthFailedError(dc.addr, "empty response")
+	}
+
+	switch res[0] {
+	case core.CMD_EXEC_SUCCEEDED:
+		return nil
+	case core.MAX_CONNS_REACHED_CODE:
+		return dCacheConnRejectedError(dc.addr, string(res[1:]))
+	default:
+		return dCacheAuthFailedError(dc.addr, string(res[1:]))
+	}
+}
+
 // Executes a command
 func (dc *dCacheConn) execCmd(cmd []byte) ([]byte, *DCacheError) {
 	dc.mu.Lock()
@@ -49,20 +116,121 @@ func (dc *dCacheConn) execCmd(cmd []byte) ([]byte, *DCacheError) {
 		return nil, dCacheNotActiveConnError(dc.addr)
 	}
 
-	_, err := dc.conn.Write(cmd)
+	err := protocol.WriteFrame(dc.conn, cmd)
 	if err != nil {
 		// Connection is unavailable
 		dc.active = false
 		return nil, dCacheConnError(err)
 	}
 
-	buf := make([]byte, 2048)
-	n, err := dc.conn.Read(buf)
-	if err != nil {
+	res, err := protocol.ReadFrame(dc.r, dc.opts.maxResponseSize)
+	if err == protocol.ErrFrameTooLarge {
+		// Only the response was skipped, the connection can still be used
+		return nil, dCacheConnError(err)
+	} else if err != nil {
 		// Connection is unavailable
 		dc.active = false
 		return nil, dCacheConnError(err)
 	}
 
-	return buf[:n], nil
+	if err := dc.checkStatus(res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// Checks for statuses that apply to any command. If the node refused the connection it's closed and marked as not active.
+func (dc *dCacheConn) checkStatus(res []byte) *DCacheError {
+	if len(res) == 0 {
+		return nil
+	}
+
+	switch res[0] {
+	case core.MAX_CONNS_REACHED_CODE:
+		dc.active = false
+		dc.conn.Close()
+		return dCacheConnRejectedError(dc.addr, string(res[1:]))
+	case core.AUTH_REQUIRED_CODE:
+		return dCacheAuthRequiredError(dc.addr)
+	case core.NO_PERMISSION_CODE:
+		return dCacheNoPermissionError(dc.addr)
+	case core.READ_ONLY_CODE:
+		return dCacheReadOnlyError(dc.addr)
+	}
+
+	return nil
+}
+
+// Executes commands in order with a single round trip, responses and errors are returned in the same order.
+// Every response is read even when some commands fail, so none are left for later commands to read.
+func (dc *dCacheConn) execPipeline(cmds [][]byte) ([][]byte, []*DCacheError) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	res := make([][]byte, len(cmds))
+	errs := make([]*DCacheError, len(cmds))
+	// Fails the commands from i on
+	fail := func(i int, err *DCacheError) ([][]byte, []*DCacheError) {
+		for ; i < len(cmds); i++ {
+			errs[i] = err
+		}
+		return res, errs
+	}
+
+	if !dc.active {
+		return fail(0, dCacheNotActiveConnError(dc.addr))
+	}
+
+	// Commands are written while responses are read, otherwise large pipelines fill the buffers of both
+	// sides with neither reading
+	written := make(chan error, 1)
+	go func() {
+		w := bufio.NewWriter(dc.conn)
+		for _, cmd := range cmds {
+			if err := protocol.WriteFrame(w, cmd); err != nil {
+				// Unblocks the reads of responses that won't come
+				dc.conn.Close()
+				written <- err
+				return
+			}
+		}
+
+		err := w.Flush()
+		if err != nil {
+			dc.conn.Close()
+		}
+		written <- err
+	}()
+
+	for i := range cmds {
+		frame, err := protocol.ReadFrame(dc.r, dc.opts.maxResponseSize)
+		if err == protocol.ErrFrameTooLarge {
+			errs[i] = dCacheConnError(err)
+			continue
+		} else if err != nil {
+			// Connection is unavailable
+			dc.active = false
+			dc.conn.Close()
+			if werr := <-written; werr != nil {
+				err = werr
+			}
+			return fail(i, dCacheConnError(err))
+		}
+
+		if err := dc.checkStatus(frame); err != nil {
+			if !dc.active {
+				// The node refused the connection, nothing else will be answered
+				<-written
+				return fail(i, err)
+			}
+			errs[i] = err
+			continue
+		}
+		res[i] = frame
+	}
+
+	// Every command was answered, so every command was written
+	<-written
+	return res, errs
 }
@@ -0,0 +1,282 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joaovictorsl/dcache/client/ring"
+	"gopkg.in/yaml.v3"
+)
+
+// Time a discovery may take before it's abandoned
+const discoveryTimeout = 5 * time.Second
+
+// A node found by a Discovery.
+type DiscoveredNode struct {
+	Addr string
+	// From 1 to ring.TopWeight, 0 if the node has no weight
+	Weight int
+}
+
+// A Discovery finds the nodes of the cluster, see WithDiscovery.
+type Discovery interface {
+	// Returns the nodes currently in the cluster.
+	Discover(ctx context.Context) ([]DiscoveredNode, error)
+}
+
+// Resolves the DNS records looked up by DNSDiscovery, *net.Resolver implements it.
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// Finds nodes with DNS lookups, see NewDNSDiscovery and NewSRVDiscovery.
+type DNSDiscovery struct {
+	resolver Resolver
+	// Host whose A and AAAA records are looked up, nodes listen on port
+	host string
+	port uint16
+	// SRV records of _service._proto.name are looked up instead when service is set
+	service string
+	proto   string
+	name    string
+}
+
+// Finds nodes listening on port at the addresses of the A and AAAA records of host, e.g. a headless service.
+// resolver may be nil to use net.DefaultResolver.
+func NewDNSDiscovery(host string, port uint16, resolver Resolver) *DNSDiscovery {
+	return &DNSDiscovery{resolver: defaultResolver(resolver), host: host, port: port}
+}
+
+// Finds nodes at the targets and ports of the SRV records of _service._proto.name, e.g. _dcache._tcp.example.com.
+// Record weights are scaled so the heaviest node weighs ring.TopWeight, unless all records weigh the same, and
+// priorities are ignored. resolver may be nil to use net.DefaultResolver.
+func NewSRVDiscovery(service, proto, name string, resolver Resolver) *DNSDiscovery {
+	return &DNSDiscovery{resolver: defaultResolver(resolver), service: service, proto: proto, name: name}
+}
+
+func defaultResolver(resolver Resolver) Resolver {
+	if resolver == nil {
+		return net.DefaultResolver
+	}
+	return resolver
+}
+
+func (d *DNSDiscovery) Discover(ctx context.Context) ([]DiscoveredNode, error) {
+	if d.service != "" {
+		return d.discoverSRV(ctx)
+	}
+
+	hosts, err := d.resolver.LookupHost(ctx, d.host)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make([]DiscoveredNode, len(hosts))
+	for i, host := range hosts {
+		nodes[i].Addr = net.JoinHostPort(host, strconv.Itoa(int(d.port)))
+	}
+
+	return sortedNodes(nodes), nil
+}
+
+func (d *DNSDiscovery) discoverSRV(ctx context.Context) ([]DiscoveredNode, error) {
+	_, records, err := d.resolver.LookupSRV(ctx, d.service, d.proto, d.name)
+	if err != nil {
+		return nil, err
+	}
+
+	var heaviest uint16
+	weighted := false
+	for _, r := range records {
+		weighted = weighted || r.Weight != records[0].Weight
+		if r.Weight > heaviest {
+			heaviest = r.Weight
+		}
+	}
+
+	nodes := make([]DiscoveredNode, len(records))
+	for i, r := range records {
+		nodes[i].Addr = net.JoinHostPort(strings.TrimSuffix(r.Target, "."), strconv.Itoa(int(r.Port)))
+		if weighted {
+			nodes[i].Weight = clamp(int(r.Weight)*ring.TopWeight/int(heaviest), 1, ring.TopWeight)
+		}
+	}
+
+	return sortedNodes(nodes), nil
+}
+
+// Finds nodes listed in a JSON or YAML file, picked by its extension, e.g.
+//
+//	nodes:
+//	  - addr: 10.0.0.1:3000
+//	  - addr: 10.0.0.2:3000
+//	    weight: 50
+//
+// The file is only read again once its modification time or size change.
+type FileDiscovery struct {
+	path string
+
+	mu sync.Mutex
+	// Nodes listed when the file had modTime and size
+	nodes   []DiscoveredNode
+	modTime time.Time
+	size    int64
+}
+
+type discoveryFile struct {
+	Nodes []struct {
+		Addr   string `yaml:"addr" json:"addr"`
+		Weight int    `yaml:"weight" json:"weight"`
+	} `yaml:"nodes" json:"nodes"`
+}
+
+func NewFileDiscovery(path string) *FileDiscovery {
+	return &FileDiscovery{path: path}
+}
+
+func (d *FileDiscovery) Discover(_ context.Context) ([]DiscoveredNode, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	info, err := os.Stat(d.path)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.nodes != nil && info.ModTime().Equal(d.modTime) && info.Size() == d.size {
+		return append([]DiscoveredNode(nil), d.nodes...), nil
+	}
+
+	nodes, err := readDiscoveryFile(d.path)
+	if err != nil {
+		return nil, err
+	}
+
+	d.nodes, d.modTime, d.size = nodes, info.ModTime(), info.Size()
+	return append([]DiscoveredNode(nil), nodes...), nil
+}
+
+func readDiscoveryFile(path string) ([]DiscoveredNode, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var f discoveryFile
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &f)
+	case ".json":
+		err = json.Unmarshal(data, &f)
+	default:
+		return nil, fmt.Errorf("%s: unsupported file extension %q", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	nodes := make([]DiscoveredNode, len(f.Nodes))
+	for i, n := range f.Nodes {
+		if n.Addr == "" {
+			return nil, fmt.Errorf("%s: node without addr", path)
+		} else if n.Weight < 0 || n.Weight > ring.TopWeight {
+			return nil, fmt.Errorf("%s: weight of %s must be between 1 and %d", path, n.Addr, ring.TopWeight)
+		}
+		nodes[i] = DiscoveredNode{Addr: n.Addr, Weight: n.Weight}
+	}
+
+	return sortedNodes(nodes), nil
+}
+
+// Sorts nodes by address, so every client adds them in the same order.
+func sortedNodes(nodes []DiscoveredNode) []DiscoveredNode {
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Addr < nodes[j].Addr
+	})
+	return nodes
+}
+
+// Updates the ring with the nodes found by the client's Discovery: new nodes are added, connecting to them,
+// the weights of the others are updated and nodes no longer found are removed. An empty result is treated
+// as an error and leaves the ring as it is. Nodes that can't be added are left out until the next discovery.
+func (c *DCacheClient) DiscoverNodes(retries uint, retryInterval time.Duration) *DCacheError {
+	if c.opts.discovery == nil {
+		return dCacheDiscoveryFailedError(fmt.Errorf("the client has no discovery"))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), discoveryTimeout)
+	found, discoverErr := c.opts.discovery.Discover(ctx)
+	cancel()
+	if discoverErr != nil {
+		return dCacheDiscoveryFailedError(discoverErr)
+	} else if len(found) == 0 {
+		return dCacheDiscoveryFailedError(fmt.Errorf("no nodes found"))
+	}
+
+	_, weighted := c.dcring.(ring.Weighted)
+	current := c.NodeWeights()
+	listed := make(map[string]bool, len(found))
+	var err *DCacheError
+	// Discovery implementations outside this package may not sort what they find
+	for _, n := range sortedNodes(found) {
+		listed[n.Addr] = true
+
+		weight, ok := current[n.Addr]
+		var nodeErr *DCacheError
+		switch {
+		case !ok && weighted && n.Weight != 0:
+			nodeErr = c.AddWeightedNode(n.Addr, n.Weight, retries, retryInterval)
+		case !ok:
+			nodeErr = c.AddNode(n.Addr, retries, retryInterval)
+		case weighted && n.Weight == 0 && weight != ring.TopWeight:
+			nodeErr = c.SetNodeWeight(n.Addr, ring.TopWeight)
+		case weighted && n.Weight != 0 && weight != n.Weight:
+			nodeErr = c.SetNodeWeight(n.Addr, n.Weight)
+		}
+		if nodeErr != nil {
+			err = nodeErr
+		}
+	}
+
+	// Removed in order, as with rings such as ring.JumpHash owners depend on it
+	gone := make([]string, 0)
+	for addr := range current {
+		if !listed[addr] {
+			gone = append(gone, addr)
+		}
+	}
+	sort.Strings(gone)
+	for _, addr := range gone {
+		c.RemoveNode(addr)
+	}
+
+	return err
+}
+
+// Discovers nodes every interval until the client ends.
+func (c *DCacheClient) discoveryLoop(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.DiscoverNodes(0, 0); err != nil {
+				log.Printf("failed to discover nodes: %s\n", err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
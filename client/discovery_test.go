@@ -0,0 +1,200 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Answers lookups with fixed records instead of querying DNS.
+type stubResolver struct {
+	hosts map[string][]string
+	srvs  map[string][]*net.SRV
+}
+
+func (r *stubResolver) LookupHost(_ context.Context, host string) ([]string, error) {
+	if addrs, ok := r.hosts[host]; ok {
+		return addrs, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func (r *stubResolver) LookupSRV(_ context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	cname := "_" + service + "._" + proto + "." + name
+	if records, ok := r.srvs[cname]; ok {
+		return cname, records, nil
+	}
+	return "", nil, &net.DNSError{Err: "no such host", Name: cname, IsNotFound: true}
+}
+
+func TestDNSDiscovery(t *testing.T) {
+	r := &stubResolver{
+		hosts: map[string][]string{"dcache.local": {"10.0.0.2", "10.0.0.1", "fd00::1"}},
+		srvs: map[string][]*net.SRV{
+			"_dcache._tcp.example.com": {
+				{Target: "b.example.com.", Port: 3001, Weight: 10},
+				{Target: "a.example.com.", Port: 3000, Weight: 20},
+			},
+			"_even._tcp.example.com": {
+				{Target: "a.example.com.", Port: 3000, Weight: 5},
+				{Target: "b.example.com.", Port: 3000, Weight: 5},
+			},
+		},
+	}
+
+	cases := []struct {
+		d    *DNSDiscovery
+		want []DiscoveredNode
+	}{
+		{NewDNSDiscovery("dcache.local", 3000, r), []DiscoveredNode{{Addr: "10.0.0.1:3000"}, {Addr: "10.0.0.2:3000"}, {Addr: "[fd00::1]:3000"}}},
+		{NewSRVDiscovery("dcache", "tcp", "example.com", r), []DiscoveredNode{{"a.example.com:3000", 100}, {"b.example.com:3001", 50}}},
+		{NewSRVDiscovery("even", "tcp", "example.com", r), []DiscoveredNode{{Addr: "a.example.com:3000"}, {Addr: "b.example.com:3000"}}},
+	}
+	for _, tc := range cases {
+		nodes, err := tc.d.Discover(context.Background())
+		if err != nil {
+			t.Errorf("no error was expected on discovery, but got: %s", err)
+		} else if !reflect.DeepEqual(nodes, tc.want) {
+			t.Errorf("expected %v, got %v", tc.want, nodes)
+		}
+	}
+
+	if _, err := NewDNSDiscovery("missing.local", 3000, r).Discover(context.Background()); err == nil {
+		t.Errorf("expected an error looking up a missing host")
+	}
+}
+
+func writeDiscoveryFile(t *testing.T, path, content string) {
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileDiscovery(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "nodes.yaml")
+	writeDiscoveryFile(t, path, "nodes:\n  - addr: 10.0.0.2:3000\n    weight: 50\n  - addr: 10.0.0.1:3000\n")
+
+	d := NewFileDiscovery(path)
+	want := []DiscoveredNode{{Addr: "10.0.0.1:3000"}, {"10.0.0.2:3000", 50}}
+	if nodes, err := d.Discover(context.Background()); err != nil || !reflect.DeepEqual(nodes, want) {
+		t.Errorf("expected %v, got %v, %v", want, nodes, err)
+	}
+
+	// Not read again while its modification time and size are the same
+	info, _ := os.Stat(path)
+	writeDiscoveryFile(t, path, "nodes:\n  - addr: 10.0.0.3:3000\n    weight: 50\n  - addr: 10.0.0.1:3000\n")
+	os.Chtimes(path, info.ModTime(), info.ModTime())
+	if nodes, _ := d.Discover(context.Background()); !reflect.DeepEqual(nodes, want) {
+		t.Errorf("expected the file not to be read again, got %v", nodes)
+	}
+
+	os.Chtimes(path, info.ModTime().Add(time.Second), info.ModTime().Add(time.Second))
+	want = []DiscoveredNode{{Addr: "10.0.0.1:3000"}, {"10.0.0.3:3000", 50}}
+	if nodes, err := d.Discover(context.Background()); err != nil || !reflect.DeepEqual(nodes, want) {
+		t.Errorf("expected %v, got %v, %v", want, nodes, err)
+	}
+
+	jsonPath := filepath.Join(dir, "nodes.json")
+	writeDiscoveryFile(t, jsonPath, `{"nodes": [{"addr": "10.0.0.1:3000", "weight": 20}]}`)
+	want = []DiscoveredNode{{"10.0.0.1:3000", 20}}
+	if nodes, err := NewFileDiscovery(jsonPath).Discover(context.Background()); err != nil || !reflect.DeepEqual(nodes, want) {
+		t.Errorf("expected %v, got %v, %v", want, nodes, err)
+	}
+
+	invalid := map[string]string{
+		"weight.json": `{"nodes": [{"addr": "10.0.0.1:3000", "weight": 101}]}`,
+		"addr.yaml":   "nodes:\n  - weight: 10\n",
+		"nodes.toml":  "",
+	}
+	for name, content := range invalid {
+		p := filepath.Join(dir, name)
+		writeDiscoveryFile(t, p, content)
+		if _, err := NewFileDiscovery(p).Discover(context.Background()); err == nil {
+			t.Errorf("expected an error reading %s", name)
+		}
+	}
+}
+
+// Returns a fixed result, or err if set.
+type stubDiscovery struct {
+	nodes chan []DiscoveredNode
+	last  []DiscoveredNode
+	err   error
+}
+
+func (d *stubDiscovery) Discover(context.Context) ([]DiscoveredNode, error) {
+	select {
+	case d.last = <-d.nodes:
+	default:
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return d.last, nil
+}
+
+func TestDiscovery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nodes.json")
+	writeDiscoveryFile(t, path, `{"nodes": [{"addr": "`+s1Addr+`"}, {"addr": "`+s2Addr+`", "weight": 50}]}`)
+
+	c := NewWithOptions(WithDiscovery(NewFileDiscovery(path), 20*time.Millisecond))
+	if err := c.Connect(2, time.Second); err != nil {
+		t.Fatalf("no error was expected on connect, but got: %s", err)
+	}
+	defer c.End()
+
+	want := map[string]int{s1Addr: 100, s2Addr: 50}
+	if weights := c.NodeWeights(); !reflect.DeepEqual(weights, want) {
+		t.Errorf("expected %v after connecting, got %v", want, weights)
+	}
+	if nodes := c.Nodes(); !nodes[s1Addr] || !nodes[s2Addr] {
+		t.Errorf("expected discovered nodes to be connected, got %v", nodes)
+	}
+
+	writeDiscoveryFile(t, path, `{"nodes": [{"addr": "`+s2Addr+`"}, {"addr": "`+s3Addr+`", "weight": 25}]}`)
+	want = map[string]int{s2Addr: 100, s3Addr: 25}
+	deadline := time.Now().Add(2 * time.Second)
+	for weights := c.NodeWeights(); !reflect.DeepEqual(weights, want); weights = c.NodeWeights() {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %v once the file changed, got %v", want, weights)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Failed or empty discoveries leave the ring as it is
+	d := &stubDiscovery{nodes: make(chan []DiscoveredNode, 1)}
+	c = NewWithOptions(WithNodes(s1Addr), WithDiscovery(d, 0))
+	defer c.End()
+	d.err = errors.New("unreachable")
+	if err := c.Connect(2, time.Second); err != nil {
+		t.Errorf("expected connect to succeed with nodes given, got %s", err)
+	}
+	if err := c.DiscoverNodes(0, 0); err == nil || err.Code() != DISCOVERY_FAILED {
+		t.Errorf("expected discovery to fail, got %v", err)
+	}
+	d.err = nil
+	d.nodes <- []DiscoveredNode{}
+	if err := c.DiscoverNodes(0, 0); err == nil || err.Code() != DISCOVERY_FAILED {
+		t.Errorf("expected discovery without nodes to fail, got %v", err)
+	} else if nodes := c.Nodes(); len(nodes) != 1 || !nodes[s1Addr] {
+		t.Errorf("expected the ring to be kept, got %v", nodes)
+	}
+
+	// Nodes that can't be connected to are left out
+	d.nodes <- []DiscoveredNode{{Addr: s1Addr}, {Addr: "127.0.0.1:1"}}
+	if err := c.DiscoverNodes(0, 0); err == nil {
+		t.Errorf("expected an error adding an unreachable node")
+	} else if nodes := c.Nodes(); len(nodes) != 1 || !nodes[s1Addr] {
+		t.Errorf("expected only %s in the ring, got %v", s1Addr, nodes)
+	}
+
+	if err := New(s1Addr).DiscoverNodes(0, 0); err == nil || err.Code() != DISCOVERY_FAILED {
+		t.Errorf("expected an error without discovery, got %v", err)
+	}
+}
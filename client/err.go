@@ -2,6 +2,8 @@ package client
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 )
 
 const (
@@ -11,11 +13,28 @@ const (
 	TERMINATED_CLIENT
 	CONN_NOT_FOUND
 	CMD_FAILED
+	INVALID_CMD
+	CONN_REJECTED
+	AUTH_FAILED
+	AUTH_REQUIRED
+	NO_PERMISSION
+	READ_ONLY
+	QUORUM_FAILED
+	REPLICA_FAILED
+	MOVED
+	UNWEIGHTED_RING
+	DISCOVERY_FAILED
+	CODEC_FAILED
+	LOAD_FAILED
 )
 
 type DCacheError struct {
 	msg  string
 	code uint
+	// Errors of the replicas a replicated command failed on, by node address
+	nodeErrs map[string]*DCacheError
+	// Error that caused this one, returned by Unwrap
+	cause error
 }
 
 func dCacheNotActiveConnError(addr string) *DCacheError {
@@ -64,6 +83,140 @@ func dCacheCmdFailedError(cmd, key string) *DCacheError {
 	}
 }
 
+func dCacheNodeCmdFailedError(cmd, addr, reason string) *DCacheError {
+	return &DCacheError{
+		msg:  fmt.Sprintf("(%s) %s command failed: %s", addr, cmd, reason),
+		code: CMD_FAILED,
+	}
+}
+
+func dCacheInvalidCmdError(cmd, key string) *DCacheError {
+	return &DCacheError{
+		msg:  fmt.Sprintf("%s command on key %s was rejected as invalid", cmd, key),
+		code: INVALID_CMD,
+	}
+}
+
+func dCacheKeyTooLongError(key string, max int) *DCacheError {
+	return &DCacheError{
+		msg:  fmt.Sprintf("key %s is longer than %d bytes", key, max),
+		code: INVALID_CMD,
+	}
+}
+
+func dCacheConnRejectedError(addr, reason string) *DCacheError {
+	return &DCacheError{
+		msg:  fmt.Sprintf("(%s) connection rejected: %s", addr, reason),
+		code: CONN_REJECTED,
+	}
+}
+
+func dCacheNodeNotFoundError(addr string) *DCacheError {
+	return &DCacheError{
+		msg:  fmt.Sprintf("node (%s) was not found", addr),
+		code: CONN_NOT_FOUND,
+	}
+}
+
+func dCacheAuthFailedError(addr, reason string) *DCacheError {
+	return &DCacheError{
+		msg:  fmt.Sprintf("(%s) authentication failed: %s", addr, reason),
+		code: AUTH_FAILED,
+	}
+}
+
+func dCacheAuthRequiredError(addr string) *DCacheError {
+	return &DCacheError{
+		msg:  fmt.Sprintf("(%s) authentication required", addr),
+		code: AUTH_REQUIRED,
+	}
+}
+
+func dCacheNoPermissionError(addr string) *DCacheError {
+	return &DCacheError{
+		msg:  fmt.Sprintf("(%s) user has no permission to run command", addr),
+		code: NO_PERMISSION,
+	}
+}
+
+func dCacheReadOnlyError(addr string) *DCacheError {
+	return &DCacheError{
+		msg:  fmt.Sprintf("(%s) node is a read only replica", addr),
+		code: READ_ONLY,
+	}
+}
+
+// The node doesn't own the key, owner is the node that does according to the cluster.
+func dCacheMovedError(addr, owner string) *DCacheError {
+	return &DCacheError{
+		msg:  fmt.Sprintf("(%s) key is owned by %s", addr, owner),
+		code: MOVED,
+	}
+}
+
+func dCacheUnweightedRingError() *DCacheError {
+	return &DCacheError{
+		msg:  "the ring doesn't support node weights",
+		code: UNWEIGHTED_RING,
+	}
+}
+
+func dCacheDiscoveryFailedError(err error) *DCacheError {
+	return &DCacheError{
+		msg:  fmt.Sprintf("node discovery failed: %s", err),
+		code: DISCOVERY_FAILED,
+	}
+}
+
+// Encoding or decoding the value of key with a codec failed, op is either "encode" or "decode".
+func dCacheCodecError(op, key string, err error) *DCacheError {
+	return &DCacheError{
+		msg:   fmt.Sprintf("failed to %s value of key %s: %s", op, key, err),
+		code:  CODEC_FAILED,
+		cause: err,
+	}
+}
+
+func dCacheLoadFailedError(key string, err error) *DCacheError {
+	return &DCacheError{
+		msg:   fmt.Sprintf("failed to load key %s: %s", key, err),
+		code:  LOAD_FAILED,
+		cause: err,
+	}
+}
+
+func dCacheQuorumFailedError(cmd, key string, acks, need int, nodeErrs map[string]*DCacheError) *DCacheError {
+	return &DCacheError{
+		msg:      fmt.Sprintf("%s command on key %s acknowledged by %d replicas, %d required: %s", cmd, key, acks, need, joinNodeErrors(nodeErrs)),
+		code:     QUORUM_FAILED,
+		nodeErrs: nodeErrs,
+	}
+}
+
+// The command succeeded, but not on every replica.
+func dCacheReplicaFailedError(cmd, key string, nodeErrs map[string]*DCacheError) *DCacheError {
+	return &DCacheError{
+		msg:      fmt.Sprintf("%s command on key %s failed on %d replicas: %s", cmd, key, len(nodeErrs), joinNodeErrors(nodeErrs)),
+		code:     REPLICA_FAILED,
+		nodeErrs: nodeErrs,
+	}
+}
+
+func joinNodeErrors(nodeErrs map[string]*DCacheError) string {
+	addrs := make([]string, 0, len(nodeErrs))
+	for addr := range nodeErrs {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+
+	msgs := make([]string, len(addrs))
+	for i, addr := range addrs {
+		msgs[i] = nodeErrs[addr].msg
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
 func (dcerr *DCacheError) Error() string {
 	return dcerr.msg
 }
@@ -71,3 +224,13 @@ func (dcerr *DCacheError) Error() string {
 func (dcerr *DCacheError) Code() uint {
 	return dcerr.code
 }
+
+// Errors of the replicas a command failed on by node address, for errors with code QUORUM_FAILED or REPLICA_FAILED.
+func (dcerr *DCacheError) NodeErrors() map[string]*DCacheError {
+	return dcerr.nodeErrs
+}
+
+// Returns the error of the codec or loader for errors with code CODEC_FAILED or LOAD_FAILED, nil otherwise.
+func (dcerr *DCacheError) Unwrap() error {
+	return dcerr.cause
+}
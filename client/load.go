@@ -0,0 +1,35 @@
+package client
+
+import "github.com/joaovictorsl/dcache/client/ring"
+
+// Owners of key commands are sent to, skipping the ones at capacity when the ring bounds loads.
+func (c *DCacheClient) owners(key string) []string {
+	if c.bounded != nil {
+		return c.bounded.GetNBounded(key, c.opts.replicas)
+	}
+
+	return c.dcring.GetN(key, c.opts.replicas)
+}
+
+// Counts a command in flight to the node at addr when the ring bounds loads.
+func (c *DCacheClient) loadInc(addr string) {
+	if c.bounded != nil {
+		c.bounded.Inc(addr)
+	}
+}
+
+func (c *DCacheClient) loadDone(addr string) {
+	if c.bounded != nil {
+		c.bounded.Done(addr)
+	}
+}
+
+// Returns the commands in flight to every node and how often commands spilled to a node other than their
+// owner. Empty if the client doesn't bound loads, see WithBoundedLoads.
+func (c *DCacheClient) LoadStats() ring.LoadStats {
+	if c.bounded == nil {
+		return ring.LoadStats{Loads: make(map[string]int64)}
+	}
+
+	return c.bounded.LoadStats()
+}
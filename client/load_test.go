@@ -0,0 +1,50 @@
+package client
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestBoundedLoads(t *testing.T) {
+	c := NewWithOptions(WithNodes(s1Addr, s2Addr, s3Addr), WithBoundedLoads(1.25))
+	if err := c.Connect(2, time.Second); err != nil {
+		t.Fatalf("no error was expected on connect, but got: %s", err)
+	}
+	defer c.End()
+
+	for i := 0; i < 20; i++ {
+		key := "bounded" + strconv.Itoa(i)
+		if err := c.Set(key, []byte(key), 10000); err != nil {
+			t.Errorf("no error was expected on SET operation, but got: %s", err)
+		}
+
+		if res, ok, err := c.Get(key); err != nil || !ok || string(res) != key {
+			t.Errorf("expected GET on %s to return it, got %q, %v, %v", key, res, ok, err)
+		}
+	}
+
+	p := c.Pipeline()
+	for i := 0; i < 20; i++ {
+		p.Get("bounded" + strconv.Itoa(i))
+	}
+	for _, r := range p.Exec() {
+		if r.Err != nil || !r.Found {
+			t.Errorf("expected pipelined GET on %s to find it, got %v, %v", r.Key, r.Found, r.Err)
+		}
+	}
+
+	// Commands don't overlap, so none of them spill
+	stats := c.LoadStats()
+	if stats.Lookups != 60 {
+		t.Errorf("expected 60 lookups, got %d", stats.Lookups)
+	} else if stats.Spills != 0 {
+		t.Errorf("expected no spills, got %d", stats.Spills)
+	} else if len(stats.Loads) != 0 {
+		t.Errorf("expected no load once commands are done, got %v", stats.Loads)
+	}
+
+	if stats := client.LoadStats(); stats.Lookups != 0 || len(stats.Loads) != 0 {
+		t.Errorf("expected no load stats without bounded loads, got %+v", stats)
+	}
+}
@@ -0,0 +1,344 @@
+package client
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/joaovictorsl/dcache/client/ring"
+	"github.com/joaovictorsl/dcache/core"
+	"github.com/joaovictorsl/dcache/core/command"
+)
+
+// Keys listed by each SCAN sent by Rebalance
+const rebalanceScanCount = 1000
+
+// Outcome of a Rebalance.
+type RebalanceStats struct {
+	// Keys found in nodes that owned keys before the migration
+	Scanned int
+	// Keys copied to their current owners and removed from the node they were found in
+	Moved int
+	// Keys still owned by the node they were found in, or already stored in their current owners
+	Skipped int
+	// Keys that couldn't be moved, they are kept in the node they were found in
+	Failed int
+}
+
+// Keeps the current ring to read from its owners while migrating. Called holding mu.
+func (c *DCacheClient) recordRing() {
+	if c.opts.migration && len(c.dcring.Nodes()) != 0 {
+		c.prevRings = append([]ring.Ring{c.dcring.Clone()}, c.prevRings...)
+	}
+}
+
+// Whether reads fall back to the nodes that owned keys before the ring changed.
+func (c *DCacheClient) Migrating() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return len(c.prevRings) != 0
+}
+
+// Starts migrating from a cluster made of oldNodes, e.g. when the client starts with the new ring. Nodes not in
+// the ring are connected to and kept until the migration finishes.
+func (c *DCacheClient) MigrateFrom(oldNodes []string, retries uint, retryInterval time.Duration) *DCacheError {
+	conns := make(map[string]*dCacheConn)
+
+	c.mu.RLock()
+	// Same kind of ring as the current one
+	old := c.dcring.Clone()
+	for _, addr := range old.Nodes() {
+		old.Remove(addr)
+	}
+	for _, addr := range oldNodes {
+		old.Add(addr)
+		if c.nodeConn(addr) == nil {
+			conns[addr] = c.newConn(addr)
+		}
+	}
+	c.mu.RUnlock()
+
+	for _, dconn := range conns {
+		if err := dconn.establishConn(retries, retryInterval); err != nil {
+			for _, dconn := range conns {
+				dconn.close()
+			}
+			return err
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.done {
+		for _, dconn := range conns {
+			dconn.close()
+		}
+		return dCacheTerminatedClientError()
+	}
+
+	for addr, dconn := range conns {
+		if c.nodeConn(addr) != nil {
+			dconn.close()
+			continue
+		}
+		c.draining[addr] = dconn
+	}
+	c.prevRings = append(c.prevRings, old)
+
+	return nil
+}
+
+// Stops falling back to previous owners and closes the connections to removed nodes.
+func (c *DCacheClient) FinishMigration() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.finishMigration()
+}
+
+func (c *DCacheClient) finishMigration() {
+	for addr, dconn := range c.draining {
+		dconn.close()
+		delete(c.draining, addr)
+	}
+	c.prevRings = nil
+}
+
+// Nodes that owned the key in previous rings and don't own it now. Called holding mu.
+func (c *DCacheClient) previousOwners(key string) []string {
+	seen := make(map[string]bool)
+	for _, addr := range c.dcring.GetN(key, c.opts.replicas) {
+		seen[addr] = true
+	}
+
+	owners := make([]string, 0)
+	for _, prev := range c.prevRings {
+		for _, addr := range prev.GetN(key, c.opts.replicas) {
+			if !seen[addr] {
+				seen[addr] = true
+				owners = append(owners, addr)
+			}
+		}
+	}
+
+	return owners
+}
+
+// Reads the key from its previous owners, copying it to the current ones if the client copies forward.
+// Failing previous owners are treated as not having the key.
+func (c *DCacheClient) getPrevious(key string) ([]byte, bool) {
+	stored, ttl, found := c.dumpPrevious(key)
+	if !found {
+		return nil, false
+	}
+
+	if c.opts.copyForward {
+		c.copyForward(key, stored, ttl)
+	}
+
+	if c.isTombstone(stored) {
+		return nil, false
+	}
+	_, value := c.unversioned(stored)
+	return value, true
+}
+
+// Returns the freshest value stored for the key in its previous owners, as stored, and the TTL it has left.
+func (c *DCacheClient) dumpPrevious(key string) ([]byte, uint32, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var freshest []byte
+	var freshestVersion int64
+	var freshestTTL uint32
+	found := false
+	for _, addr := range c.previousOwners(key) {
+		// Connections that aren't active fail without being used
+		dconn := c.nodeConn(addr)
+		if dconn == nil {
+			continue
+		}
+
+		res, err := dconn.execCmd(command.DumpCmdAsBytes(key))
+		stored, ttl, ok := dumpResult(res, err)
+		if !ok {
+			continue
+		}
+
+		if version, _ := c.unversioned(stored); !found || version > freshestVersion {
+			freshest, freshestVersion, freshestTTL, found = stored, version, ttl, true
+		}
+	}
+
+	return freshest, freshestTTL, found
+}
+
+// Interprets a DUMP response, a failed DUMP means the key was not found.
+func dumpResult(res []byte, err *DCacheError) ([]byte, uint32, bool) {
+	if err != nil || len(res) < 5 || res[0] != core.CMD_EXEC_SUCCEEDED {
+		return nil, 0, false
+	}
+
+	return res[5:], binary.LittleEndian.Uint32(res[1:5]), true
+}
+
+// Stores the key, as stored in a previous owner, in the current owners that don't have it, so it doesn't
+// overwrite newer writes. Returns whether it was stored anywhere.
+func (c *DCacheClient) copyForward(key string, stored []byte, ttl uint32) (bool, *DCacheError) {
+	results, err := c.execReplicated(command.HasCmdAsBytes(key), key, true)
+	if err != nil {
+		return false, err
+	}
+
+	failed := make(map[string]*DCacheError)
+	copied := false
+	for _, r := range results {
+		found, err := false, r.err
+		if err == nil {
+			found, err = hasResult(key, r.res)
+		}
+		if err == nil && !found {
+			var res []byte
+			if res, err = c.execCmdOnNode(command.SetCmdAsBytes(key, stored, ttl), r.addr); err == nil {
+				err = setResult(key, res)
+			}
+			copied = copied || err == nil
+		}
+		if err != nil {
+			failed[r.addr] = err
+		}
+	}
+
+	if len(failed) != 0 {
+		return copied, dCacheReplicaFailedError("copy", key, failed)
+	}
+	return copied, nil
+}
+
+// Deletes the key from its previous owners so reads don't fall back to a deleted value. Best effort, previous
+// owners may be gone.
+func (c *DCacheClient) deletePrevious(key string) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, addr := range c.previousOwners(key) {
+		if dconn := c.nodeConn(addr); dconn != nil {
+			dconn.execCmd(command.DeleteCmdAsBytes(key))
+		}
+	}
+}
+
+// Moves every key stored in the nodes of previous rings that no longer own it to its current owners, and
+// finishes the migration if no key failed to move.
+//
+// Keys are copied only to owners that don't have them and removed from the old node once copied. Returns
+// early if a node can't be scanned, keys moved until then stay moved.
+func (c *DCacheClient) Rebalance() (RebalanceStats, *DCacheError) {
+	c.mu.RLock()
+	nodes := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, prev := range c.prevRings {
+		for _, addr := range prev.Nodes() {
+			if !seen[addr] {
+				seen[addr] = true
+				nodes = append(nodes, addr)
+			}
+		}
+	}
+	c.mu.RUnlock()
+
+	stats := RebalanceStats{}
+	for _, addr := range nodes {
+		if err := c.rebalanceNode(addr, &stats); err != nil {
+			return stats, err
+		}
+	}
+
+	if stats.Failed == 0 {
+		c.FinishMigration()
+	}
+
+	return stats, nil
+}
+
+func (c *DCacheClient) rebalanceNode(addr string, stats *RebalanceStats) *DCacheError {
+	after := ""
+	for {
+		res, err := c.execCmdOnNode(command.ScanCmdAsBytes(after, rebalanceScanCount), addr)
+		if err != nil {
+			return err
+		} else if len(res) == 0 {
+			return dCacheNodeCmdFailedError("scan", addr, "empty response")
+		} else if res[0] != core.CMD_EXEC_SUCCEEDED {
+			return dCacheNodeCmdFailedError("scan", addr, string(res[1:]))
+		}
+
+		keys, ok := scanResult(res[1:])
+		if !ok {
+			return dCacheNodeCmdFailedError("scan", addr, "invalid response")
+		} else if len(keys) == 0 {
+			return nil
+		}
+
+		for _, key := range keys {
+			stats.Scanned++
+			switch moved, err := c.moveKey(key, addr); {
+			case err != nil:
+				stats.Failed++
+			case moved:
+				stats.Moved++
+			default:
+				stats.Skipped++
+			}
+		}
+		after = keys[len(keys)-1]
+	}
+}
+
+// Decodes the keys of a SCAN response, each one prefixed by its length.
+func scanResult(data []byte) ([]string, bool) {
+	keys := make([]string, 0)
+	for len(data) != 0 {
+		n := int(data[0])
+		if 1+n > len(data) {
+			return nil, false
+		}
+
+		keys = append(keys, string(data[1:1+n]))
+		data = data[1+n:]
+	}
+
+	return keys, true
+}
+
+// Moves the key from addr to its current owners unless addr is one of them, returning whether it was copied.
+func (c *DCacheClient) moveKey(key, addr string) (bool, *DCacheError) {
+	for _, owner := range c.NodesFor(key) {
+		if owner == addr {
+			return false, nil
+		}
+	}
+
+	res, err := c.execCmdOnNode(command.DumpCmdAsBytes(key), addr)
+	if err != nil {
+		return false, err
+	}
+
+	stored, ttl, found := dumpResult(res, nil)
+	if !found {
+		// Expired or deleted since it was listed
+		return false, nil
+	}
+
+	copied, err := c.copyForward(key, stored, ttl)
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := c.execCmdOnNode(command.DeleteCmdAsBytes(key), addr); err != nil {
+		return false, err
+	}
+
+	return copied, nil
+}
@@ -0,0 +1,141 @@
+package client
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/joaovictorsl/dcache/core"
+)
+
+func TestMigration(t *testing.T) {
+	c := NewWithOptions(WithNodes(s1Addr, s2Addr), WithMigration(true))
+	if err := c.Connect(2, 2*time.Second); err != nil {
+		t.Fatalf("failed to connect: %s", err)
+	}
+	defer c.End()
+
+	raw := New(s1Addr, s2Addr, s3Addr)
+	if err := raw.Connect(2, 2*time.Second); err != nil {
+		t.Fatalf("failed to connect: %s", err)
+	}
+	defer raw.End()
+
+	keys := make([]string, 50)
+	oldOwners := make(map[string]string, len(keys))
+	for i := range keys {
+		keys[i] = fmt.Sprintf("migration-%d", i)
+		oldOwners[keys[i]], _ = c.NodeFor(keys[i])
+		if err := c.Set(keys[i], []byte(keys[i]), 60000); err != nil {
+			t.Fatalf("SET returned error %q", err)
+		}
+	}
+
+	if err := c.AddNode(s3Addr, 2, 2*time.Second); err != nil {
+		t.Fatalf("failed to add node: %s", err)
+	} else if !c.Migrating() {
+		t.Fatalf("expected adding a node to start a migration")
+	}
+
+	moved := make([]string, 0)
+	for _, key := range keys {
+		if owner, _ := c.NodeFor(key); owner != oldOwners[key] {
+			moved = append(moved, key)
+		}
+	}
+	if len(moved) == 0 {
+		t.Fatalf("expected some keys to move to %s", s3Addr)
+	}
+
+	t.Run("should read moved keys from their previous owner", func(t *testing.T) {
+		if found, err := c.Has(moved[0]); err != nil || !found {
+			t.Errorf("HAS %s = %v, %v, want true", moved[0], found, err)
+		}
+
+		v, found, err := c.Get(moved[0])
+		if err != nil || !found || string(v) != moved[0] {
+			t.Errorf("GET %s = %q, %v, %v, want %q", moved[0], v, found, err, moved[0])
+		}
+
+		if _, found := nodeGet(t, raw, s3Addr, moved[0]); !found {
+			t.Errorf("expected %s to be copied to %s", moved[0], s3Addr)
+		}
+	})
+
+	t.Run("should delete from previous owners", func(t *testing.T) {
+		if err := c.Delete(moved[1]); err != nil {
+			t.Fatalf("DELETE returned error %q", err)
+		}
+
+		if _, found, err := c.Get(moved[1]); err != nil || found {
+			t.Errorf("GET %s = %v, %v, want not found", moved[1], found, err)
+		}
+		if _, found := nodeGet(t, raw, oldOwners[moved[1]], moved[1]); found {
+			t.Errorf("expected %s to be deleted from %s", moved[1], oldOwners[moved[1]])
+		}
+	})
+
+	t.Run("should move keys to their current owners", func(t *testing.T) {
+		stats, err := c.Rebalance()
+		if err != nil {
+			t.Fatalf("Rebalance returned error %q", err)
+		} else if stats.Failed != 0 || stats.Moved < len(moved)-2 {
+			t.Errorf("expected at least %d keys moved and none failed, got %+v", len(moved)-2, stats)
+		}
+
+		if c.Migrating() {
+			t.Errorf("expected Rebalance to finish the migration")
+		}
+
+		for _, key := range moved[2:] {
+			if v, found := nodeGet(t, raw, s3Addr, key); !found || string(v) != key {
+				t.Errorf("expected %s in %s, got %q, %v", key, s3Addr, v, found)
+			}
+			if _, found := nodeGet(t, raw, oldOwners[key], key); found {
+				t.Errorf("expected %s to be removed from %s", key, oldOwners[key])
+			}
+		}
+
+		for _, key := range keys[2:] {
+			if key == moved[1] {
+				continue
+			}
+			if _, found, err := c.Get(key); err != nil || !found {
+				t.Errorf("GET %s = %v, %v, want found", key, found, err)
+			}
+		}
+	})
+
+	t.Run("should keep removed nodes until the migration finishes", func(t *testing.T) {
+		if err := c.Set("migration-removed", []byte("v"), 60000); err != nil {
+			t.Fatalf("SET returned error %q", err)
+		}
+
+		owner, _ := c.NodeFor("migration-removed")
+		c.RemoveNode(owner)
+		if v, found, err := c.Get("migration-removed"); err != nil || !found || string(v) != "v" {
+			t.Errorf("GET = %q, %v, %v, want v from removed node", v, found, err)
+		}
+
+		// The read copied it to its new owner
+		c.FinishMigration()
+		if v, found, err := c.Get("migration-removed"); err != nil || !found || string(v) != "v" {
+			t.Errorf("GET = %q, %v, %v, want v from its new owner", v, found, err)
+		}
+		if _, err := c.execCmdOnNode(nil, owner); err == nil {
+			t.Errorf("expected %s to be forgotten", owner)
+		}
+	})
+}
+
+func TestDumpResult(t *testing.T) {
+	for _, res := range [][]byte{nil, {core.CMD_EXEC_SUCCEEDED}, {core.CMD_EXEC_FAILED}} {
+		if _, _, ok := dumpResult(res, nil); ok {
+			t.Errorf("expected DUMP response %v not to be found", res)
+		}
+	}
+
+	if stored, ttl, ok := dumpResult([]byte{core.CMD_EXEC_SUCCEEDED, 10, 0, 0, 0, 'v'}, nil); !ok || ttl != 10 || string(stored) != "v" {
+		t.Errorf("expected v with a TTL of 10, got %q, %d, %t", stored, ttl, ok)
+	}
+}
@@ -0,0 +1,210 @@
+package client
+
+import (
+	"encoding/binary"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/joaovictorsl/fooche"
+	"github.com/joaovictorsl/fooche/evict"
+)
+
+// Lookups served by the near cache, see WithNearCache.
+type NearCacheStats struct {
+	// Gets and Hases answered by the near cache
+	Hits uint64
+	// Gets and Hases sent to the nodes because the near cache didn't have the key
+	Misses uint64
+	// Keys removed from the near cache because they were written or deleted
+	Invalidations uint64
+}
+
+// Size of the epoch stored before every value
+const nearEpochSize = 8
+
+// In-process cache of values read from the nodes. Methods do nothing on a nil nearCache, so callers don't
+// check whether the client has one.
+type nearCache struct {
+	// fooche caches update their eviction policy on reads holding a read lock, so reads are serialized too
+	mu    sync.Mutex
+	cache fooche.ICache
+	// How long values are kept
+	ttl time.Duration
+	// Incremented by every invalidation, so values read while a key was written aren't kept. Guarded by mu
+	gen uint64
+	// Only keys starting with one of them are kept when not empty, see WithTracking
+	prefixes []string
+	// Stored before every value, values of a previous epoch are discarded. Guarded by mu
+	epoch uint64
+	// Nodes whose invalidation stream is down, nothing is kept while there's any. Guarded by mu
+	paused int
+
+	hits          atomic.Uint64
+	misses        atomic.Uint64
+	invalidations atomic.Uint64
+}
+
+// Returns a fooche.ICache for WithNearCache keeping up to maxKeys values of up to maxValueLength bytes, evicting
+// the least recently used one when full. Expired values are removed every second.
+func NewNearCache(maxKeys, maxValueLength int) fooche.ICache {
+	// Values are stored with their epoch
+	return fooche.NewCleanIntervalBounded(time.Second, map[int]int{nearEpochSize + maxValueLength: maxKeys}, func(capacity int) evict.EvictionPolicy[string] {
+		return evict.NewLRU[string](capacity)
+	})
+}
+
+// Returns the value of key if it's in the near cache, counting a hit or a miss.
+func (n *nearCache) get(key string) ([]byte, bool) {
+	if n == nil {
+		return nil, false
+	}
+
+	n.mu.Lock()
+	value, found := n.lookup(key)
+	// The cache may reuse the memory it returned
+	value = append([]byte(nil), value...)
+	n.mu.Unlock()
+
+	if !found {
+		n.misses.Add(1)
+		return nil, false
+	}
+
+	n.hits.Add(1)
+	return value, true
+}
+
+func (n *nearCache) has(key string) bool {
+	if n == nil {
+		return false
+	}
+
+	n.mu.Lock()
+	_, found := n.lookup(key)
+	n.mu.Unlock()
+
+	if !found {
+		n.misses.Add(1)
+		return false
+	}
+
+	n.hits.Add(1)
+	return true
+}
+
+// Returns the value of key unless it's from a previous epoch, in which case it's removed. Called holding mu.
+func (n *nearCache) lookup(key string) ([]byte, bool) {
+	stored, err := n.cache.Get(key)
+	if err != nil {
+		return nil, false
+	} else if len(stored) < nearEpochSize || binary.LittleEndian.Uint64(stored) != n.epoch {
+		n.cache.Delete(key)
+		return nil, false
+	}
+
+	return stored[nearEpochSize:], true
+}
+
+// Returns the generation to pass to set for a read about to be sent to the nodes.
+func (n *nearCache) generation() uint64 {
+	if n == nil {
+		return 0
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.gen
+}
+
+// Keeps value, as read from the nodes, for the near cache's TTL unless something was invalidated since gen was
+// returned, as the value may be older than the write. Values the cache can't hold aren't kept, and neither
+// are values of keys the nodes wouldn't invalidate.
+func (n *nearCache) set(key string, value []byte, gen uint64) {
+	if n == nil || !n.keeps(key) {
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if gen == n.gen && n.paused == 0 {
+		stored := binary.LittleEndian.AppendUint64(make([]byte, 0, nearEpochSize+len(value)), n.epoch)
+		n.cache.Set(key, append(stored, value...), n.ttl)
+	}
+}
+
+func (n *nearCache) keeps(key string) bool {
+	if len(n.prefixes) == 0 {
+		return true
+	}
+
+	for _, prefix := range n.prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Removes key, which was written or deleted.
+func (n *nearCache) invalidate(key string) {
+	if n == nil {
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.gen++
+	if n.cache.Has(key) {
+		n.cache.Delete(key)
+		n.invalidations.Add(1)
+	}
+}
+
+// Stops keeping values and discards the ones kept, as a node's invalidation stream is down and they may have
+// changed without the client being told. Undone by resume.
+func (n *nearCache) pause() {
+	if n == nil {
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.paused++
+	n.epoch++
+	n.gen++
+}
+
+// Keeps values again once every paused stream resumed. Reads sent before aren't kept, the node may not
+// have tracked them.
+func (n *nearCache) resume() {
+	if n == nil {
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.paused--
+	n.gen++
+}
+
+// Returns how many lookups the near cache answered, separately from the stats of the nodes. Empty if the
+// client has no near cache, see WithNearCache.
+func (c *DCacheClient) NearCacheStats() NearCacheStats {
+	if c.near == nil {
+		return NearCacheStats{}
+	}
+
+	return NearCacheStats{
+		Hits:          c.near.hits.Load(),
+		Misses:        c.near.misses.Load(),
+		Invalidations: c.near.invalidations.Load(),
+	}
+}
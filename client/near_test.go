@@ -0,0 +1,99 @@
+package client
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNearCache(t *testing.T) {
+	c := NewWithOptions(WithNodes(s1Addr, s2Addr), WithNearCache(NewNearCache(100, 64), time.Minute))
+	other := New(s1Addr, s2Addr)
+	for _, client := range []*DCacheClient{c, other} {
+		if err := client.Connect(2, time.Second); err != nil {
+			t.Fatalf("no error was expected on connect, but got: %s", err)
+		}
+		defer client.End()
+	}
+
+	if err := c.Set("near", []byte("v1"), 60000); err != nil {
+		t.Fatalf("no error was expected on set, but got: %s", err)
+	}
+	for i := 0; i < 2; i++ {
+		if value, ok, err := c.Get("near"); err != nil || !ok || string(value) != "v1" {
+			t.Errorf("expected %q, got %q, %t, %v", "v1", value, ok, err)
+		}
+	}
+	if ok, err := c.Has("near"); err != nil || !ok {
+		t.Errorf("expected the key to be found, got %t, %v", ok, err)
+	}
+	if stats := c.NearCacheStats(); stats.Hits != 2 || stats.Misses != 1 {
+		t.Errorf("expected 2 hits and 1 miss, got %+v", stats)
+	}
+
+	// Writes of other clients are only seen once the value expires, writes of the client right away
+	other.Set("near", []byte("v2"), 60000)
+	if value, _, _ := c.Get("near"); string(value) != "v1" {
+		t.Errorf("expected the near cache to answer with %q, got %q", "v1", value)
+	}
+	c.Set("near", []byte("v3"), 60000)
+	if value, _, _ := c.Get("near"); string(value) != "v3" {
+		t.Errorf("expected %q after set, got %q", "v3", value)
+	}
+
+	p := c.Pipeline()
+	p.Set("near", []byte("v4"), 60000)
+	p.Exec()
+	if value, _, _ := c.Get("near"); string(value) != "v4" {
+		t.Errorf("expected %q after a pipelined set, got %q", "v4", value)
+	}
+
+	c.Delete("near")
+	if _, ok, _ := c.Get("near"); ok {
+		t.Errorf("expected the key to be deleted")
+	}
+	if ok, _ := c.Has("near"); ok {
+		t.Errorf("expected the key to be deleted")
+	}
+	if stats := c.NearCacheStats(); stats.Invalidations != 3 {
+		t.Errorf("expected 3 invalidations, got %+v", stats)
+	}
+
+	if stats := other.NearCacheStats(); stats != (NearCacheStats{}) {
+		t.Errorf("expected empty stats without a near cache, got %+v", stats)
+	}
+}
+
+func TestNearCacheExpiry(t *testing.T) {
+	c := NewWithOptions(WithNodes(s1Addr, s2Addr), WithNearCache(NewNearCache(100, 64), 50*time.Millisecond))
+	if err := c.Connect(2, time.Second); err != nil {
+		t.Fatalf("no error was expected on connect, but got: %s", err)
+	}
+	defer c.End()
+
+	c.Set("near-expiry", []byte("v1"), 60000)
+	expires := time.Now().Add(50 * time.Millisecond)
+	c.Get("near-expiry")
+	time.Sleep(time.Until(expires))
+	if value, _, _ := c.Get("near-expiry"); string(value) != "v1" {
+		t.Errorf("expected %q, got %q", "v1", value)
+	}
+	if stats := c.NearCacheStats(); stats.Hits != 0 || stats.Misses != 2 {
+		t.Errorf("expected expired values to be read from the nodes, got %+v", stats)
+	}
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if value, _, err := c.Get("near-expiry"); err != nil || string(value) != "v1" {
+					t.Errorf("expected %q, got %q, %v", "v1", value, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
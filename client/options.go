@@ -0,0 +1,256 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/joaovictorsl/dcache/client/ring"
+	"github.com/joaovictorsl/fooche"
+)
+
+type options struct {
+	nodes []string
+	// Weights of the nodes that have one, from 1 to ring.TopWeight. Guarded by the client's mu once it's created
+	weights   map[string]int
+	tlsConfig *tls.Config
+	// Credentials sent with AUTH on every connection, not sent if secret is empty
+	username string
+	secret   string
+	// Nodes each key is stored in, acknowledgments a write waits for and nodes a read queries
+	replicas     int
+	writeAcks    int
+	readReplicas int
+	// Whether ring changes start a migration, and if values found in previous owners are copied to the current ones
+	migration   bool
+	copyForward bool
+	// Interval in which the ring is refreshed from CLUSTER NODES, 0 disables it
+	refreshInterval time.Duration
+	// Finds the nodes of the ring every discoveryInterval, nil if nodes are only given by the user
+	discovery         Discovery
+	discoveryInterval time.Duration
+	// How long GetOrLoad locks keys on the server while loading them, 0 disables locking
+	loadLockTTL time.Duration
+	// How long GetOrLoad remembers keys the loader didn't find, 0 disables it
+	negativeTTL time.Duration
+	// Keeps values read for nearTTL in the process, nil disables it
+	nearCache fooche.ICache
+	nearTTL   time.Duration
+	// Whether nodes send the keys to remove from the near cache, every key starting with trackingPrefixes
+	// if there's any or the keys read otherwise
+	tracking         bool
+	trackingPrefixes []string
+	// Biggest response read from a node
+	maxResponseSize uint32
+	// Maps keys to nodes, a ring.ConsistentHash when not given. Loads are bounded if it's a ConsistentHash with a load factor
+	ring ring.Ring
+}
+
+// Option configures a DCacheClient created by NewWithOptions.
+type Option func(*options)
+
+// Adds nodes to the ring, connections to them are established by Connect.
+func WithNodes(addrs ...string) Option {
+	return func(o *options) {
+		o.nodes = append(o.nodes, addrs...)
+	}
+}
+
+// Adds nodes to the ring like WithNodes, each one owning a share of the keys proportional to its weight, from 1
+// to ring.TopWeight, e.g. 100 for a node with twice the memory of nodes weighing 50. Nodes added with WithNodes
+// weigh ring.TopWeight. Weights are clamped to that range and ignored, with a log message, by rings that
+// aren't ring.Weighted.
+func WithWeightedNodes(weights map[string]int) Option {
+	return func(o *options) {
+		addrs := make([]string, 0, len(weights))
+		for addr := range weights {
+			addrs = append(addrs, addr)
+		}
+		// Same order for every client, some rings depend on it
+		sort.Strings(addrs)
+
+		for _, addr := range addrs {
+			o.nodes = append(o.nodes, addr)
+			o.weights[addr] = clamp(weights[addr], 1, ring.TopWeight)
+		}
+	}
+}
+
+// Connects to nodes using TLS. If cfg has no ServerName, the host of each node address is used.
+func WithTLS(cfg *tls.Config) Option {
+	return func(o *options) {
+		o.tlsConfig = cfg
+	}
+}
+
+// Authenticates every connection, including reconnections, with the given credentials.
+// An empty username authenticates as the server's default user.
+func WithAuth(username, secret string) Option {
+	return func(o *options) {
+		o.username = username
+		o.secret = secret
+	}
+}
+
+// Maps keys to nodes with r instead of a ring.ConsistentHash, e.g. ring.NewRendezvous(). r must be empty and
+// not used by anything else. Every client of the cluster must use the same kind of ring, and servers
+// redirecting commands with MOVED only use ring.ConsistentHash.
+func WithRing(r ring.Ring) Option {
+	return func(o *options) {
+		o.ring = r
+	}
+}
+
+// Maps keys to nodes with a ring.ConsistentHash bounding loads to loadFactor times the average, replacing the ring
+// given to WithRing. The load of a node is the commands in flight to it, and commands on keys whose owner is at
+// capacity are sent to the next node in the ring, see ring.NewBoundedConsistentHash. LoadStats tells how often
+// that happens.
+//
+// Reads of a key that spilled only find it while they spill the same way, and a delete only removes it from the
+// node it's sent to, so spilled keys may be read stale until they expire.
+func WithBoundedLoads(loadFactor float64) Option {
+	return func(o *options) {
+		o.ring = ring.NewBoundedConsistentHash(loadFactor)
+	}
+}
+
+// Stores every key in n nodes, the next ones clockwise in the ring. Writes are sent to all of them and succeed
+// once w acknowledge, reads query r of them and return the value written last. w and r are capped at n, and
+// choosing w + r > n makes reads see the latest successful write.
+//
+// Values are stored with the time they were written prepended as 8 bytes, so every client of the cluster must
+// use the same replication factor and node clocks should be in sync.
+func WithReplication(n, w, r int) Option {
+	return func(o *options) {
+		o.replicas = clamp(n, 1, n)
+		o.writeAcks = clamp(w, 1, o.replicas)
+		o.readReplicas = clamp(r, 1, o.replicas)
+	}
+}
+
+// Keeps the previous ring whenever AddNode or RemoveNode change it, until FinishMigration is called. Keys not
+// found in their current owners are then read from the nodes that owned them before, and removed nodes stay
+// connected so they can still be read from. With copyForward values found that way are also stored in their
+// current owners, with the TTL they have left.
+//
+// Rebalance moves every key to its current owners and finishes the migration.
+func WithMigration(copyForward bool) Option {
+	return func(o *options) {
+		o.migration = true
+		o.copyForward = copyForward
+	}
+}
+
+// Refreshes the ring from the cluster members every interval once Connect succeeds, see RefreshRing. The
+// nodes given to WithNodes only need to include one member of the cluster.
+func WithRingRefresh(interval time.Duration) Option {
+	return func(o *options) {
+		o.refreshInterval = interval
+	}
+}
+
+// Finds the nodes of the ring with d, e.g. NewSRVDiscovery or NewFileDiscovery, once when Connect is called and
+// then every interval, see DiscoverNodes. Nodes given to WithNodes are kept until the first discovery succeeds.
+func WithDiscovery(d Discovery, interval time.Duration) Option {
+	return func(o *options) {
+		o.discovery = d
+		o.discoveryInterval = interval
+	}
+}
+
+// Makes GetOrLoad lock a key on the server while loading it, for up to ttl, so processes loading the same key
+// wait for the one holding the lock instead of loading it too. ttl should be longer than a load takes: once
+// it passes waiting processes load the key themselves. Locks are keys, they only expire on nodes whose cache
+// expires keys.
+func WithLoadLock(ttl time.Duration) Option {
+	return func(o *options) {
+		o.loadLockTTL = ttl
+	}
+}
+
+// Makes GetOrLoad remember for ttl the keys the loader didn't find, returning them as not found without
+// loading them again.
+func WithNegativeCaching(ttl time.Duration) Option {
+	return func(o *options) {
+		o.negativeTTL = ttl
+	}
+}
+
+// Keeps values read with Get in cache, e.g. NewNearCache(10000, 1024), for ttl, answering Get and Has for those
+// keys without reaching the nodes. Set, Add and Delete, pipelined or not, remove the key from cache, but writes
+// made by other clients are only seen once ttl passes, so ttl should be short unless WithTracking is used.
+// cache must expire keys, and NearCacheStats tells how many lookups it answered.
+func WithNearCache(cache fooche.ICache, ttl time.Duration) Option {
+	return func(o *options) {
+		o.nearCache = cache
+		o.nearTTL = ttl
+	}
+}
+
+// Makes every node tell the client which keys to remove from the near cache given to WithNearCache as they're
+// written by any client, expire or are evicted, over a second connection, so ttl no longer bounds how stale
+// values are. Without prefixes nodes remember the keys the client reads, with them they send every change to
+// keys starting with one of the prefixes and only those keys are kept in the near cache.
+//
+// Nothing is kept while a node's invalidation stream is down, which discards the values kept, and it's
+// opened again every second. Nodes must have tracking enabled, and users need the read category. Ignored
+// without a near cache.
+func WithTracking(prefixes ...string) Option {
+	return func(o *options) {
+		o.tracking = true
+		o.trackingPrefixes = prefixes
+	}
+}
+
+// Biggest response read from a node unless WithMaxResponseSize is used
+const defaultMaxResponseSize = 64 * 1024 * 1024
+
+// Sets the biggest response read from a node, 64 MiB by default. It must fit the biggest value stored, as
+// commands getting bigger responses fail.
+func WithMaxResponseSize(n uint32) Option {
+	return func(o *options) {
+		o.maxResponseSize = n
+	}
+}
+
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	} else if v > hi {
+		return hi
+	}
+	return v
+}
+
+// Builds a TLS configuration for WithTLS.
+//
+// Node certificates are verified against the CAs in caFile, or against the system CAs if it's empty.
+// certFile and keyFile hold the certificate sent to nodes that require one, both may be empty.
+func NewTLSConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+	}
+
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
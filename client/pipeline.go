@@ -0,0 +1,198 @@
+package client
+
+import (
+	"sync"
+
+	"github.com/joaovictorsl/dcache/core/command"
+)
+
+// Pipeline queues commands and sends them on Exec with a single round trip per node.
+//
+// A Pipeline is not safe for concurrent use, but many pipelines can be used concurrently.
+type Pipeline struct {
+	c   *DCacheClient
+	ops []pipelineOp
+}
+
+type pipelineOp struct {
+	key   string
+	cmd   []byte
+	write bool
+	// Fills the result based on the responses of the key's replicas
+	merge func(results []replicaResult, r *PipelineResult)
+	// Completes the result using previous owners while migrating, nil if not needed
+	fallback func(r *PipelineResult)
+}
+
+// Result of a pipelined command.
+type PipelineResult struct {
+	Key string
+	// Node the command was sent to, the first replica when keys are replicated. Empty if no node was found for the key
+	Node string
+	// Value returned by a GET
+	Value []byte
+	// Wether the key was found by a GET or HAS
+	Found bool
+	Err   *DCacheError
+}
+
+// Creates an empty pipeline.
+func (c *DCacheClient) Pipeline() *Pipeline {
+	return &Pipeline{c: c}
+}
+
+// Amount of queued commands.
+func (p *Pipeline) Len() int {
+	return len(p.ops)
+}
+
+func (p *Pipeline) Set(key string, value []byte, ttl uint32) {
+	p.queue(key, command.SetCmdAsBytes(key, p.c.versioned(value), ttl), true, func(results []replicaResult, r *PipelineResult) {
+		r.Err = p.c.setReplicas(key, results)
+	}, nil)
+}
+
+func (p *Pipeline) Get(key string) {
+	p.queue(key, command.GetCmdAsBytes(key), false, func(results []replicaResult, r *PipelineResult) {
+		r.Value, r.Found, r.Err = p.c.getReplicas(key, results)
+	}, func(r *PipelineResult) {
+		if !r.Found && quorumReached(r.Err) {
+			r.Value, r.Found = p.c.getPrevious(key)
+		}
+	})
+}
+
+func (p *Pipeline) Has(key string) {
+	fallback := func(r *PipelineResult) {
+		if !r.Found && quorumReached(r.Err) {
+			_, r.Found = p.c.getPrevious(key)
+		}
+	}
+
+	if p.c.replicated() {
+		// Values are read to tell tombstones apart
+		p.queue(key, command.GetCmdAsBytes(key), false, func(results []replicaResult, r *PipelineResult) {
+			_, r.Found, r.Err = p.c.getReplicas(key, results)
+		}, fallback)
+		return
+	}
+
+	p.queue(key, command.HasCmdAsBytes(key), false, func(results []replicaResult, r *PipelineResult) {
+		r.Found, r.Err = p.c.hasReplicas(key, results)
+	}, fallback)
+}
+
+func (p *Pipeline) Delete(key string) {
+	p.queue(key, p.c.deleteCmd(key), true, func(results []replicaResult, r *PipelineResult) {
+		r.Err = p.c.deleteReplicas(key, results)
+	}, func(r *PipelineResult) {
+		if r.Node != "" {
+			p.c.deletePrevious(key)
+		}
+	})
+}
+
+func (p *Pipeline) queue(key string, cmd []byte, write bool, merge func(results []replicaResult, r *PipelineResult), fallback func(r *PipelineResult)) {
+	p.ops = append(p.ops, pipelineOp{key: key, cmd: cmd, write: write, merge: merge, fallback: fallback})
+}
+
+// Sends all queued commands and empties the pipeline.
+//
+// Commands are grouped by node and each group is sent concurrently, results are returned in the order
+// commands were queued. Commands sent to the same node run in order. When keys are replicated, commands
+// are sent to the same replicas and acknowledged the same way as outside a pipeline.
+//
+// While migrating, keys not found are then read from their previous owners one by one. Reads are always sent to
+// the nodes, bypassing the near cache.
+func (p *Pipeline) Exec() []PipelineResult {
+	ops := p.ops
+	p.ops = nil
+
+	results := make([]PipelineResult, len(ops))
+	for i, op := range ops {
+		results[i].Key = op.key
+	}
+
+	replies := p.send(ops, results)
+	for _, op := range ops {
+		if op.write {
+			p.c.near.invalidate(op.key)
+		}
+	}
+	for i, op := range ops {
+		if replies[i] != nil {
+			p.c.followMoved(op.cmd, replies[i])
+			op.merge(replies[i], &results[i])
+		}
+	}
+
+	if p.c.Migrating() {
+		for i, op := range ops {
+			if op.fallback != nil {
+				op.fallback(&results[i])
+			}
+		}
+	}
+
+	return results
+}
+
+// Sends ops to their replicas, returning the responses of every replica by command index. Commands that
+// can't be sent get an error in results.
+func (p *Pipeline) send(ops []pipelineOp, results []PipelineResult) [][]replicaResult {
+	// Read locking due to use of c.conns
+	p.c.mu.RLock()
+	defer p.c.mu.RUnlock()
+
+	if p.c.done {
+		for i := range results {
+			results[i].Err = dCacheTerminatedClientError()
+		}
+		return make([][]replicaResult, len(ops))
+	}
+
+	// Responses of every replica a command was sent to, by command index
+	replies := make([][]replicaResult, len(ops))
+	// Maps node address to the commands sent to it, as command and replica indexes
+	groups := make(map[string][][2]int)
+	for i, op := range ops {
+		replicas := p.c.replicasFor(op.key, op.write)
+		if len(replicas) == 0 {
+			results[i].Err = dCacheConnNotFoundError(op.key)
+			continue
+		}
+
+		results[i].Node = replicas[0]
+		replies[i] = make([]replicaResult, len(replicas))
+		for j, addr := range replicas {
+			groups[addr] = append(groups[addr], [2]int{i, j})
+		}
+	}
+
+	wg := &sync.WaitGroup{}
+	for addr, targets := range groups {
+		wg.Add(1)
+		go func(dconn *dCacheConn, targets [][2]int) {
+			defer wg.Done()
+
+			cmds := make([][]byte, len(targets))
+			for i, target := range targets {
+				cmds[i] = ops[target[0]].cmd
+			}
+
+			for range cmds {
+				p.c.loadInc(dconn.addr)
+			}
+			res, errs := dconn.execPipeline(cmds)
+			for range cmds {
+				p.c.loadDone(dconn.addr)
+			}
+			for i, target := range targets {
+				replies[target[0]][target[1]] = replicaResult{addr: dconn.addr, res: res[i], err: errs[i]}
+			}
+		}(p.c.conns[addr], targets)
+	}
+	wg.Wait()
+
+	return replies
+}
@@ -0,0 +1,132 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joaovictorsl/dcache"
+)
+
+func TestPipeline(t *testing.T) {
+	c := New(s1Addr, s2Addr, s3Addr, s4Addr, s5Addr)
+	if err := c.Connect(2, 2*time.Second); err != nil {
+		t.Fatalf("failed to connect: %s", err)
+	}
+	defer c.End()
+
+	p := c.Pipeline()
+	for i := 0; i < 50; i++ {
+		p.Set(fmt.Sprintf("pipeline-%d", i), []byte(fmt.Sprintf("value-%d", i)), 10000)
+	}
+
+	if p.Len() != 50 {
+		t.Fatalf("expected 50 queued commands, got %d", p.Len())
+	}
+
+	for _, r := range p.Exec() {
+		if r.Err != nil {
+			t.Errorf("no error was expected on pipelined SET of %s, but got: %s", r.Key, r.Err)
+		}
+	}
+
+	if p.Len() != 0 {
+		t.Fatalf("expected pipeline to be empty after Exec, got %d commands", p.Len())
+	}
+
+	p.Get("pipeline-0")
+	p.Delete("pipeline-1")
+	p.Has("pipeline-1")
+	p.Get("pipeline-missing")
+	results := p.Exec()
+
+	if r := results[0]; r.Err != nil || !r.Found || !bytes.Equal(r.Value, []byte("value-0")) {
+		t.Errorf("expected GET pipeline-0 to return value-0, got %+v", r)
+	}
+
+	if owner, _ := c.NodeFor("pipeline-0"); results[0].Node != owner {
+		t.Errorf("expected GET pipeline-0 to be sent to %s, got %s", owner, results[0].Node)
+	}
+
+	if r := results[1]; r.Err != nil {
+		t.Errorf("no error was expected on pipelined DELETE, but got: %s", r.Err)
+	}
+
+	if r := results[2]; r.Err != nil || r.Found {
+		t.Errorf("expected HAS pipeline-1 to be false after DELETE, got %+v", r)
+	}
+
+	if r := results[3]; r.Err != nil || r.Found {
+		t.Errorf("expected GET pipeline-missing to not be found, got %+v", r)
+	}
+}
+
+func TestPipelineNoPermission(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "acl.yaml")
+	acl := "users: [{name: orders, passwords: [orders-secret], categories: [read, write], keys: ['orders:*']}]"
+	if err := os.WriteFile(path, []byte(acl), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	s := dcache.NewServerWithOptions(dcache.WithPort(0), dcache.WithACLFile(path), dcache.WithLogLevel(dcache.LOG_NONE))
+	addr := startTestServer(s)
+	defer s.Shutdown(context.Background())
+
+	c := NewWithOptions(WithNodes(addr), WithAuth("orders", "orders-secret"))
+	if err := c.Connect(2, 100*time.Millisecond); err != nil {
+		t.Fatalf("failed to connect: %s", err)
+	}
+	defer c.End()
+
+	c.Set("orders:1", []byte("one"), 10000)
+	c.Set("orders:2", []byte("two"), 10000)
+
+	p := c.Pipeline()
+	p.Get("carts:1")
+	p.Get("orders:1")
+	results := p.Exec()
+
+	if r := results[0]; r.Err == nil || r.Err.Code() != NO_PERMISSION {
+		t.Errorf("expected GET carts:1 to be denied, got %+v", r)
+	}
+	if r := results[1]; r.Err != nil || string(r.Value) != "one" {
+		t.Errorf("expected GET orders:1 to return one, got %+v", r)
+	}
+
+	// Commands sent after the pipeline read their own responses
+	if v, _, err := c.Get("orders:2"); err != nil || string(v) != "two" {
+		t.Errorf("expected GET orders:2 to return two, got %q, %v", v, err)
+	}
+}
+
+func TestPipelineLarge(t *testing.T) {
+	c := New(s1Addr)
+	if err := c.Connect(2, 2*time.Second); err != nil {
+		t.Fatalf("failed to connect: %s", err)
+	}
+	defer c.End()
+
+	// Both requests and responses are larger than the socket buffers
+	key := "pipeline-large-" + strings.Repeat("k", 200)
+	value := bytes.Repeat([]byte("v"), 900)
+	if err := c.Set(key, value, 60000); err != nil {
+		t.Fatalf("no error was expected on SET, but got: %s", err)
+	}
+	defer c.Delete(key)
+
+	p := c.Pipeline()
+	for i := 0; i < 40000; i++ {
+		p.Get(key)
+	}
+
+	for _, r := range p.Exec() {
+		if r.Err != nil || !bytes.Equal(r.Value, value) {
+			t.Fatalf("expected GET to return the value, got %+v", r.Err)
+		}
+	}
+}
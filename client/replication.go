@@ -0,0 +1,278 @@
+package client
+
+import (
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"github.com/joaovictorsl/dcache/core"
+	"github.com/joaovictorsl/dcache/core/command"
+)
+
+const (
+	// Size of the version stored before values when keys are replicated
+	versionSize = 8
+	// Set in the version of tombstones, versions of values are positive timestamps so it's never set in them
+	tombstoneFlag = 1 << 63
+	// TTL of tombstones in milliseconds, a replica that missed a delete returns the key again once it expires
+	tombstoneTTL uint32 = 60 * 60 * 1000
+)
+
+// Response of a node to a command sent to the owners of a key.
+type replicaResult struct {
+	addr string
+	res  []byte
+	err  *DCacheError
+}
+
+// Whether keys are stored in more than one node, in which case values carry a version.
+func (c *DCacheClient) replicated() bool {
+	return c.opts.replicas > 1
+}
+
+// Prepends the version to value, the time it was written, so reads can tell which replica is the freshest.
+func (c *DCacheClient) versioned(value []byte) []byte {
+	if !c.replicated() {
+		return value
+	}
+
+	stored := make([]byte, versionSize, versionSize+len(value))
+	binary.LittleEndian.PutUint64(stored, uint64(time.Now().UnixNano()))
+	return append(stored, value...)
+}
+
+// Splits a stored value into its version and the value written by the user.
+func (c *DCacheClient) unversioned(stored []byte) (int64, []byte) {
+	if !c.replicated() || len(stored) < versionSize {
+		return 0, stored
+	}
+
+	return int64(binary.LittleEndian.Uint64(stored) &^ tombstoneFlag), stored[versionSize:]
+}
+
+// Command deleting key. When keys are replicated it stores a tombstone, a version without a value, so reads
+// reaching a replica that missed the delete see that the key was deleted after it was written there.
+func (c *DCacheClient) deleteCmd(key string) []byte {
+	if !c.replicated() {
+		return command.DeleteCmdAsBytes(key)
+	}
+
+	stored := make([]byte, versionSize)
+	binary.LittleEndian.PutUint64(stored, uint64(time.Now().UnixNano())|tombstoneFlag)
+	return command.SetCmdAsBytes(key, stored, tombstoneTTL)
+}
+
+// Whether a stored value is a tombstone left by Delete.
+func (c *DCacheClient) isTombstone(stored []byte) bool {
+	return c.replicated() && len(stored) == versionSize && binary.LittleEndian.Uint64(stored)&tombstoneFlag != 0
+}
+
+// Nodes a command on key is sent to: every owner for writes, and for reads the first R owners
+// preferring active ones.
+func (c *DCacheClient) replicasFor(key string, write bool) []string {
+	owners := c.owners(key)
+	if write || len(owners) <= c.opts.readReplicas {
+		return owners
+	}
+
+	replicas := make([]string, 0, len(owners))
+	for _, addr := range owners {
+		if c.conns[addr].active {
+			replicas = append(replicas, addr)
+		}
+	}
+	for _, addr := range owners {
+		if !c.conns[addr].active {
+			replicas = append(replicas, addr)
+		}
+	}
+
+	return replicas[:c.opts.readReplicas]
+}
+
+// Amount of replicas that must acknowledge a command, capped by the replicas it was sent to.
+func (c *DCacheClient) quorum(write bool, replicas int) int {
+	need := c.opts.readReplicas
+	if write {
+		need = c.opts.writeAcks
+	}
+
+	if need > replicas {
+		return replicas
+	}
+	return need
+}
+
+// Executes a command in the replicas of the given key, concurrently when there's more than one, following
+// MOVED responses.
+func (c *DCacheClient) execReplicated(cmd []byte, key string, write bool) ([]replicaResult, *DCacheError) {
+	results, err := c.execOnReplicas(cmd, key, write)
+	if err != nil {
+		return nil, err
+	}
+
+	c.followMoved(cmd, results)
+	return results, nil
+}
+
+func (c *DCacheClient) execOnReplicas(cmd []byte, key string, write bool) ([]replicaResult, *DCacheError) {
+	// Read locking due to use of c.conns
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.done {
+		return nil, dCacheTerminatedClientError()
+	}
+
+	replicas := c.replicasFor(key, write)
+	if len(replicas) == 0 {
+		return nil, dCacheConnNotFoundError(key)
+	}
+
+	results := make([]replicaResult, len(replicas))
+	if len(replicas) == 1 {
+		results[0] = c.execOnReplica(cmd, replicas[0])
+		return results, nil
+	}
+
+	wg := &sync.WaitGroup{}
+	for i, addr := range replicas {
+		wg.Add(1)
+		go func(i int, addr string) {
+			defer wg.Done()
+			results[i] = c.execOnReplica(cmd, addr)
+		}(i, addr)
+	}
+	wg.Wait()
+
+	return results, nil
+}
+
+func (c *DCacheClient) execOnReplica(cmd []byte, addr string) replicaResult {
+	dconn := c.conns[addr]
+	if !dconn.active {
+		return replicaResult{addr: addr, err: dCacheNotActiveConnError(addr)}
+	}
+
+	c.loadInc(addr)
+	res, err := dconn.execCmd(cmd)
+	c.loadDone(addr)
+	return replicaResult{addr: addr, res: res, err: err}
+}
+
+// Checks that at least need replicas acknowledged a command, ack interprets a response and returns nil if it counts.
+//
+// If the quorum is reached but some replicas failed, an error with code REPLICA_FAILED is returned. Commands sent
+// to a single node fail with that node's error.
+func replicaQuorum(cmd, key string, results []replicaResult, need int, ack func(res []byte) *DCacheError) *DCacheError {
+	failed := make(map[string]*DCacheError)
+	for _, r := range results {
+		err := r.err
+		if err == nil {
+			err = ack(r.res)
+		}
+		if err != nil {
+			failed[r.addr] = err
+		}
+	}
+
+	if len(results) == 1 {
+		return failed[results[0].addr]
+	}
+
+	acks := len(results) - len(failed)
+	switch {
+	case acks < need:
+		return dCacheQuorumFailedError(cmd, key, acks, need, failed)
+	case len(failed) != 0:
+		return dCacheReplicaFailedError(cmd, key, failed)
+	}
+
+	return nil
+}
+
+// Whether err still lets the command's result be used.
+func quorumReached(err *DCacheError) bool {
+	return err == nil || err.code == REPLICA_FAILED
+}
+
+// An owner that already has the key acknowledges an ADD without adding it.
+func (c *DCacheClient) addReplicas(key string, results []replicaResult) (bool, *DCacheError) {
+	need := c.quorum(true, len(results))
+	added := 0
+	err := replicaQuorum("add", key, results, need, func(res []byte) *DCacheError {
+		if len(res) == 0 {
+			return dCacheInvalidCmdError("add", key)
+		}
+
+		switch res[0] {
+		case core.CMD_EXEC_SUCCEEDED:
+			added++
+			return nil
+		case core.CMD_EXEC_FAILED:
+			return nil
+		default:
+			return dCacheInvalidCmdError("add", key)
+		}
+	})
+
+	return added >= need && quorumReached(err), err
+}
+
+func (c *DCacheClient) setReplicas(key string, results []replicaResult) *DCacheError {
+	return replicaQuorum("set", key, results, c.quorum(true, len(results)), func(res []byte) *DCacheError {
+		return setResult(key, res)
+	})
+}
+
+// Interprets the responses to deleteCmd.
+func (c *DCacheClient) deleteReplicas(key string, results []replicaResult) *DCacheError {
+	return replicaQuorum("delete", key, results, c.quorum(true, len(results)), func(res []byte) *DCacheError {
+		if c.replicated() {
+			return setResult(key, res)
+		}
+		return deleteResult(key, res)
+	})
+}
+
+// Returns the value with the newest version among the replicas that have the key, not found if it's a
+// tombstone.
+func (c *DCacheClient) getReplicas(key string, results []replicaResult) ([]byte, bool, *DCacheError) {
+	var freshest []byte
+	var freshestVersion int64
+	found, deleted := false, false
+
+	err := replicaQuorum("get", key, results, c.quorum(false, len(results)), func(res []byte) *DCacheError {
+		stored, ok, err := getResult(key, res)
+		if err != nil || !ok {
+			return err
+		}
+
+		version, value := c.unversioned(stored)
+		if !found || version > freshestVersion {
+			freshest, freshestVersion, found, deleted = value, version, true, c.isTombstone(stored)
+		}
+		return nil
+	})
+	if !quorumReached(err) || deleted {
+		return nil, false, err
+	}
+
+	return freshest, found, err
+}
+
+// The key is found if any replica has it. Not used when keys are replicated, as HAS doesn't tell
+// tombstones apart.
+func (c *DCacheClient) hasReplicas(key string, results []replicaResult) (bool, *DCacheError) {
+	found := false
+	err := replicaQuorum("has", key, results, c.quorum(false, len(results)), func(res []byte) *DCacheError {
+		ok, err := hasResult(key, res)
+		found = found || ok
+		return err
+	})
+	if !quorumReached(err) {
+		return false, err
+	}
+
+	return found, err
+}
@@ -0,0 +1,148 @@
+package client
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/joaovictorsl/dcache/core/command"
+)
+
+// Reads the value a single node stores for key, including its version.
+func nodeGet(t *testing.T, c *DCacheClient, addr, key string) ([]byte, bool) {
+	res, err := c.execCmdOnNode(command.GetCmdAsBytes(key), addr)
+	if err != nil {
+		t.Fatalf("GET on %s returned error %q", addr, err)
+	}
+
+	v, found, _ := getResult(key, res)
+	return v, found
+}
+
+func TestReplication(t *testing.T) {
+	nodes := []string{s1Addr, s2Addr, s3Addr, s4Addr, s5Addr}
+	c := NewWithOptions(WithNodes(nodes...), WithReplication(3, 2, 2))
+	if err := c.Connect(2, 2*time.Second); err != nil {
+		t.Fatalf("failed to connect: %s", err)
+	}
+	defer c.End()
+
+	// Reads what a single node stores, without replication
+	raw := New(nodes...)
+	if err := raw.Connect(2, 2*time.Second); err != nil {
+		t.Fatalf("failed to connect: %s", err)
+	}
+	defer raw.End()
+
+	owners := c.NodesFor("replicated")
+	if len(owners) != 3 {
+		t.Fatalf("expected 3 owners, got %v", owners)
+	}
+
+	t.Run("should write to every owner", func(t *testing.T) {
+		if err := c.Set("replicated", []byte("v1"), 10000); err != nil {
+			t.Fatalf("SET returned error %q", err)
+		}
+
+		for _, addr := range nodes {
+			stored, found := nodeGet(t, raw, addr, "replicated")
+			isOwner := addr == owners[0] || addr == owners[1] || addr == owners[2]
+			if found != isOwner {
+				t.Errorf("expected key in %s to be %v, got %v", addr, isOwner, found)
+			} else if found && !bytes.Equal(stored[versionSize:], []byte("v1")) {
+				t.Errorf("expected %s to store v1 after its version, got %q", addr, stored)
+			}
+		}
+	})
+
+	t.Run("should read the freshest value", func(t *testing.T) {
+		// The first owner misses a newer write
+		stale := make([]byte, versionSize, versionSize+2)
+		binary.LittleEndian.PutUint64(stale, 1)
+		if _, err := raw.execCmdOnNode(command.SetCmdAsBytes("replicated", append(stale, "v0"...), 10000), owners[0]); err != nil {
+			t.Fatalf("SET on %s returned error %q", owners[0], err)
+		}
+
+		for i := 0; i < 10; i++ {
+			v, found, err := c.Get("replicated")
+			if err != nil || !found || string(v) != "v1" {
+				t.Fatalf("GET = %q, %v, %v, want v1", v, found, err)
+			}
+		}
+	})
+
+	t.Run("should report replicas that failed", func(t *testing.T) {
+		down := c.conns[owners[1]]
+		down.active = false
+		defer func() { down.active = true }()
+
+		err := c.Set("replicated", []byte("v2"), 10000)
+		if err == nil || err.Code() != REPLICA_FAILED {
+			t.Fatalf("expected SET to fail on a replica, got %v", err)
+		} else if _, ok := err.NodeErrors()[owners[1]]; !ok || len(err.NodeErrors()) != 1 {
+			t.Errorf("expected only %s to fail, got %v", owners[1], err.NodeErrors())
+		}
+
+		if v, found, err := c.Get("replicated"); string(v) != "v2" || !found || (err != nil && err.Code() != REPLICA_FAILED) {
+			t.Errorf("GET = %q, %v, %v, want v2", v, found, err)
+		}
+
+		strict := NewWithOptions(WithNodes(nodes...), WithReplication(3, 3, 3))
+		strict.conns, strict.dcring = c.conns, c.dcring
+		if err := strict.Set("replicated", []byte("v3"), 10000); err == nil || err.Code() != QUORUM_FAILED {
+			t.Errorf("expected SET to miss its quorum, got %v", err)
+		}
+	})
+
+	t.Run("should not return keys deleted while a replica was down", func(t *testing.T) {
+		if err := c.Set("deleted", []byte("v1"), 10000); err != nil {
+			t.Fatalf("SET returned error %q", err)
+		}
+
+		owners := c.NodesFor("deleted")
+		down := c.conns[owners[0]]
+		down.active = false
+		if err := c.Delete("deleted"); err == nil || err.Code() != REPLICA_FAILED {
+			t.Fatalf("expected DELETE to fail on a replica, got %v", err)
+		}
+		down.active = true
+
+		if stored, found := nodeGet(t, raw, owners[1], "deleted"); !found || !c.isTombstone(stored) {
+			t.Errorf("expected %s to store a tombstone, got %q", owners[1], stored)
+		}
+		for i := 0; i < 10; i++ {
+			if v, found, err := c.Get("deleted"); found || err != nil {
+				t.Fatalf("GET = %q, %v, %v, want not found", v, found, err)
+			}
+			if found, err := c.Has("deleted"); found || err != nil {
+				t.Fatalf("HAS = %v, %v, want not found", found, err)
+			}
+		}
+
+		p := c.Pipeline()
+		p.Get("deleted")
+		p.Has("deleted")
+		for _, r := range p.Exec() {
+			if r.Found || r.Err != nil {
+				t.Errorf("expected pipelined read not to find the key, got %+v", r)
+			}
+		}
+	})
+
+	t.Run("should replicate pipelined commands", func(t *testing.T) {
+		p := c.Pipeline()
+		p.Set("pipelined", []byte("Foo"), 10000)
+		p.Get("pipelined")
+		results := p.Exec()
+		if results[0].Err != nil || results[1].Err != nil || string(results[1].Value) != "Foo" {
+			t.Fatalf("unexpected pipeline results %+v", results)
+		}
+
+		for _, addr := range c.NodesFor("pipelined") {
+			if _, found := nodeGet(t, raw, addr, "pipelined"); !found {
+				t.Errorf("expected pipelined key in %s", addr)
+			}
+		}
+	})
+}
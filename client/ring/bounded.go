@@ -0,0 +1,146 @@
+package ring
+
+import "math"
+
+// Load of the nodes of a ConsistentHash with bounded loads.
+type LoadStats struct {
+	// Lookups made by GetNBounded
+	Lookups uint64
+	// Lookups that skipped a node over capacity, sending the key to the nodes after it in the ring
+	Spills uint64
+	// Load of every node with load, as reported by Inc and Done
+	Loads map[string]int64
+}
+
+// NewBoundedConsistentHash returns a ConsistentHash with bounded loads: GetNBounded skips nodes whose load is
+// at their capacity, loadFactor times the average load, so hot keys and unlucky virtual node placement
+// spill to the next nodes in the ring instead of overloading one. Factors below 1 are raised to 1.
+//
+// Load is whatever the caller reports with Inc and Done, e.g. commands in flight. Keys sent to a node other
+// than their owner are not found there by later lookups that don't spill.
+func NewBoundedConsistentHash(loadFactor float64) *ConsistentHash {
+	h := NewConsistentHash()
+	h.loadFactor = math.Max(loadFactor, 1)
+	h.loads = make(map[string]int64)
+	return h
+}
+
+// LoadFactor returns the factor loads are bounded by, 0 if h doesn't bound loads.
+func (h *ConsistentHash) LoadFactor() float64 {
+	return h.loadFactor
+}
+
+// GetNBounded returns up to n distinct nodes for v like GetN, skipping nodes at their capacity. Nodes at
+// capacity are only returned when there aren't n nodes below it. Same as GetN if h doesn't bound loads.
+func (h *ConsistentHash) GetNBounded(v string, n int) []string {
+	if h.loadFactor == 0 {
+		return h.GetN(v, n)
+	}
+
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	if len(h.ring) == 0 || n <= 0 {
+		return nil
+	}
+
+	if n > len(h.nodes) {
+		n = len(h.nodes)
+	}
+
+	h.loadLock.Lock()
+	defer h.loadLock.Unlock()
+
+	// Capacity as in "Consistent Hashing with Bounded Loads" by Mirrokni, Thorup and Zadimoghaddam, counting
+	// the load the lookup is for
+	capacity := int64(math.Ceil(h.loadFactor * float64(h.totalLoad+1) / float64(len(h.nodes))))
+
+	owners := make([]string, 0, n)
+	full := make([]string, 0)
+	h.walk(v, func(node string) bool {
+		if h.loads[node] < capacity {
+			owners = append(owners, node)
+		} else {
+			full = append(full, node)
+		}
+		return len(owners) < n
+	})
+
+	h.lookups++
+	if len(full) != 0 {
+		h.spills++
+	}
+
+	for i := 0; len(owners) < n && i < len(full); i++ {
+		owners = append(owners, full[i])
+	}
+
+	return owners
+}
+
+// Inc adds one to the load of node, nodes not in h are ignored. Does nothing if h doesn't bound loads.
+func (h *ConsistentHash) Inc(node string) {
+	if h.loadFactor == 0 {
+		return
+	}
+
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	if !h.containsNode(node) {
+		return
+	}
+
+	h.loadLock.Lock()
+	defer h.loadLock.Unlock()
+
+	h.loads[node]++
+	h.totalLoad++
+}
+
+// Done removes one from the load of node, undoing Inc. Does nothing if h doesn't bound loads.
+func (h *ConsistentHash) Done(node string) {
+	if h.loadFactor == 0 {
+		return
+	}
+
+	h.loadLock.Lock()
+	defer h.loadLock.Unlock()
+
+	// The node may have been removed, and its load dropped, since Inc
+	if h.loads[node] <= 0 {
+		return
+	}
+
+	h.loads[node]--
+	h.totalLoad--
+	if h.loads[node] == 0 {
+		delete(h.loads, node)
+	}
+}
+
+// LoadStats returns the loads of the nodes of h and how often keys spilled.
+func (h *ConsistentHash) LoadStats() LoadStats {
+	h.loadLock.Lock()
+	defer h.loadLock.Unlock()
+
+	stats := LoadStats{Lookups: h.lookups, Spills: h.spills, Loads: make(map[string]int64, len(h.loads))}
+	for node, load := range h.loads {
+		stats.Loads[node] = load
+	}
+
+	return stats
+}
+
+// Forgets the load of a removed node. Called holding lock.
+func (h *ConsistentHash) dropLoad(node string) {
+	if h.loadFactor == 0 {
+		return
+	}
+
+	h.loadLock.Lock()
+	defer h.loadLock.Unlock()
+
+	h.totalLoad -= h.loads[node]
+	delete(h.loads, node)
+}
@@ -0,0 +1,86 @@
+package ring
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBoundedConsistentHash(t *testing.T) {
+	ch := NewBoundedConsistentHash(1.25)
+	assert.Empty(t, ch.GetNBounded("any", 1))
+	for i := 0; i < 10; i++ {
+		ch.Add("localhost:" + strconv.Itoa(i))
+	}
+
+	// Without load the key stays with its owners
+	assert.Equal(t, ch.GetN("hot", 3), ch.GetNBounded("hot", 3))
+
+	// Requests for a single hot key, none of them done yet
+	served := make(map[string]int)
+	for i := 0; i < 100; i++ {
+		owners := ch.GetNBounded("hot", 1)
+		assert.Len(t, owners, 1)
+		ch.Inc(owners[0])
+		served[owners[0]]++
+	}
+
+	owner, _ := ch.Get("hot")
+	// ceil(1.25 * 100 / 10)
+	assert.LessOrEqual(t, served[owner], 13)
+	assert.Greater(t, len(served), 7)
+	for node, n := range served {
+		assert.LessOrEqual(t, n, 13, node)
+	}
+
+	stats := ch.LoadStats()
+	assert.Equal(t, uint64(101), stats.Lookups)
+	assert.Greater(t, stats.Spills, uint64(0))
+	assert.Equal(t, int64(served[owner]), stats.Loads[owner])
+
+	// Replicas skip the nodes at capacity too
+	owners := ch.GetNBounded("hot", 3)
+	assert.Len(t, owners, 3)
+	assert.NotContains(t, owners, owner)
+
+	for node, n := range served {
+		for i := 0; i < n; i++ {
+			ch.Done(node)
+		}
+	}
+	assert.Empty(t, ch.LoadStats().Loads)
+	assert.Equal(t, owner, ch.GetNBounded("hot", 1)[0])
+}
+
+func TestBoundedConsistentHashMembership(t *testing.T) {
+	ch := NewBoundedConsistentHash(0.5)
+	assert.Equal(t, 1.0, ch.LoadFactor())
+	ch.Add("localhost:0")
+	ch.Add("localhost:1")
+
+	ch.Inc("localhost:0")
+	ch.Inc("localhost:1")
+	ch.Inc("unknown")
+	assert.Equal(t, map[string]int64{"localhost:0": 1, "localhost:1": 1}, ch.LoadStats().Loads)
+
+	// Changing the weight of a node keeps its load
+	ch.AddWithWeight("localhost:0", 50)
+	assert.Equal(t, int64(1), ch.LoadStats().Loads["localhost:0"])
+
+	// Removed nodes lose their load, commands finishing afterwards are ignored
+	ch.Remove("localhost:1")
+	ch.Done("localhost:1")
+	assert.Equal(t, map[string]int64{"localhost:0": 1}, ch.LoadStats().Loads)
+
+	clone := ch.Clone().(*ConsistentHash)
+	assert.Equal(t, 1.0, clone.LoadFactor())
+	assert.Empty(t, clone.LoadStats().Loads)
+
+	plain := NewConsistentHash()
+	plain.Add("localhost:0")
+	plain.Inc("localhost:0")
+	assert.Equal(t, 0.0, plain.LoadFactor())
+	assert.Equal(t, plain.GetN("key", 1), plain.GetNBounded("key", 1))
+	assert.Empty(t, plain.LoadStats().Loads)
+}
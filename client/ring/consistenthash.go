@@ -4,8 +4,6 @@ This code was taken from https://github.com/zeromicro/go-zero/blob/master/core/h
 package ring
 
 import (
-	"crypto/md5"
-	"encoding/binary"
 	"fmt"
 	"sort"
 	"strconv"
@@ -21,7 +19,10 @@ const (
 )
 
 type (
-	// Func defines the hash method.
+	// Func defines the hash method, e.g. MD5, XXHash, FNV1a or Murmur3. Each one maps keys to different
+	// nodes, so every client of a cluster must use the same function, and changing it moves almost every
+	// key to another node: choose one before the cluster holds data, or migrate with client.WithMigration.
+	// The mapping of each function is kept the same across releases.
 	Func func(data []byte) uint64
 
 	// A ConsistentHash is a ring hash implementation.
@@ -30,31 +31,40 @@ type (
 		replicas int
 		keys     []uint64
 		ring     map[uint64][]string
-		nodes    map[string]struct{}
-		lock     sync.RWMutex
+		// Maps nodes to their amount of virtual nodes
+		nodes map[string]int
+		lock  sync.RWMutex
+
+		// Bounded loads, see NewBoundedConsistentHash. Guarded by loadLock, taken after lock
+		loadFactor float64
+		loadLock   sync.Mutex
+		loads      map[string]int64
+		totalLoad  int64
+		lookups    uint64
+		spills     uint64
 	}
 )
 
 // NewConsistentHash returns a ConsistentHash.
 func NewConsistentHash() *ConsistentHash {
-	return NewCustomConsistentHash(minReplicas, hash)
+	return NewCustomConsistentHash(minReplicas, MD5)
 }
 
-// NewCustomConsistentHash returns a ConsistentHash with given replicas and hash func.
+// NewCustomConsistentHash returns a ConsistentHash with given replicas and hash func, MD5 if nil.
 func NewCustomConsistentHash(replicas int, hashFn Func) *ConsistentHash {
 	if replicas < minReplicas {
 		replicas = minReplicas
 	}
 
 	if hashFn == nil {
-		hashFn = hash
+		hashFn = MD5
 	}
 
 	return &ConsistentHash{
 		hashFunc: hashFn,
 		replicas: replicas,
 		ring:     make(map[uint64][]string),
-		nodes:    make(map[string]struct{}),
+		nodes:    make(map[string]int),
 	}
 }
 
@@ -68,15 +78,17 @@ func (h *ConsistentHash) Add(node string) {
 // replicas will be truncated to h.replicas if it's larger than h.replicas,
 // the later call will overwrite the replicas of the former calls.
 func (h *ConsistentHash) AddWithReplicas(node string, replicas int) {
-	h.Remove(node)
-
 	if replicas > h.replicas {
 		replicas = h.replicas
 	}
 
 	h.lock.Lock()
 	defer h.lock.Unlock()
-	h.addNode(node)
+	// Replaced in place, keeping its load
+	if h.containsNode(node) {
+		h.removeReplicas(node)
+	}
+	h.addNode(node, replicas)
 
 	for i := 0; i < replicas; i++ {
 		hash := h.hashFunc([]byte(node + strconv.Itoa(i)))
@@ -125,6 +137,105 @@ func (h *ConsistentHash) Get(v string) (string, bool) {
 	}
 }
 
+// GetN returns up to n distinct nodes for v, starting with the one Get returns and walking the ring clockwise.
+// Fewer nodes are returned when h has less than n.
+func (h *ConsistentHash) GetN(v string, n int) []string {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	if len(h.ring) == 0 || n <= 0 {
+		return nil
+	}
+
+	if n > len(h.nodes) {
+		n = len(h.nodes)
+	}
+
+	owners := make([]string, 0, n)
+	h.walk(v, func(node string) bool {
+		owners = append(owners, node)
+		return len(owners) < n
+	})
+
+	return owners
+}
+
+// walk calls visit with the distinct nodes of h in the order GetN returns them for v, until visit returns
+// false or every node was visited. Called holding lock.
+func (h *ConsistentHash) walk(v string, visit func(node string) bool) {
+	hash := h.hashFunc([]byte(v))
+	index := sort.Search(len(h.keys), func(i int) bool {
+		return h.keys[i] >= hash
+	})
+
+	seen := make([]string, 0, 8)
+	next := func(node string) bool {
+		for _, s := range seen {
+			if s == node {
+				return true
+			}
+		}
+		seen = append(seen, node)
+		return visit(node)
+	}
+
+	for i := 0; i < len(h.keys); i++ {
+		nodes := h.ring[h.keys[(index+i)%len(h.keys)]]
+		if i == 0 && len(nodes) > 1 {
+			// Same choice as Get
+			if !next(nodes[int(h.hashFunc([]byte(innerRepr(v)))%uint64(len(nodes)))]) {
+				return
+			}
+		}
+
+		for _, node := range nodes {
+			if !next(node) {
+				return
+			}
+		}
+	}
+}
+
+// Clone returns a copy of h, changes to either one don't affect the other.
+func (h *ConsistentHash) Clone() Ring {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	c := &ConsistentHash{
+		hashFunc: h.hashFunc,
+		replicas: h.replicas,
+		keys:     append([]uint64(nil), h.keys...),
+		ring:     make(map[uint64][]string, len(h.ring)),
+		nodes:    make(map[string]int, len(h.nodes)),
+		// Loads are tracked by the caller of each ring, the copy starts without any
+		loadFactor: h.loadFactor,
+	}
+	if h.loads != nil {
+		c.loads = make(map[string]int64)
+	}
+	for hash, nodes := range h.ring {
+		c.ring[hash] = append([]string(nil), nodes...)
+	}
+	for node, replicas := range h.nodes {
+		c.nodes[node] = replicas
+	}
+
+	return c
+}
+
+// Nodes returns the nodes in h in no particular order.
+func (h *ConsistentHash) Nodes() []string {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	nodes := make([]string, 0, len(h.nodes))
+	for node := range h.nodes {
+		nodes = append(nodes, node)
+	}
+
+	return nodes
+}
+
 // Remove removes the given node from h.
 func (h *ConsistentHash) Remove(node string) {
 	h.lock.Lock()
@@ -134,6 +245,13 @@ func (h *ConsistentHash) Remove(node string) {
 		return
 	}
 
+	h.removeReplicas(node)
+	h.removeNode(node)
+	h.dropLoad(node)
+}
+
+// Removes the virtual nodes of node from the ring. Called holding lock.
+func (h *ConsistentHash) removeReplicas(node string) {
 	for i := 0; i < h.replicas; i++ {
 		hash := h.hashFunc([]byte(node + strconv.Itoa(i)))
 		index := sort.Search(len(h.keys), func(i int) bool {
@@ -144,8 +262,6 @@ func (h *ConsistentHash) Remove(node string) {
 		}
 		h.removeRingNode(hash, node)
 	}
-
-	h.removeNode(node)
 }
 
 func (h *ConsistentHash) removeRingNode(hash uint64, node string) {
@@ -164,8 +280,8 @@ func (h *ConsistentHash) removeRingNode(hash uint64, node string) {
 	}
 }
 
-func (h *ConsistentHash) addNode(node string) {
-	h.nodes[node] = struct{}{}
+func (h *ConsistentHash) addNode(node string, replicas int) {
+	h.nodes[node] = replicas
 }
 
 func (h *ConsistentHash) containsNode(node string) bool {
@@ -180,9 +296,3 @@ func (h *ConsistentHash) removeNode(node string) {
 func innerRepr(node any) string {
 	return fmt.Sprintf("%d:%v", prime, node)
 }
-
-func hash(key []byte) uint64 {
-	h := md5.New()
-	h.Write([]byte(key))
-	return binary.LittleEndian.Uint64(h.Sum(nil))
-}
@@ -53,6 +53,56 @@ func TestConsistentHash(t *testing.T) {
 	assert.True(t, entropy > .95)
 }
 
+func TestConsistentHashGetN(t *testing.T) {
+	ch := NewConsistentHash()
+	assert.Empty(t, ch.GetN("any", 3))
+
+	for i := 0; i < keySize; i++ {
+		ch.Add("localhost:" + strconv.Itoa(i))
+	}
+
+	for i := 0; i < requestSize; i++ {
+		key := strconv.Itoa(i)
+		owners := ch.GetN(key, 3)
+		assert.Len(t, owners, 3)
+
+		first, _ := ch.Get(key)
+		assert.Equal(t, first, owners[0])
+
+		distinct := make(map[string]bool)
+		for _, owner := range owners {
+			distinct[owner] = true
+		}
+		assert.Len(t, distinct, 3)
+	}
+
+	// Removing the first owner promotes the next ones
+	owners := ch.GetN("key", 3)
+	ch.Remove(owners[0])
+	assert.Equal(t, owners[1:], ch.GetN("key", 2))
+
+	assert.Len(t, ch.GetN("key", keySize+5), keySize-1)
+}
+
+func TestConsistentHashClone(t *testing.T) {
+	ch := NewConsistentHash()
+	for i := 0; i < keySize; i++ {
+		ch.Add("localhost:" + strconv.Itoa(i))
+	}
+
+	clone := ch.Clone()
+	ch.Remove("localhost:0")
+	ch.Add("localhost:100")
+
+	assert.Len(t, clone.Nodes(), keySize)
+	assert.Contains(t, clone.Nodes(), "localhost:0")
+	assert.NotContains(t, clone.Nodes(), "localhost:100")
+	for i := 0; i < requestSize; i++ {
+		node, _ := clone.Get(strconv.Itoa(i))
+		assert.NotEqual(t, "localhost:100", node)
+	}
+}
+
 func TestConsistentHashIncrementalTransfer(t *testing.T) {
 	prefix := "anything"
 	create := func() *ConsistentHash {
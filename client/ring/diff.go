@@ -0,0 +1,101 @@
+package ring
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// A Move is a part of the hash space whose owner differs between two rings.
+type Move struct {
+	// Owner in the first ring, empty if it has no nodes
+	From string
+	// Owner in the second ring, empty if it has no nodes
+	To string
+	// Fraction of the hash space, from 0 to 1
+	Fraction float64
+}
+
+// Diff returns the parts of the hash space owned by a different node in to than in from, one Move per pair
+// of nodes sorted from the largest, so keys spread evenly over the hash space move in the same proportions.
+// Both rings must use the same hash function. Only the owners returned by Get are compared, not the replicas
+// returned by GetN, and virtual nodes of different nodes sharing a hash are ignored.
+func Diff(from, to *ConsistentHash) ([]Move, error) {
+	fromPoints, fromOwners := from.points()
+	toPoints, toOwners := to.points()
+	if !sameFunc(from.hashFunc, to.hashFunc) {
+		return nil, fmt.Errorf("ring: rings use different hash functions, every key may move")
+	}
+
+	// Every point where either ring changes owner, each one ending an arc owned by a single node in each ring
+	points := append(append(make([]uint64, 0, len(fromPoints)+len(toPoints)), fromPoints...), toPoints...)
+	sort.Slice(points, func(i, j int) bool {
+		return points[i] < points[j]
+	})
+	unique := points[:0]
+	for i, p := range points {
+		if i == 0 || p != points[i-1] {
+			unique = append(unique, p)
+		}
+	}
+	points = unique
+
+	moved := make(map[[2]string]float64)
+	switch len(points) {
+	case 0:
+		return nil, nil
+	case 1:
+		// A single arc covering the whole hash space
+		if a, b := ownerAt(fromPoints, fromOwners, points[0]), ownerAt(toPoints, toOwners, points[0]); a != b {
+			moved[[2]string{a, b}] = 1
+		}
+	default:
+		for i, end := range points {
+			// The first arc wraps around from the last point
+			length := end - points[(i+len(points)-1)%len(points)]
+			if a, b := ownerAt(fromPoints, fromOwners, end), ownerAt(toPoints, toOwners, end); a != b {
+				moved[[2]string{a, b}] += float64(length) / math.Pow(2, 64)
+			}
+		}
+	}
+
+	moves := make([]Move, 0, len(moved))
+	for pair, fraction := range moved {
+		moves = append(moves, Move{From: pair[0], To: pair[1], Fraction: fraction})
+	}
+	sort.Slice(moves, func(i, j int) bool {
+		if moves[i].Fraction != moves[j].Fraction {
+			return moves[i].Fraction > moves[j].Fraction
+		} else if moves[i].From != moves[j].From {
+			return moves[i].From < moves[j].From
+		}
+		return moves[i].To < moves[j].To
+	})
+
+	return moves, nil
+}
+
+// Returns the sorted hashes of the virtual nodes of h and the node at each one.
+func (h *ConsistentHash) points() ([]uint64, []string) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	owners := make([]string, len(h.keys))
+	for i, key := range h.keys {
+		owners[i] = h.ring[key][0]
+	}
+
+	return append([]uint64(nil), h.keys...), owners
+}
+
+// Returns the owner of the arc ending at p, the first virtual node at or after it.
+func ownerAt(points []uint64, owners []string, p uint64) string {
+	if len(points) == 0 {
+		return ""
+	}
+
+	i := sort.Search(len(points), func(i int) bool {
+		return points[i] >= p
+	})
+	return owners[i%len(points)]
+}
@@ -0,0 +1,75 @@
+package ring
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Checks that Diff predicts how many keys move between each pair of nodes.
+func assertDiffPredicts(t *testing.T, from, to *ConsistentHash) []Move {
+	moves, err := Diff(from, to)
+	assert.NoError(t, err)
+
+	sampled := make(map[Move]float64)
+	for i := 0; i < ringKeys; i++ {
+		key := "key:" + strconv.Itoa(i)
+		a, _ := from.Get(key)
+		b, _ := to.Get(key)
+		if a != b {
+			sampled[Move{From: a, To: b}] += 1.0 / ringKeys
+		}
+	}
+
+	assert.Len(t, moves, len(sampled))
+	for _, m := range moves {
+		assert.InDelta(t, sampled[Move{From: m.From, To: m.To}], m.Fraction, 0.01, "%s -> %s", m.From, m.To)
+	}
+	return moves
+}
+
+func TestDiff(t *testing.T) {
+	from := NewConsistentHash()
+	for i := 0; i < 5; i++ {
+		from.Add("localhost:" + strconv.Itoa(i))
+	}
+
+	moves, err := Diff(from, from.Clone().(*ConsistentHash))
+	assert.NoError(t, err)
+	assert.Empty(t, moves)
+
+	added := from.Clone().(*ConsistentHash)
+	added.Add("localhost:new")
+	total := 0.0
+	for _, m := range assertDiffPredicts(t, from, added) {
+		assert.Equal(t, "localhost:new", m.To)
+		total += m.Fraction
+	}
+	assert.InDelta(t, 1.0/6, total, 0.05)
+
+	removed := from.Clone().(*ConsistentHash)
+	removed.Remove("localhost:2")
+	for _, m := range assertDiffPredicts(t, from, removed) {
+		assert.Equal(t, "localhost:2", m.From)
+	}
+
+	weighted := from.Clone().(*ConsistentHash)
+	weighted.AddWithWeight("localhost:0", 50)
+	for _, m := range assertDiffPredicts(t, from, weighted) {
+		assert.Equal(t, "localhost:0", m.From)
+	}
+
+	single := NewConsistentHash()
+	single.Add("localhost:0")
+	moves, err = Diff(NewConsistentHash(), single)
+	assert.NoError(t, err)
+	if assert.Len(t, moves, 1) {
+		assert.Equal(t, "", moves[0].From)
+		assert.Equal(t, "localhost:0", moves[0].To)
+		assert.InDelta(t, 1, moves[0].Fraction, 1e-9)
+	}
+
+	_, err = Diff(from, NewCustomConsistentHash(minReplicas, XXHash))
+	assert.Error(t, err)
+}
@@ -0,0 +1,89 @@
+package ring
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// State of a ConsistentHash as saved in JSON, e.g.
+//
+//	{"hash": "md5", "replicas": 100, "nodes": [{"node": "10.0.0.1:3000", "replicas": 100}]}
+type consistentHashState struct {
+	// Name of the hash function, see HashFunc
+	Hash string `json:"hash"`
+	// Virtual nodes of a node added with Add
+	Replicas int `json:"replicas"`
+	// Sorted by node
+	Nodes []nodeState `json:"nodes"`
+}
+
+type nodeState struct {
+	Node string `json:"node"`
+	// Virtual nodes of the node, its weight
+	Replicas int `json:"replicas"`
+}
+
+// MarshalJSON saves the nodes of h, their weights, its replicas and the name of its hash function, which must
+// be one of the functions of this package. Loads are not saved.
+func (h *ConsistentHash) MarshalJSON() ([]byte, error) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	name, ok := hashFuncName(h.hashFunc)
+	if !ok {
+		return nil, fmt.Errorf("ring: hash function has no name, only the ones of this package can be saved")
+	}
+
+	state := consistentHashState{Hash: name, Replicas: h.replicas, Nodes: make([]nodeState, 0, len(h.nodes))}
+	for node, replicas := range h.nodes {
+		state.Nodes = append(state.Nodes, nodeState{Node: node, Replicas: replicas})
+	}
+	sort.Slice(state.Nodes, func(i, j int) bool {
+		return state.Nodes[i].Node < state.Nodes[j].Node
+	})
+
+	return json.Marshal(state)
+}
+
+// UnmarshalJSON replaces the nodes, replicas and hash function of h with the ones saved by MarshalJSON,
+// so h maps keys the same way as the ring that was saved. h may be a zero ConsistentHash. Whether h bounds
+// loads is kept, its loads are reset.
+func (h *ConsistentHash) UnmarshalJSON(data []byte) error {
+	var state consistentHashState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+
+	hashFn, ok := HashFunc(state.Hash)
+	if !ok {
+		return fmt.Errorf("ring: unknown hash function %q", state.Hash)
+	}
+
+	loaded := NewCustomConsistentHash(state.Replicas, hashFn)
+	for _, n := range state.Nodes {
+		if n.Replicas < 1 || n.Replicas > loaded.replicas {
+			return fmt.Errorf("ring: node %s has %d replicas, must be between 1 and %d", n.Node, n.Replicas, loaded.replicas)
+		}
+		loaded.AddWithReplicas(n.Node, n.Replicas)
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.hashFunc = loaded.hashFunc
+	h.replicas = loaded.replicas
+	h.keys = loaded.keys
+	h.ring = loaded.ring
+	h.nodes = loaded.nodes
+
+	h.loadLock.Lock()
+	defer h.loadLock.Unlock()
+
+	if h.loadFactor != 0 {
+		h.loads = make(map[string]int64)
+		h.totalLoad = 0
+	}
+
+	return nil
+}
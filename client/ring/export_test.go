@@ -0,0 +1,50 @@
+package ring
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsistentHashJSON(t *testing.T) {
+	ch := NewCustomConsistentHash(200, XXHash)
+	ch.Add("10.0.0.1:3000")
+	ch.AddWithWeight("10.0.0.2:3000", 50)
+	ch.AddWithReplicas("10.0.0.3:3000", 120)
+
+	data, err := json.Marshal(ch)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"hash": "xxhash", "replicas": 200, "nodes": [
+		{"node": "10.0.0.1:3000", "replicas": 200},
+		{"node": "10.0.0.2:3000", "replicas": 100},
+		{"node": "10.0.0.3:3000", "replicas": 120}
+	]}`, string(data))
+
+	var loaded ConsistentHash
+	assert.NoError(t, json.Unmarshal(data, &loaded))
+	assert.ElementsMatch(t, ch.Nodes(), loaded.Nodes())
+	for i := 0; i < 1000; i++ {
+		key := strconv.Itoa(i)
+		assert.Equal(t, ch.GetN(key, 2), loaded.GetN(key, 2), key)
+	}
+
+	// Loading into a bounded ring keeps it bounded
+	bounded := NewBoundedConsistentHash(1.5)
+	bounded.Add("10.0.0.9:3000")
+	bounded.Inc("10.0.0.9:3000")
+	assert.NoError(t, json.Unmarshal(data, bounded))
+	assert.Equal(t, 1.5, bounded.LoadFactor())
+	assert.Empty(t, bounded.LoadStats().Loads)
+	assert.ElementsMatch(t, ch.Nodes(), bounded.Nodes())
+
+	custom := NewCustomConsistentHash(100, func(data []byte) uint64 { return uint64(len(data)) })
+	_, err = json.Marshal(custom)
+	assert.Error(t, err)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"hash": "sha1", "replicas": 100, "nodes": []}`), &loaded))
+	assert.Error(t, json.Unmarshal([]byte(`{"hash": "md5", "replicas": 100, "nodes": [{"node": "a", "replicas": 0}]}`), &loaded))
+	// Failed loads leave the ring as it was
+	assert.ElementsMatch(t, ch.Nodes(), loaded.Nodes())
+}
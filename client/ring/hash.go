@@ -0,0 +1,66 @@
+package ring
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"hash/fnv"
+	"reflect"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/spaolacci/murmur3"
+)
+
+// Names of the hash functions, as saved by ConsistentHash.MarshalJSON
+var hashFuncs = map[string]Func{
+	"md5":     MD5,
+	"xxhash":  XXHash,
+	"fnv1a":   FNV1a,
+	"murmur3": Murmur3,
+}
+
+// HashFunc returns the hash function with the given name: "md5", "xxhash", "fnv1a" or "murmur3".
+func HashFunc(name string) (Func, bool) {
+	fn, ok := hashFuncs[name]
+	return fn, ok
+}
+
+// Returns the name of fn, false if it's not one of the functions of this package.
+func hashFuncName(fn Func) (string, bool) {
+	for name, f := range hashFuncs {
+		if sameFunc(f, fn) {
+			return name, true
+		}
+	}
+
+	return "", false
+}
+
+func sameFunc(a, b Func) bool {
+	return reflect.ValueOf(a).Pointer() == reflect.ValueOf(b).Pointer()
+}
+
+// MD5 returns the first 8 bytes of the MD5 sum of data, little endian. It's the function of NewConsistentHash
+// and of servers redirecting commands, and the slowest one.
+func MD5(data []byte) uint64 {
+	sum := md5.Sum(data)
+	return binary.LittleEndian.Uint64(sum[:])
+}
+
+// XXHash returns the 64 bit xxHash of data, the fastest function.
+func XXHash(data []byte) uint64 {
+	return xxhash.Sum64(data)
+}
+
+// FNV1a returns the 64 bit FNV-1a hash of data mixed with the splitmix64 finalizer. FNV-1a alone barely
+// changes the high bits between inputs that differ in their last bytes, e.g. "node1" and "node2", which
+// would cluster virtual nodes together.
+func FNV1a(data []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(data)
+	return mix(h.Sum64())
+}
+
+// Murmur3 returns the first 64 bits of the 128 bit MurmurHash3 of data.
+func Murmur3(data []byte) uint64 {
+	return murmur3.Sum64(data)
+}
@@ -0,0 +1,107 @@
+package ring
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var hashFuncCases = []struct {
+	name string
+	fn   Func
+}{
+	{"md5", MD5},
+	{"xxhash", XXHash},
+	{"fnv1a", FNV1a},
+	{"murmur3", Murmur3},
+}
+
+// Changing any of these values remaps the keys of every cluster using the function.
+func TestHashFuncsStable(t *testing.T) {
+	assert.Equal(t, uint64(0x08d0090c1d55585a), MD5([]byte("dcache")))
+	assert.Equal(t, uint64(0x981d2ea2e9bd4243), XXHash([]byte("dcache")))
+	assert.Equal(t, uint64(0xa3e1c0419542dd5a), FNV1a([]byte("dcache")))
+	assert.Equal(t, uint64(0xb1ba5f9e9976056b), Murmur3([]byte("dcache")))
+
+	// The default ring must keep mapping keys the same way
+	ch := NewConsistentHash()
+	for i := 0; i < 5; i++ {
+		ch.Add("10.0.0." + strconv.Itoa(i) + ":3000")
+	}
+	owners := map[string]string{
+		"foo":         "10.0.0.2:3000",
+		"bar":         "10.0.0.2:3000",
+		"user:1":      "10.0.0.3:3000",
+		"user:2":      "10.0.0.3:3000",
+		"session:abc": "10.0.0.2:3000",
+	}
+	for key, owner := range owners {
+		node, _ := ch.Get(key)
+		assert.Equal(t, owner, node, key)
+	}
+}
+
+func TestHashFuncsBalance(t *testing.T) {
+	for _, hf := range hashFuncCases {
+		t.Run(hf.name, func(t *testing.T) {
+			ch := NewCustomConsistentHash(minReplicas, hf.fn)
+			for i := 0; i < ringNodes; i++ {
+				ch.Add("localhost:" + strconv.Itoa(i))
+			}
+
+			load := make(map[string]int)
+			for i := 0; i < ringKeys; i++ {
+				node, _ := ch.Get("key:" + strconv.Itoa(i))
+				load[node]++
+			}
+
+			max := 0
+			for _, n := range load {
+				if n > max {
+					max = n
+				}
+			}
+			t.Logf("max load %.3f of the average", float64(max)*ringNodes/ringKeys)
+			assert.Len(t, load, ringNodes)
+			// 100 virtual nodes leave some nodes up to about 40% above the average with any function,
+			// functions clustering virtual nodes together do far worse
+			assert.Less(t, float64(max)*ringNodes/ringKeys, 1.5)
+		})
+	}
+}
+
+func BenchmarkHashFuncs(b *testing.B) {
+	for _, size := range []int{16, 64, 512} {
+		data := []byte(strings.Repeat("k", size))
+		for _, hf := range hashFuncCases {
+			b.Run(hf.name+"/"+strconv.Itoa(size), func(b *testing.B) {
+				b.SetBytes(int64(size))
+				for i := 0; i < b.N; i++ {
+					hf.fn(data)
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkConsistentHashGetFuncs(b *testing.B) {
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = "user:session:" + strconv.Itoa(i)
+	}
+
+	for _, hf := range hashFuncCases {
+		ch := NewCustomConsistentHash(minReplicas, hf.fn)
+		for i := 0; i < keySize; i++ {
+			ch.Add("localhost:" + strconv.Itoa(i))
+		}
+
+		b.Run(hf.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				ch.Get(keys[i%len(keys)])
+			}
+		})
+	}
+}
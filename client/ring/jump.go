@@ -0,0 +1,114 @@
+package ring
+
+import "sync"
+
+// A JumpHash is a ring using jump consistent hashing, which spreads keys evenly over the nodes without
+// storing anything but the node list.
+//
+// Nodes are numbered in the order they are added, so clients must add them in the same order to agree on
+// owners. Adding a node only moves keys to it, removing one puts the last node in its place, moving the keys
+// of both, so removals must happen in the same order too. Changing membership from a set, e.g. a map, must
+// sort it first. Replicas are the nodes numbered after the owner.
+type JumpHash struct {
+	lock  sync.RWMutex
+	nodes []string
+	// Maps nodes to their index in nodes
+	index map[string]int
+}
+
+// NewJumpHash returns an empty JumpHash.
+func NewJumpHash() *JumpHash {
+	return &JumpHash{index: make(map[string]int)}
+}
+
+func (j *JumpHash) Add(node string) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	if _, ok := j.index[node]; ok {
+		return
+	}
+
+	j.index[node] = len(j.nodes)
+	j.nodes = append(j.nodes, node)
+}
+
+func (j *JumpHash) Remove(node string) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	i, ok := j.index[node]
+	if !ok {
+		return
+	}
+
+	last := len(j.nodes) - 1
+	j.nodes[i] = j.nodes[last]
+	j.index[j.nodes[i]] = i
+	j.nodes = j.nodes[:last]
+	delete(j.index, node)
+}
+
+func (j *JumpHash) Get(key string) (string, bool) {
+	j.lock.RLock()
+	defer j.lock.RUnlock()
+
+	if len(j.nodes) == 0 {
+		return "", false
+	}
+
+	return j.nodes[jump(hashString(key), len(j.nodes))], true
+}
+
+func (j *JumpHash) GetN(key string, n int) []string {
+	j.lock.RLock()
+	defer j.lock.RUnlock()
+
+	if len(j.nodes) == 0 || n <= 0 {
+		return nil
+	}
+
+	if n > len(j.nodes) {
+		n = len(j.nodes)
+	}
+
+	first := jump(hashString(key), len(j.nodes))
+	owners := make([]string, n)
+	for i := range owners {
+		owners[i] = j.nodes[(first+i)%len(j.nodes)]
+	}
+
+	return owners
+}
+
+func (j *JumpHash) Nodes() []string {
+	j.lock.RLock()
+	defer j.lock.RUnlock()
+
+	return append([]string(nil), j.nodes...)
+}
+
+func (j *JumpHash) Clone() Ring {
+	j.lock.RLock()
+	defer j.lock.RUnlock()
+
+	c := NewJumpHash()
+	c.nodes = append(c.nodes, j.nodes...)
+	for node, i := range j.index {
+		c.index[node] = i
+	}
+
+	return c
+}
+
+// Jump consistent hash by Lamping and Veach, returns the bucket in [0, buckets) key belongs to.
+func jump(key uint64, buckets int) int {
+	var b, j int64 = -1, 0
+	for j < int64(buckets) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+
+	return int(b)
+}
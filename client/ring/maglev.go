@@ -0,0 +1,191 @@
+package ring
+
+import (
+	"sort"
+	"sync"
+)
+
+// Size of the lookup table of a Maglev created by NewMaglev
+const MaglevTableSize = 65537
+
+// A Maglev is a ring using Maglev hashing: a lookup table where every node fills about the same amount of
+// entries, rebuilt on every membership change. Lookups take constant time and load is nearly even, while
+// membership changes move slightly more keys than the minimum.
+type Maglev struct {
+	lock  sync.RWMutex
+	size  int
+	nodes map[string]struct{}
+	// Owner of each entry, nil when there are no nodes
+	table []string
+}
+
+// NewMaglev returns an empty Maglev with a table of MaglevTableSize entries.
+func NewMaglev() *Maglev {
+	return NewMaglevWithSize(MaglevTableSize)
+}
+
+// NewMaglevWithSize returns an empty Maglev with a table of size entries, rounded up to the next prime so
+// every permutation walks the whole table. size should be much bigger than the amount of nodes, e.g. 100
+// times bigger, so every node gets about the same share.
+func NewMaglevWithSize(size int) *Maglev {
+	return &Maglev{size: nextPrime(size), nodes: make(map[string]struct{})}
+}
+
+// Returns the smallest prime not smaller than n.
+func nextPrime(n int) int {
+	if n <= 2 {
+		return 2
+	}
+
+	for ; ; n++ {
+		prime := true
+		for d := 2; d*d <= n; d++ {
+			if n%d == 0 {
+				prime = false
+				break
+			}
+		}
+		if prime {
+			return n
+		}
+	}
+}
+
+func (m *Maglev) Add(node string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if _, ok := m.nodes[node]; ok {
+		return
+	}
+
+	m.nodes[node] = struct{}{}
+	m.populate()
+}
+
+func (m *Maglev) Remove(node string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if _, ok := m.nodes[node]; !ok {
+		return
+	}
+
+	delete(m.nodes, node)
+	m.populate()
+}
+
+// Fills the table taking turns between nodes, each one walking its own permutation of the entries and taking
+// the next free one. Called holding lock.
+func (m *Maglev) populate() {
+	if len(m.nodes) == 0 {
+		m.table = nil
+		return
+	}
+
+	// Sorted so every client builds the same table
+	names := make([]string, 0, len(m.nodes))
+	for node := range m.nodes {
+		names = append(names, node)
+	}
+	sort.Strings(names)
+
+	size := uint64(m.size)
+	offsets := make([]uint64, len(names))
+	skips := make([]uint64, len(names))
+	next := make([]uint64, len(names))
+	for i, name := range names {
+		h := hashString(name)
+		offsets[i] = h % size
+		skips[i] = mix(h^0x9e3779b97f4a7c15)%(size-1) + 1
+	}
+
+	entries := make([]int, m.size)
+	for i := range entries {
+		entries[i] = -1
+	}
+
+	for filled := 0; filled < m.size; {
+		for i := range names {
+			c := (offsets[i] + next[i]*skips[i]) % size
+			for entries[c] >= 0 {
+				next[i]++
+				c = (offsets[i] + next[i]*skips[i]) % size
+			}
+
+			entries[c] = i
+			next[i]++
+			if filled++; filled == m.size {
+				break
+			}
+		}
+	}
+
+	m.table = make([]string, m.size)
+	for i, node := range entries {
+		m.table[i] = names[node]
+	}
+}
+
+func (m *Maglev) Get(key string) (string, bool) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	if m.table == nil {
+		return "", false
+	}
+
+	return m.table[hashString(key)%uint64(m.size)], true
+}
+
+// GetN returns the owner of key followed by the next distinct nodes in the table.
+func (m *Maglev) GetN(key string, n int) []string {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	if m.table == nil || n <= 0 {
+		return nil
+	}
+
+	if n > len(m.nodes) {
+		n = len(m.nodes)
+	}
+
+	start := int(hashString(key) % uint64(m.size))
+	owners := make([]string, 0, n)
+	seen := make(map[string]bool, n)
+	for i := 0; len(owners) < n && i < m.size; i++ {
+		node := m.table[(start+i)%m.size]
+		if !seen[node] {
+			seen[node] = true
+			owners = append(owners, node)
+		}
+	}
+
+	return owners
+}
+
+func (m *Maglev) Nodes() []string {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	nodes := make([]string, 0, len(m.nodes))
+	for node := range m.nodes {
+		nodes = append(nodes, node)
+	}
+
+	return nodes
+}
+
+func (m *Maglev) Clone() Ring {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	c := NewMaglevWithSize(m.size)
+	for node := range m.nodes {
+		c.nodes[node] = struct{}{}
+	}
+	c.table = m.table
+
+	return c
+}
@@ -0,0 +1,24 @@
+package ring
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaglevWithSize(t *testing.T) {
+	// Permutations only cover tables of prime size, others would never fill
+	for _, size := range []int{0, 2, 100, 1024} {
+		m := NewMaglevWithSize(size)
+		for i := 0; i < 3; i++ {
+			m.Add("localhost:" + strconv.Itoa(i))
+		}
+
+		assert.Len(t, m.table, nextPrime(size))
+		_, ok := m.Get("Foo")
+		assert.True(t, ok)
+	}
+
+	assert.Equal(t, []int{2, 2, 3, 101, 1031}, []int{nextPrime(0), nextPrime(2), nextPrime(3), nextPrime(100), nextPrime(1024)})
+}
@@ -0,0 +1,110 @@
+package ring
+
+import (
+	"sort"
+	"sync"
+)
+
+// A Rendezvous is a ring using highest random weight hashing: every node gets a score for each key and the
+// key belongs to the nodes with the highest scores. Only the keys of a removed node move, and an added node
+// takes keys evenly from the others, at the cost of scoring every node on each lookup.
+type Rendezvous struct {
+	lock sync.RWMutex
+	// Maps nodes to the hash of their name
+	nodes map[string]uint64
+}
+
+// NewRendezvous returns an empty Rendezvous.
+func NewRendezvous() *Rendezvous {
+	return &Rendezvous{nodes: make(map[string]uint64)}
+}
+
+func (r *Rendezvous) Add(node string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.nodes[node] = hashString(node)
+}
+
+func (r *Rendezvous) Remove(node string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	delete(r.nodes, node)
+}
+
+func (r *Rendezvous) Get(key string) (string, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	h := hashString(key)
+	best, bestScore, found := "", uint64(0), false
+	for node, nh := range r.nodes {
+		score := mix(h ^ nh)
+		if !found || score > bestScore || (score == bestScore && node < best) {
+			best, bestScore, found = node, score, true
+		}
+	}
+
+	return best, found
+}
+
+func (r *Rendezvous) GetN(key string, n int) []string {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	if len(r.nodes) == 0 || n <= 0 {
+		return nil
+	}
+
+	type scored struct {
+		node  string
+		score uint64
+	}
+
+	h := hashString(key)
+	scores := make([]scored, 0, len(r.nodes))
+	for node, nh := range r.nodes {
+		scores = append(scores, scored{node, mix(h ^ nh)})
+	}
+	sort.Slice(scores, func(i, j int) bool {
+		if scores[i].score != scores[j].score {
+			return scores[i].score > scores[j].score
+		}
+		return scores[i].node < scores[j].node
+	})
+
+	if n > len(scores) {
+		n = len(scores)
+	}
+	owners := make([]string, n)
+	for i := range owners {
+		owners[i] = scores[i].node
+	}
+
+	return owners
+}
+
+func (r *Rendezvous) Nodes() []string {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	nodes := make([]string, 0, len(r.nodes))
+	for node := range r.nodes {
+		nodes = append(nodes, node)
+	}
+
+	return nodes
+}
+
+func (r *Rendezvous) Clone() Ring {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	c := NewRendezvous()
+	for node, nh := range r.nodes {
+		c.nodes[node] = nh
+	}
+
+	return c
+}
@@ -0,0 +1,49 @@
+package ring
+
+// A Ring maps keys to the nodes responsible for them. Implementations are safe for concurrent use.
+type Ring interface {
+	// Add adds node, adding a node already in the ring does nothing.
+	Add(node string)
+	// Remove removes node, removing a node not in the ring does nothing.
+	Remove(node string)
+	// Get returns the node responsible for key, false if the ring is empty.
+	Get(key string) (string, bool)
+	// GetN returns up to n distinct nodes for key, starting with the one Get returns.
+	GetN(key string, n int) []string
+	// Nodes returns the nodes in the ring in no particular order.
+	Nodes() []string
+	// Clone returns a copy of the ring, changes to either one don't affect the other.
+	Clone() Ring
+}
+
+// A Weighted ring lets nodes own shares of the keys proportional to their weight.
+type Weighted interface {
+	Ring
+	// AddWithWeight adds node with weight, from 1 to TopWeight, or changes the weight of a node already in the
+	// ring in a single step, so lookups never miss it.
+	AddWithWeight(node string, weight int)
+}
+
+var (
+	_ Weighted = &ConsistentHash{}
+
+	_ Ring = &ConsistentHash{}
+	_ Ring = &Rendezvous{}
+	_ Ring = &JumpHash{}
+	_ Ring = &Maglev{}
+)
+
+// Hashes keys and nodes for the rings that don't take a Func.
+func hashString(s string) uint64 {
+	return FNV1a([]byte(s))
+}
+
+// Finalizer of splitmix64.
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,153 @@
+package ring
+
+import (
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var rings = []struct {
+	name    string
+	newRing func() Ring
+	// Highest load of a node relative to the average tolerated with 10 nodes
+	maxLoad float64
+	// Fraction of the keys tolerated to move between nodes that stayed in the ring when one is added or removed
+	maxStray float64
+}{
+	{"consistent hash", func() Ring { return NewConsistentHash() }, 1.35, 0},
+	{"rendezvous", func() Ring { return NewRendezvous() }, 1.1, 0},
+	{"jump hash", func() Ring { return NewJumpHash() }, 1.1, 0},
+	// Maglev favors balance over minimal disruption
+	{"maglev", func() Ring { return NewMaglev() }, 1.1, 0.03},
+}
+
+const (
+	ringNodes = 10
+	ringKeys  = 100000
+)
+
+func newTestRing(newRing func() Ring) Ring {
+	r := newRing()
+	for i := 0; i < ringNodes; i++ {
+		r.Add("localhost:" + strconv.Itoa(i))
+	}
+	return r
+}
+
+// Maps keys to their owner.
+func owners(r Ring) []string {
+	owners := make([]string, ringKeys)
+	for i := range owners {
+		owners[i], _ = r.Get(strconv.Itoa(i))
+	}
+	return owners
+}
+
+// Returns the fraction of the keys that moved and the fraction that moved between nodes other than node.
+func moved(before, after []string, node string) (float64, float64) {
+	n, stray := 0, 0
+	for i := range before {
+		if before[i] != after[i] {
+			n++
+			if before[i] != node && after[i] != node {
+				stray++
+			}
+		}
+	}
+	return float64(n) / ringKeys, float64(stray) / ringKeys
+}
+
+func TestRingLoadBalance(t *testing.T) {
+	for _, tc := range rings {
+		t.Run(tc.name, func(t *testing.T) {
+			load := make(map[string]int)
+			for _, owner := range owners(newTestRing(tc.newRing)) {
+				load[owner]++
+			}
+			assert.Len(t, load, ringNodes)
+
+			avg := float64(ringKeys) / ringNodes
+			var max, variance float64
+			for _, n := range load {
+				max = math.Max(max, float64(n)/avg)
+				variance += (float64(n) - avg) * (float64(n) - avg) / ringNodes
+			}
+			t.Logf("max load %.3f of the average, standard deviation %.1f%%", max, 100*math.Sqrt(variance)/avg)
+			assert.Less(t, max, tc.maxLoad)
+		})
+	}
+}
+
+func TestRingMovement(t *testing.T) {
+	for _, tc := range rings {
+		t.Run(tc.name, func(t *testing.T) {
+			r := newTestRing(tc.newRing)
+			before := owners(r)
+
+			r.Add("localhost:new")
+			// The new node should take about its share, 1/11 of the keys, from the others
+			share, stray := moved(before, owners(r), "localhost:new")
+			t.Logf("adding a node moved %.1f%% of the keys, %.1f%% between other nodes", 100*share, 100*stray)
+			assert.InDelta(t, 1.0/(ringNodes+1), share, 0.03)
+			assert.LessOrEqual(t, stray, tc.maxStray)
+
+			r.Remove("localhost:new")
+			assert.Equal(t, before, owners(r), "removing the added node should restore the owners")
+
+			r.Remove("localhost:3")
+			share, stray = moved(before, owners(r), "localhost:3")
+			t.Logf("removing a node moved %.1f%% of the keys, %.1f%% between other nodes", 100*share, 100*stray)
+			if tc.name == "jump hash" {
+				// The last node takes the place of the removed one, moving its keys too
+				assert.InDelta(t, 2.0/ringNodes, share, 0.03)
+				return
+			}
+			assert.InDelta(t, 1.0/ringNodes, share, 0.03)
+			assert.LessOrEqual(t, stray, tc.maxStray)
+		})
+	}
+}
+
+func TestRingGetN(t *testing.T) {
+	for _, tc := range rings {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Empty(t, tc.newRing().GetN("any", 3))
+
+			r := newTestRing(tc.newRing)
+			for i := 0; i < 1000; i++ {
+				key := strconv.Itoa(i)
+				owners := r.GetN(key, 3)
+				assert.Len(t, owners, 3)
+
+				first, _ := r.Get(key)
+				assert.Equal(t, first, owners[0])
+
+				distinct := make(map[string]bool)
+				for _, owner := range owners {
+					distinct[owner] = true
+				}
+				assert.Len(t, distinct, 3)
+			}
+
+			assert.Len(t, r.GetN("key", ringNodes+5), ringNodes)
+		})
+	}
+}
+
+func TestRingClone(t *testing.T) {
+	for _, tc := range rings {
+		t.Run(tc.name, func(t *testing.T) {
+			r := newTestRing(tc.newRing)
+			before := owners(r)
+
+			clone := r.Clone()
+			r.Remove("localhost:0")
+			r.Add("localhost:new")
+
+			assert.Len(t, clone.Nodes(), ringNodes)
+			assert.Equal(t, before, owners(clone))
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package client
+
+import (
+	"bufio"
+	"encoding/binary"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/joaovictorsl/dcache/core"
+	"github.com/joaovictorsl/dcache/core/command"
+	"github.com/joaovictorsl/dcache/core/protocol"
+)
+
+// How long to wait before opening an invalidation stream again after it goes down
+const trackingRetryInterval = time.Second
+
+// Invalidation stream of a node connection, see WithTracking.
+type trackingLink struct {
+	mu sync.Mutex
+	// Connection the stream is received on, nil between attempts
+	conn net.Conn
+	// Closed once the link is closed, along with conn
+	stop   chan struct{}
+	closed bool
+}
+
+// Stops the link, l may be nil.
+func (l *trackingLink) close() {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.closed {
+		l.closed = true
+		close(l.stop)
+		if l.conn != nil {
+			l.conn.Close()
+		}
+	}
+}
+
+// Keeps conn as the connection to close along with the link, returning false if it was closed already.
+func (l *trackingLink) setConn(conn net.Conn) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return false
+	}
+	l.conn = conn
+	return true
+}
+
+// Replaces the invalidation stream of a connection just established, does nothing without WithTracking.
+// The near cache keeps nothing until the new stream is up, so reads sent before the node tracks them
+// aren't kept.
+func (dc *dCacheConn) startTracking() {
+	if dc.near == nil || !dc.opts.tracking {
+		return
+	}
+
+	dc.tracking.close()
+	dc.tracking = &trackingLink{stop: make(chan struct{})}
+	dc.near.pause()
+	go dc.track(dc.tracking)
+}
+
+// Receives the keys to invalidate until l is closed, opening the stream again whenever it goes down.
+// Returns once the connection isn't active, as no reads are sent to the node until it's established again.
+func (dc *dCacheConn) track(l *trackingLink) {
+	paused := true
+	defer func() {
+		if paused {
+			dc.near.resume()
+		}
+	}()
+
+	for {
+		r, err := dc.openTracking(l)
+		if err == nil {
+			dc.near.resume()
+			paused = false
+			err = dc.receiveInvalidations(r)
+			dc.near.pause()
+			paused = true
+		}
+
+		select {
+		case <-l.stop:
+			return
+		default:
+		}
+
+		log.Printf("(%s) invalidation stream down: %s\n", dc.addr, err)
+		switch err.code {
+		case NOT_ACTIVE_CONN:
+			return
+		case CMD_FAILED, AUTH_FAILED, NO_PERMISSION, CONN_REJECTED:
+			// Trying again won't help, nothing is kept until the connection is established again
+			<-l.stop
+			return
+		}
+
+		select {
+		case <-l.stop:
+			return
+		case <-time.After(trackingRetryInterval):
+		}
+	}
+}
+
+// Opens a stream with TRACKING and, unless it's sent keys by prefix, makes the connection's reads tracked
+// for it.
+func (dc *dCacheConn) openTracking(l *trackingLink) (*bufio.Reader, *DCacheError) {
+	conn, err := dc.dial()
+	if err != nil {
+		return nil, dCacheConnError(err)
+	}
+
+	r := bufio.NewReader(conn)
+	if err := dc.authenticate(conn, r); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !l.setConn(conn) {
+		conn.Close()
+		return nil, dCacheNotActiveConnError(dc.addr)
+	}
+
+	if err := protocol.WriteFrame(conn, command.TrackingCmdAsBytes(dc.opts.trackingPrefixes...)); err != nil {
+		conn.Close()
+		return nil, dCacheConnError(err)
+	}
+	res, err := protocol.ReadFrame(r, dc.opts.maxResponseSize)
+	if err != nil {
+		conn.Close()
+		return nil, dCacheConnError(err)
+	} else if len(res) != 1+8 || res[0] != core.CMD_EXEC_SUCCEEDED {
+		conn.Close()
+		return nil, dCacheNodeCmdFailedError("tracking", dc.addr, failureReason(res))
+	}
+
+	if len(dc.opts.trackingPrefixes) == 0 {
+		res, err := dc.execCmd(command.ClientTrackingCmdAsBytes(binary.LittleEndian.Uint64(res[1:])))
+		if err != nil {
+			conn.Close()
+			return nil, err
+		} else if len(res) == 0 || res[0] != core.CMD_EXEC_SUCCEEDED {
+			conn.Close()
+			return nil, dCacheNodeCmdFailedError("client tracking", dc.addr, failureReason(res))
+		}
+	}
+
+	return r, nil
+}
+
+// Reason a node gave for failing a command, the response without its status.
+func failureReason(res []byte) string {
+	if len(res) == 0 {
+		return "empty response"
+	}
+
+	return string(res[1:])
+}
+
+// Removes the keys the node sends from the near cache until the stream fails.
+func (dc *dCacheConn) receiveInvalidations(r *bufio.Reader) *DCacheError {
+	for {
+		msg, err := protocol.ReadFrame(r, dc.opts.maxResponseSize)
+		if err != nil {
+			return dCacheConnError(err)
+		}
+
+		keys, ok := scanResult(msg)
+		if !ok {
+			return dCacheNodeCmdFailedError("tracking", dc.addr, "invalid invalidation message")
+		}
+		for _, key := range keys {
+			dc.near.invalidate(key)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package client
+
+import (
+	"testing"
+	"time"
+)
+
+// Reads key until the near cache answers it, which it only does once the invalidation streams are up.
+func waitNearCache(t *testing.T, c *DCacheClient, key string) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		hits := c.NearCacheStats().Hits
+		c.Get(key)
+		if c.NearCacheStats().Hits != hits {
+			return
+		}
+	}
+
+	t.Fatalf("timed out waiting for the near cache to answer %s", key)
+}
+
+// Reads key until it has value, "" meaning not found.
+func waitValue(t *testing.T, c *DCacheClient, key, value string) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if v, _, _ := c.Get(key); string(v) == value {
+			return
+		}
+	}
+
+	t.Fatalf("timed out waiting for %s to be %q", key, value)
+}
+
+func TestTracking(t *testing.T) {
+	c := NewWithOptions(WithNodes(s1Addr, s2Addr), WithNearCache(NewNearCache(100, 64), time.Hour), WithTracking())
+	other := New(s1Addr, s2Addr)
+	for _, client := range []*DCacheClient{c, other} {
+		if err := client.Connect(2, time.Second); err != nil {
+			t.Fatalf("no error was expected on connect, but got: %s", err)
+		}
+		defer client.End()
+	}
+
+	other.Set("tracked", []byte("v1"), 60000)
+	waitNearCache(t, c, "tracked")
+
+	// Writes of other clients are seen long before the value expires
+	other.Set("tracked", []byte("v2"), 60000)
+	waitValue(t, c, "tracked", "v2")
+	if stats := c.NearCacheStats(); stats.Invalidations == 0 {
+		t.Errorf("expected the key to be invalidated, got %+v", stats)
+	}
+
+	waitNearCache(t, c, "tracked")
+	other.Delete("tracked")
+	waitValue(t, c, "tracked", "")
+	if ok, _ := c.Has("tracked"); ok {
+		t.Errorf("expected the key to be deleted")
+	}
+}
+
+func TestTrackingPrefixes(t *testing.T) {
+	c := NewWithOptions(WithNodes(s1Addr, s2Addr), WithNearCache(NewNearCache(100, 64), time.Hour), WithTracking("tracked:"))
+	other := New(s1Addr, s2Addr)
+	for _, client := range []*DCacheClient{c, other} {
+		if err := client.Connect(2, time.Second); err != nil {
+			t.Fatalf("no error was expected on connect, but got: %s", err)
+		}
+		defer client.End()
+	}
+
+	other.Set("tracked:1", []byte("v1"), 60000)
+	other.Set("untracked", []byte("v1"), 60000)
+	defer other.Delete("untracked")
+	waitNearCache(t, c, "tracked:1")
+
+	// Keys without the prefixes are never kept
+	hits := c.NearCacheStats().Hits
+	c.Get("untracked")
+	c.Get("untracked")
+	if stats := c.NearCacheStats(); stats.Hits != hits {
+		t.Errorf("expected keys without the prefixes to be read from the nodes, got %+v", stats)
+	}
+
+	other.Set("tracked:1", []byte("v2"), 60000)
+	waitValue(t, c, "tracked:1", "v2")
+	other.Delete("tracked:1")
+	waitValue(t, c, "tracked:1", "")
+}
+
+func TestNearCachePause(t *testing.T) {
+	n := &nearCache{cache: NewNearCache(100, 64), ttl: time.Hour}
+	n.set("paused", []byte("v1"), n.generation())
+
+	// Values kept before pausing are discarded, and nothing is kept while paused
+	n.pause()
+	if _, ok := n.get("paused"); ok {
+		t.Errorf("expected values to be discarded when pausing")
+	}
+	n.set("paused", []byte("v2"), n.generation())
+	if _, ok := n.get("paused"); ok {
+		t.Errorf("expected nothing to be kept while paused")
+	}
+
+	gen := n.generation()
+	n.resume()
+	n.set("paused", []byte("v3"), gen)
+	if _, ok := n.get("paused"); ok {
+		t.Errorf("expected values read before resuming not to be kept")
+	}
+	n.set("paused", []byte("v4"), n.generation())
+	if value, ok := n.get("paused"); !ok || string(value) != "v4" {
+		t.Errorf("expected %q, got %q, %t", "v4", value, ok)
+	}
+}
+
+func TestFailureReason(t *testing.T) {
+	if reason := failureReason(nil); reason != "empty response" {
+		t.Errorf("expected empty response, got %q", reason)
+	}
+	if reason := failureReason([]byte("\x05tracking disabled")); reason != "tracking disabled" {
+		t.Errorf("expected tracking disabled, got %q", reason)
+	}
+}
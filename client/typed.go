@@ -0,0 +1,112 @@
+package client
+
+import (
+	"context"
+
+	"github.com/joaovictorsl/dcache/client/codec"
+)
+
+// Typed stores values of type T in the nodes of a client, encoded with a codec.Codec. Errors are *DCacheError:
+// CODEC_FAILED when encoding or decoding a value fails, the error of the command otherwise.
+//
+// Commands can't be interrupted once sent, so when ctx is done first the command keeps running in the background:
+// a Set or Delete returning ctx.Err() may still take effect.
+type Typed[T any] struct {
+	client *DCacheClient
+	codec  codec.Codec
+	// Used by Set, in milliseconds
+	ttl uint32
+}
+
+// NewTyped returns a Typed storing values in c with cd. Set stores them for ttl milliseconds.
+func NewTyped[T any](c *DCacheClient, cd codec.Codec, ttl uint32) *Typed[T] {
+	return &Typed[T]{client: c, codec: cd, ttl: ttl}
+}
+
+// Set stores value under key for the ttl given to NewTyped.
+func (t *Typed[T]) Set(ctx context.Context, key string, value T) error {
+	return t.SetWithTTL(ctx, key, value, t.ttl)
+}
+
+// SetWithTTL stores value under key for ttl milliseconds.
+func (t *Typed[T]) SetWithTTL(ctx context.Context, key string, value T, ttl uint32) error {
+	data, encErr := t.codec.Marshal(value)
+	if encErr != nil {
+		return dCacheCodecError("encode", key, encErr)
+	}
+
+	_, err := withContext(ctx, func() (struct{}, *DCacheError) {
+		return struct{}{}, t.client.Set(key, data, ttl)
+	})
+	return err
+}
+
+// Get returns the value stored under key, false if there's none.
+func (t *Typed[T]) Get(ctx context.Context, key string) (T, bool, error) {
+	var value T
+	res, err := withContext(ctx, func() (storedValue, *DCacheError) {
+		data, found, err := t.client.Get(key)
+		return storedValue{data, found}, err
+	})
+	if !res.found {
+		return value, false, err
+	}
+
+	if decErr := t.codec.Unmarshal(res.data, &value); decErr != nil {
+		var zero T
+		return zero, false, dCacheCodecError("decode", key, decErr)
+	}
+
+	// A REPLICA_FAILED error comes with the value read from the replicas that answered
+	return value, true, err
+}
+
+// Result of a GET.
+type storedValue struct {
+	data  []byte
+	found bool
+}
+
+// Delete removes key.
+func (t *Typed[T]) Delete(ctx context.Context, key string) error {
+	_, err := withContext(ctx, func() (struct{}, *DCacheError) {
+		return struct{}{}, t.client.Delete(key)
+	})
+	return err
+}
+
+// Runs fn, returning early with ctx.Err() when ctx is done first. The error is an untyped nil when fn succeeds.
+func withContext[R any](ctx context.Context, fn func() (R, *DCacheError)) (R, error) {
+	var zero R
+	if err := ctx.Err(); err != nil {
+		return zero, err
+	}
+
+	if ctx.Done() == nil {
+		res, err := fn()
+		if err != nil {
+			return res, err
+		}
+		return res, nil
+	}
+
+	type result struct {
+		res R
+		err *DCacheError
+	}
+	done := make(chan result, 1)
+	go func() {
+		res, err := fn()
+		done <- result{res, err}
+	}()
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			return r.res, r.err
+		}
+		return r.res, nil
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+}
@@ -0,0 +1,88 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/joaovictorsl/dcache/client/codec"
+)
+
+type typedUser struct {
+	Name string
+	Age  int
+}
+
+func TestTyped(t *testing.T) {
+	c := New(s1Addr, s2Addr)
+	if err := c.Connect(2, time.Second); err != nil {
+		t.Fatalf("no error was expected on connect, but got: %s", err)
+	}
+	defer c.End()
+
+	ctx := context.Background()
+	want := typedUser{Name: "ana", Age: 31}
+	for name, cd := range map[string]codec.Codec{"json": codec.JSON, "gob": codec.Gob, "msgpack": codec.Msgpack} {
+		users := NewTyped[typedUser](c, cd, 60000)
+		key := "typed-" + name
+		if err := users.Set(ctx, key, want); err != nil {
+			t.Errorf("%s: no error was expected on set, but got: %s", name, err)
+		}
+
+		got, ok, err := users.Get(ctx, key)
+		if err != nil || !ok || !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: expected %v, got %v, %t, %v", name, want, got, ok, err)
+		}
+
+		if err := users.Delete(ctx, key); err != nil {
+			t.Errorf("%s: no error was expected on delete, but got: %s", name, err)
+		}
+		if _, ok, err := users.Get(ctx, key); ok || err != nil {
+			t.Errorf("%s: expected the key to be deleted, got %t, %v", name, ok, err)
+		}
+	}
+}
+
+func TestTypedErrors(t *testing.T) {
+	c := New(s1Addr, s2Addr)
+	if err := c.Connect(2, time.Second); err != nil {
+		t.Fatalf("no error was expected on connect, but got: %s", err)
+	}
+	defer c.End()
+
+	ctx := context.Background()
+	if err := c.Set("typed-invalid", []byte("not json"), 60000); err != nil {
+		t.Fatalf("no error was expected on set, but got: %s", err)
+	}
+
+	var dcerr *DCacheError
+	users := NewTyped[typedUser](c, codec.JSON, 60000)
+	_, ok, err := users.Get(ctx, "typed-invalid")
+	if ok || !errors.As(err, &dcerr) || dcerr.Code() != CODEC_FAILED {
+		t.Errorf("expected a codec error decoding an invalid value, got %t, %v", ok, err)
+	}
+	var syntaxErr *json.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Errorf("expected the json error to be unwrapped, got %v", err)
+	}
+
+	funcs := NewTyped[func()](c, codec.JSON, 60000)
+	if err := funcs.Set(ctx, "typed-func", func() {}); !errors.As(err, &dcerr) || dcerr.Code() != CODEC_FAILED {
+		t.Errorf("expected a codec error encoding a func, got %v", err)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := users.Set(canceled, "typed-canceled", typedUser{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+
+	ended := New(s1Addr)
+	ended.End()
+	if err := NewTyped[typedUser](ended, codec.JSON, 0).Set(ctx, "typed-ended", typedUser{}); !errors.As(err, &dcerr) || dcerr.Code() == CODEC_FAILED {
+		t.Errorf("expected a command error on an ended client, got %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package client
+
+import "github.com/joaovictorsl/dcache/client/ring"
+
+// Adds the node to the ring with its weight if it has one and the ring is weighted. Called holding mu or
+// before the client is shared.
+func (c *DCacheClient) addToRing(addr string) {
+	weight, ok := c.opts.weights[addr]
+	if w, weighted := c.dcring.(ring.Weighted); ok && weighted {
+		w.AddWithWeight(addr, weight)
+		return
+	}
+
+	c.dcring.Add(addr)
+}
+
+// Changes the weight of a node in the ring, from 1 to ring.TopWeight, see WithWeightedNodes. The ring changes
+// in a single step, so commands running meanwhile see either weight. In migration mode keys that move are
+// read from their previous owner until the migration finishes.
+func (c *DCacheClient) SetNodeWeight(addr string, weight int) *DCacheError {
+	w, ok := c.dcring.(ring.Weighted)
+	if !ok {
+		return dCacheUnweightedRingError()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.done {
+		return dCacheTerminatedClientError()
+	} else if _, ok := c.conns[addr]; !ok {
+		return dCacheNodeNotFoundError(addr)
+	}
+
+	weight = clamp(weight, 1, ring.TopWeight)
+	if current, ok := c.opts.weights[addr]; (ok && current == weight) || (!ok && weight == ring.TopWeight) {
+		return nil
+	}
+
+	c.recordRing()
+	c.opts.weights[addr] = weight
+	w.AddWithWeight(addr, weight)
+	return nil
+}
+
+// Returns the weight of every node in the ring, ring.TopWeight for the ones without one or if the ring isn't
+// weighted.
+func (c *DCacheClient) NodeWeights() map[string]int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	_, weighted := c.dcring.(ring.Weighted)
+	weights := make(map[string]int, len(c.conns))
+	for addr := range c.conns {
+		weights[addr] = ring.TopWeight
+		if weight, ok := c.opts.weights[addr]; ok && weighted {
+			weights[addr] = weight
+		}
+	}
+
+	return weights
+}
@@ -0,0 +1,99 @@
+package client
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/joaovictorsl/dcache/client/ring"
+)
+
+// Fraction of 10000 keys owned by addr.
+func ownedShare(c *DCacheClient, addr string) float64 {
+	owned := 0
+	for i := 0; i < 10000; i++ {
+		if node, _ := c.NodeFor("weighted" + strconv.Itoa(i)); node == addr {
+			owned++
+		}
+	}
+
+	return float64(owned) / 10000
+}
+
+func TestWeightedNodes(t *testing.T) {
+	c := NewWithOptions(WithWeightedNodes(map[string]int{s1Addr: 100, s2Addr: 25}))
+	if err := c.Connect(2, time.Second); err != nil {
+		t.Fatalf("no error was expected on connect, but got: %s", err)
+	}
+	defer c.End()
+
+	// 25 of 125
+	if share := ownedShare(c, s2Addr); share < 0.1 || share > 0.3 {
+		t.Errorf("expected %s to own about 20%% of the keys, it owns %.1f%%", s2Addr, 100*share)
+	}
+
+	// Lookups running while weights change always find an owner
+	stop := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+
+			if node, ok := c.NodeFor("weighted" + strconv.Itoa(i)); !ok || (node != s1Addr && node != s2Addr) {
+				t.Errorf("expected an owner while weights change, got %q", node)
+				return
+			}
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		if err := c.SetNodeWeight(s2Addr, 25+i); err != nil {
+			t.Errorf("no error was expected changing the weight, but got: %s", err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+
+	if err := c.SetNodeWeight(s2Addr, 100); err != nil {
+		t.Errorf("no error was expected changing the weight, but got: %s", err)
+	} else if share := ownedShare(c, s2Addr); share < 0.4 || share > 0.6 {
+		t.Errorf("expected %s to own about half the keys, it owns %.1f%%", s2Addr, 100*share)
+	}
+
+	if err := c.AddWeightedNode(s3Addr, 50, 2, time.Second); err != nil {
+		t.Errorf("no error was expected adding a node, but got: %s", err)
+	}
+	// Weights are kept when nodes are added again
+	c.RemoveNode(s3Addr)
+	if err := c.AddNode(s3Addr, 2, time.Second); err != nil {
+		t.Errorf("no error was expected adding a node, but got: %s", err)
+	}
+	weights := c.NodeWeights()
+	if weights[s1Addr] != 100 || weights[s2Addr] != 100 || weights[s3Addr] != 50 {
+		t.Errorf("expected weights 100, 100 and 50, got %v", weights)
+	}
+
+	key := "weighted"
+	if err := c.Set(key, []byte("value"), 10000); err != nil {
+		t.Errorf("no error was expected on SET operation, but got: %s", err)
+	} else if res, ok, err := c.Get(key); err != nil || !ok || string(res) != "value" {
+		t.Errorf("expected GET to return the value, got %q, %v, %v", res, ok, err)
+	}
+
+	if err := c.SetNodeWeight(s4Addr, 50); err == nil || err.Code() != CONN_NOT_FOUND {
+		t.Errorf("expected an error changing the weight of a node not in the ring, got %v", err)
+	}
+
+	unweighted := NewWithOptions(WithWeightedNodes(map[string]int{s1Addr: 50}), WithRing(ring.NewRendezvous()))
+	if err := unweighted.SetNodeWeight(s1Addr, 100); err == nil || err.Code() != UNWEIGHTED_RING {
+		t.Errorf("expected an error changing weights of a ring without them, got %v", err)
+	} else if weights := unweighted.NodeWeights(); weights[s1Addr] != ring.TopWeight {
+		t.Errorf("expected weights to be ignored, got %v", weights)
+	}
+}
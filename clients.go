@@ -0,0 +1,152 @@
+package dcache
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/joaovictorsl/dcache/core"
+	"github.com/joaovictorsl/dcache/core/protocol"
+)
+
+var (
+	errMaxConns          = errors.New(core.MAX_CONNS_REACHED)
+	errMaxConnsPerClient = errors.New(core.MAX_CONNS_REACHED + " for client")
+)
+
+// How long the server waits for a rejected connection to receive the rejection
+const rejectTimeout = time.Second
+
+// A connection accepted by the server.
+type clientConn struct {
+	net.Conn
+	id        uint64
+	host      string
+	createdAt time.Time
+	// Unix nano time of the last command
+	lastCmdAt atomic.Int64
+	cmds      atomic.Uint64
+	// User the connection authenticated as, empty if it didn't. Only used by the connection's handler.
+	username string
+	// Invalidation stream the keys the connection reads are sent to, 0 if they aren't tracked. Only used
+	// by the connection's handler.
+	trackingID uint64
+}
+
+// A connected client as listed by Server.Clients.
+type ClientInfo struct {
+	ID   uint64
+	Addr string
+	// Connections open from the same host
+	HostConns int
+	// Time since the connection was accepted
+	Age time.Duration
+	// Time since the last command, or since the connection was accepted if it sent none
+	Idle     time.Duration
+	Commands uint64
+}
+
+// Records a command sent by the client.
+func (cc *clientConn) touch() {
+	cc.lastCmdAt.Store(time.Now().UnixNano())
+	cc.cmds.Add(1)
+}
+
+// Registers an accepted connection, fails if MaxConns or MaxConnsPerClient was reached.
+func (s *Server) acquireConn(conn net.Conn) (*clientConn, error) {
+	cfg := s.config()
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		host = conn.RemoteAddr().String()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closing {
+		return nil, ErrServerClosed
+	} else if cfg.MaxConns != 0 && uint(len(s.clients)) >= cfg.MaxConns {
+		return nil, errMaxConns
+	} else if cfg.MaxConnsPerClient != 0 && uint(s.hostConns[host]) >= cfg.MaxConnsPerClient {
+		return nil, errMaxConnsPerClient
+	}
+
+	s.nextClientID++
+	cc := &clientConn{
+		Conn:      conn,
+		id:        s.nextClientID,
+		host:      host,
+		createdAt: time.Now(),
+	}
+	cc.lastCmdAt.Store(cc.createdAt.UnixNano())
+
+	s.clients[cc.id] = cc
+	s.hostConns[host]++
+	s.handlers.Add(1)
+	return cc, nil
+}
+
+func (s *Server) releaseConn(cc *clientConn) {
+	s.mu.Lock()
+	delete(s.clients, cc.id)
+	if s.hostConns[cc.host]--; s.hostConns[cc.host] == 0 {
+		delete(s.hostConns, cc.host)
+	}
+	s.mu.Unlock()
+
+	cc.Close()
+	s.handlers.Done()
+}
+
+// Tells the client why its connection was refused and closes it.
+func (s *Server) rejectConn(conn net.Conn, reason error) {
+	defer conn.Close()
+
+	conn.SetWriteDeadline(time.Now().Add(rejectTimeout))
+	protocol.WriteFrame(conn, append([]byte{core.MAX_CONNS_REACHED_CODE}, reason.Error()...))
+}
+
+// Lists connected clients ordered by connection time.
+func (s *Server) Clients() []ClientInfo {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	clients := make([]ClientInfo, 0, len(s.clients))
+	for _, cc := range s.clients {
+		clients = append(clients, ClientInfo{
+			ID:        cc.id,
+			Addr:      cc.RemoteAddr().String(),
+			HostConns: s.hostConns[cc.host],
+			Age:       now.Sub(cc.createdAt),
+			Idle:      now.Sub(time.Unix(0, cc.lastCmdAt.Load())),
+			Commands:  cc.cmds.Load(),
+		})
+	}
+
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].ID < clients[j].ID
+	})
+
+	return clients
+}
+
+// Answers CLIENT LIST with a line per client, e.g.
+//
+//	id=1 addr=127.0.0.1:52000 host_conns=2 age=30s idle=2s cmds=15
+func (s *Server) clientList() []byte {
+	lines := make([]string, 0)
+	for _, c := range s.Clients() {
+		lines = append(lines, fmt.Sprintf(
+			"id=%d addr=%s host_conns=%d age=%ds idle=%ds cmds=%d",
+			c.ID, c.Addr, c.HostConns, int(c.Age.Seconds()), int(c.Idle.Seconds()), c.Commands,
+		))
+	}
+
+	return append([]byte{core.CMD_EXEC_SUCCEEDED}, strings.Join(lines, "\n")...)
+}
@@ -0,0 +1,341 @@
+package dcache
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joaovictorsl/dcache/client/ring"
+	"github.com/joaovictorsl/dcache/core"
+	"github.com/joaovictorsl/dcache/core/command"
+	"github.com/joaovictorsl/dcache/core/protocol"
+)
+
+// Gossip intervals a member's heartbeat may stop increasing for before it's suspected, considered dead and
+// forgotten.
+const (
+	gossipSuspectAfter = 5
+	gossipDeadAfter    = 15
+	gossipForgetAfter  = 60
+)
+
+// A cluster member as seen by this server.
+type Member struct {
+	Addr string
+	// core.MEMBER_ALIVE, core.MEMBER_SUSPECT or core.MEMBER_DEAD
+	State string
+	// Whether it's this server
+	Self bool
+}
+
+type member struct {
+	heartbeat uint64
+	// When the heartbeat last increased, as seen by this server
+	updated time.Time
+	state   string
+}
+
+// Members of the cluster this server belongs to, kept up to date by gossipLoop.
+//
+// Every member increases its own heartbeat once per gossip interval and sends the members it knows, with
+// their heartbeats, to another one, which answers with the ones it knows. Heartbeats start from the time
+// a member started, so a member that restarts is newer than what others remember of it.
+type membership struct {
+	self string
+
+	mu      sync.Mutex
+	members map[string]*member
+	// Ring of the members that aren't dead, built the same way clients build theirs
+	ring *ring.ConsistentHash
+}
+
+func newMembership(self string) *membership {
+	m := &membership{self: self, members: make(map[string]*member)}
+	m.members[self] = &member{heartbeat: uint64(time.Now().UnixNano()), updated: time.Now(), state: core.MEMBER_ALIVE}
+	m.buildRing()
+	return m
+}
+
+// Rebuilds the ring after members join or die. Called holding mu.
+func (m *membership) buildRing() {
+	m.ring = ring.NewConsistentHash()
+	for addr, mb := range m.members {
+		if mb.state != core.MEMBER_DEAD {
+			m.ring.Add(addr)
+		}
+	}
+}
+
+// Returns the first member owning key, "" if this server is one of its n owners.
+func (m *membership) movedTo(key string, n int) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	owners := m.ring.GetN(key, n)
+	for _, owner := range owners {
+		if owner == m.self {
+			return ""
+		}
+	}
+
+	if len(owners) == 0 {
+		return ""
+	}
+	return owners[0]
+}
+
+// Members gossiped to others, dead ones are left out so they aren't brought back by members that forgot them.
+func (m *membership) digest() []command.GossipMember {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	members := make([]command.GossipMember, 0, len(m.members))
+	for addr, mb := range m.members {
+		if mb.state != core.MEMBER_DEAD {
+			members = append(members, command.GossipMember{Addr: addr, Heartbeat: mb.heartbeat})
+		}
+	}
+
+	return members
+}
+
+// Takes in the members gossiped by another member, returning the ones that came up.
+func (m *membership) merge(members []command.GossipMember) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	up := make([]string, 0)
+	for _, gm := range members {
+		if gm.Addr == m.self {
+			continue
+		}
+
+		mb, ok := m.members[gm.Addr]
+		if ok && gm.Heartbeat <= mb.heartbeat {
+			continue
+		}
+
+		if !ok || mb.state != core.MEMBER_ALIVE {
+			up = append(up, gm.Addr)
+		}
+		m.members[gm.Addr] = &member{heartbeat: gm.Heartbeat, updated: now, state: core.MEMBER_ALIVE}
+	}
+
+	if len(up) != 0 {
+		m.buildRing()
+	}
+	return up
+}
+
+// Increases the heartbeat of this server and updates the state of the others based on how long ago their
+// heartbeat increased, forgetting the ones dead for long. Returns the members whose state changed.
+func (m *membership) tick(interval time.Duration) map[string]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	changed := make(map[string]string)
+	for addr, mb := range m.members {
+		if addr == m.self {
+			mb.heartbeat++
+			mb.updated = now
+			continue
+		}
+
+		state := core.MEMBER_ALIVE
+		switch age := now.Sub(mb.updated); {
+		case age >= gossipForgetAfter*interval:
+			// Already out of the ring
+			delete(m.members, addr)
+			continue
+		case age >= gossipDeadAfter*interval:
+			state = core.MEMBER_DEAD
+		case age >= gossipSuspectAfter*interval:
+			state = core.MEMBER_SUSPECT
+		}
+
+		if state != mb.state {
+			mb.state = state
+			changed[addr] = state
+		}
+	}
+
+	for _, state := range changed {
+		if state == core.MEMBER_DEAD {
+			m.buildRing()
+			break
+		}
+	}
+	return changed
+}
+
+// Picks the member to gossip with: one that's up or a seed not known to be up, "" if there's none.
+func (m *membership) target(seeds []string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	candidates := make([]string, 0, len(m.members)+len(seeds))
+	for addr, mb := range m.members {
+		if addr != m.self && mb.state != core.MEMBER_DEAD {
+			candidates = append(candidates, addr)
+		}
+	}
+	for _, seed := range seeds {
+		if mb, ok := m.members[seed]; seed != m.self && (!ok || mb.state == core.MEMBER_DEAD) {
+			candidates = append(candidates, seed)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return ""
+	}
+	return candidates[rand.Intn(len(candidates))]
+}
+
+func (m *membership) list() []Member {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	members := make([]Member, 0, len(m.members))
+	for addr, mb := range m.members {
+		members = append(members, Member{Addr: addr, State: mb.state, Self: addr == m.self})
+	}
+
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].Addr < members[j].Addr
+	})
+
+	return members
+}
+
+// Returns the cluster members known by this server, sorted by address. Empty if it's not part of a cluster.
+func (s *Server) Members() []Member {
+	s.mu.Lock()
+	m := s.cluster
+	s.mu.Unlock()
+
+	if m == nil {
+		return nil
+	}
+	return m.list()
+}
+
+// Gossips with another member every ClusterGossipInterval until the server shuts down.
+func (s *Server) gossipLoop(m *membership) {
+	defer s.background.Done()
+
+	s.infof("cluster member at %s\n", m.self)
+	for {
+		cfg := s.config()
+		select {
+		case <-time.After(cfg.ClusterGossipInterval):
+		case <-s.done:
+			return
+		}
+
+		for addr, state := range m.tick(cfg.ClusterGossipInterval) {
+			s.infof("cluster member %s is %s\n", addr, state)
+		}
+
+		target := m.target(cfg.ClusterSeeds)
+		if target == "" {
+			continue
+		}
+
+		if err := s.gossip(cfg, m, target); err != nil {
+			s.debugf("gossip with %s failed: %s\n", target, err)
+		}
+	}
+}
+
+// Exchanges members with the member at addr.
+func (s *Server) gossip(cfg Config, m *membership, addr string) error {
+	conn, err := dialPeer(cfg, addr, cfg.ClusterUser, cfg.ClusterPassword, replTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	res, err := conn.exec("GOSSIP", command.GossipCmdAsBytes(m.digest()), replTimeout)
+	if err != nil {
+		return err
+	}
+
+	members, err := protocol.ParseGossipMembers(res)
+	if err != nil {
+		return fmt.Errorf("invalid response: %s", err)
+	}
+
+	s.membersUp(m.merge(members))
+	return nil
+}
+
+func (s *Server) membersUp(addrs []string) {
+	for _, addr := range addrs {
+		s.infof("cluster member %s is %s\n", addr, core.MEMBER_ALIVE)
+	}
+}
+
+// Returns the member owning the key of cmd when the server checks ownership and isn't one of the key's owners.
+//
+// DUMP is answered for any key so clients can read from previous owners while migrating.
+func (s *Server) movedTo(cmd command.Command) (string, bool) {
+	cfg := s.config()
+	if !cfg.ClusterRedirect {
+		return "", false
+	}
+
+	key, ok := commandKey(cmd)
+	if _, dump := cmd.(*command.DumpCommand); !ok || dump {
+		return "", false
+	}
+
+	s.mu.Lock()
+	m := s.cluster
+	s.mu.Unlock()
+	if m == nil {
+		return "", false
+	}
+
+	owner := m.movedTo(key, int(cfg.ClusterReplicas))
+	return owner, owner != ""
+}
+
+// Answers GOSSIP with the members this server knows, after taking in the ones sent.
+func (s *Server) gossipCommand(c *command.GossipCommand) []byte {
+	s.mu.Lock()
+	m := s.cluster
+	s.mu.Unlock()
+
+	if m == nil {
+		return append([]byte{core.CMD_EXEC_FAILED}, "not a cluster member"...)
+	}
+
+	s.membersUp(m.merge(c.Members))
+	return append([]byte{core.CMD_EXEC_SUCCEEDED}, command.GossipMembersAsBytes(m.digest())...)
+}
+
+// Answers CLUSTER NODES with a line per member, e.g.
+//
+//	10.0.0.1:3000 alive myself
+//	10.0.0.2:3000 suspect
+func (s *Server) clusterNodesCommand() []byte {
+	members := s.Members()
+	if members == nil {
+		return append([]byte{core.CMD_EXEC_FAILED}, "not a cluster member"...)
+	}
+
+	lines := make([]string, len(members))
+	for i, mb := range members {
+		lines[i] = fmt.Sprintf("%s %s", mb.Addr, mb.State)
+		if mb.Self {
+			lines[i] += " myself"
+		}
+	}
+
+	return append([]byte{core.CMD_EXEC_SUCCEEDED}, strings.Join(lines, "\n")...)
+}
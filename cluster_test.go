@@ -0,0 +1,182 @@
+package dcache
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joaovictorsl/dcache/client"
+	"github.com/joaovictorsl/dcache/client/ring"
+	"github.com/joaovictorsl/dcache/core"
+	"github.com/joaovictorsl/dcache/core/command"
+)
+
+// Returns the ports of n members and their addresses, sorted like Members sorts them.
+func clusterAddrs(t *testing.T, n int) ([]uint16, []string) {
+	ports := freePorts(t, n)
+	// Addresses only differ in their port
+	sort.Slice(ports, func(i, j int) bool {
+		return fmt.Sprint(ports[i]) < fmt.Sprint(ports[j])
+	})
+
+	addrs := make([]string, n)
+	for i, port := range ports {
+		addrs[i] = fmt.Sprint("127.0.0.1:", port)
+	}
+	return ports, addrs
+}
+
+func TestServerCluster(t *testing.T) {
+	ports, addrs := clusterAddrs(t, 3)
+	servers := make([]*Server, len(addrs))
+	for i, port := range ports {
+		servers[i], _ = startTestServer(t, WithPort(port), WithAuthPassword("secret"), WithCluster(addrs[i], addrs[0]),
+			WithClusterAuth("", "secret"), WithGossipInterval(20*time.Millisecond))
+		defer servers[i].Shutdown(context.Background())
+	}
+
+	// Whether every server sees the members in addrs as state
+	membersAre := func(servers []*Server, addrs []string, state string) func() bool {
+		return func() bool {
+			for _, s := range servers {
+				states := make(map[string]string)
+				for _, m := range s.Members() {
+					states[m.Addr] = m.State
+				}
+				for _, addr := range addrs {
+					if states[addr] != state {
+						return false
+					}
+				}
+			}
+			return true
+		}
+	}
+
+	t.Run("should join through the seed", func(t *testing.T) {
+		eventually(t, "every member to be alive", membersAre(servers, addrs, core.MEMBER_ALIVE))
+
+		conn := dialTestServer(t, servers[1])
+		defer conn.Close()
+		conn.exec(command.AuthCmdAsBytes("", "secret"))
+
+		res, err := conn.exec(command.ClusterNodesCmdAsBytes())
+		if err != nil || res[0] != core.CMD_EXEC_SUCCEEDED {
+			t.Fatalf("CLUSTER NODES = %q, %v, want success", res, err)
+		}
+
+		expected := strings.Join([]string{addrs[0] + " alive", addrs[1] + " alive myself", addrs[2] + " alive"}, "\n")
+		if string(res[1:]) != expected {
+			t.Errorf("CLUSTER NODES = %q, want %q", res[1:], expected)
+		}
+	})
+
+	t.Run("should refresh the client ring", func(t *testing.T) {
+		c := client.NewWithOptions(client.WithNodes(addrs[0]), client.WithAuth("", "secret"))
+		if err := c.Connect(0, 0); err != nil {
+			t.Fatalf("failed to connect: %s", err)
+		}
+		defer c.End()
+
+		if err := c.RefreshRing(0, 0); err != nil {
+			t.Fatalf("RefreshRing returned error %q", err)
+		}
+		if nodes := c.Nodes(); len(nodes) != 3 || !nodes[addrs[2]] {
+			t.Errorf("expected every member in the ring, got %v", nodes)
+		}
+
+		servers[2].Shutdown(context.Background())
+		eventually(t, "the stopped member to be dead", membersAre(servers[:2], addrs[2:], core.MEMBER_DEAD))
+
+		if err := c.RefreshRing(0, 0); err != nil {
+			t.Fatalf("RefreshRing returned error %q", err)
+		}
+		if _, ok := c.Nodes()[addrs[2]]; ok || len(c.Nodes()) != 2 {
+			t.Errorf("expected the dead member to be removed, got %v", c.Nodes())
+		}
+	})
+
+	t.Run("should fail when not a cluster member", func(t *testing.T) {
+		s, _ := startTestServer(t)
+		defer s.Shutdown(context.Background())
+		conn := dialTestServer(t, s)
+		defer conn.Close()
+
+		for _, cmd := range [][]byte{command.ClusterNodesCmdAsBytes(), command.GossipCmdAsBytes(nil)} {
+			if res, err := conn.exec(cmd); err != nil || res[0] != core.CMD_EXEC_FAILED {
+				t.Errorf("%v = %q, %v, want failure", cmd, res, err)
+			}
+		}
+	})
+}
+
+func TestServerClusterRedirect(t *testing.T) {
+	ports, addrs := clusterAddrs(t, 2)
+	servers := make([]*Server, len(addrs))
+	for i, port := range ports {
+		servers[i], _ = startTestServer(t, WithPort(port), WithCluster(addrs[i], addrs[0]), WithGossipInterval(20*time.Millisecond),
+			WithClusterRedirect(1))
+		defer servers[i].Shutdown(context.Background())
+	}
+	eventually(t, "members to know each other", func() bool {
+		return len(servers[0].Members()) == 2 && len(servers[1].Members()) == 2
+	})
+
+	clusterRing := ring.NewConsistentHash()
+	clusterRing.Add(addrs[0])
+	clusterRing.Add(addrs[1])
+	key := ""
+	for i := 0; key == ""; i++ {
+		if owner, _ := clusterRing.Get(fmt.Sprint("redirected-", i)); owner == addrs[1] {
+			key = fmt.Sprint("redirected-", i)
+		}
+	}
+
+	conn := dialTestServer(t, servers[0])
+	defer conn.Close()
+
+	t.Run("should answer MOVED for keys owned by another member", func(t *testing.T) {
+		res, err := conn.exec(command.GetCmdAsBytes(key))
+		if err != nil || res[0] != core.MOVED_CODE || string(res[1:]) != addrs[1] {
+			t.Errorf("GET = %q, %v, want MOVED to %s", res, err, addrs[1])
+		}
+
+		// Copies left by ring changes are deleted anyway
+		servers[0].keys.Set(key, []byte("stale"), time.Minute)
+		if res, err := conn.exec(command.DeleteCmdAsBytes(key)); err != nil || res[0] != core.MOVED_CODE {
+			t.Errorf("DELETE = %q, %v, want MOVED", res, err)
+		} else if servers[0].keys.Has(key) {
+			t.Errorf("expected DELETE to remove the local copy")
+		}
+	})
+
+	t.Run("should make clients follow the redirect", func(t *testing.T) {
+		// Knows a single member
+		c := client.New(addrs[0])
+		if err := c.Connect(0, 0); err != nil {
+			t.Fatalf("failed to connect: %s", err)
+		}
+		defer c.End()
+
+		if err := c.Set(key, []byte("Bar"), 60000); err != nil {
+			t.Fatalf("SET returned error %q", err)
+		}
+		if !servers[1].keys.Has(key) || servers[0].keys.Has(key) {
+			t.Errorf("expected %s to be stored only by its owner", key)
+		}
+
+		p := c.Pipeline()
+		p.Get(key)
+		if r := p.Exec()[0]; r.Err != nil || !r.Found || string(r.Value) != "Bar" {
+			t.Errorf("pipelined GET = %q, %v, %v, want Bar", r.Value, r.Found, r.Err)
+		}
+
+		eventually(t, "the ring to be refreshed", func() bool {
+			owner, _ := c.NodeFor(key)
+			return owner == addrs[1]
+		})
+	})
+}
@@ -0,0 +1,270 @@
+// Command dcache-bench drives a configurable load against DCache nodes through client.DCacheClient
+// and reports throughput, latency percentiles and errors per node.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/joaovictorsl/dcache/client"
+)
+
+var (
+	nodes       = flag.String("nodes", "127.0.0.1:3000", "comma separated list of node addresses")
+	duration    = flag.Duration("duration", 10*time.Second, "how long to run, ignored when -requests is set")
+	requests    = flag.Int64("requests", 0, "total amount of requests, 0 runs for -duration")
+	concurrency = flag.Int("concurrency", 50, "amount of concurrent workers")
+	pipeline    = flag.Int("pipeline", 1, "commands sent per round trip by each worker")
+	mixFlag     = flag.String("mix", "get:80,set:20", "operation weights, e.g. get:70,set:20,has:5,delete:5")
+	keys        = flag.Uint64("keys", 100_000, "size of the key space")
+	keyPrefix   = flag.String("key-prefix", "bench:", "prefix of generated keys")
+	dist        = flag.String("dist", DIST_UNIFORM, "key distribution: uniform or zipf")
+	zipfS       = flag.Float64("zipf-s", 1.1, "zipf exponent, must be greater than 1")
+	valueFlag   = flag.String("value-size", "100", "value size in bytes, fixed (100) or a range (64-1024)")
+	ttl         = flag.Duration("ttl", time.Hour, "ttl of SET commands")
+	prefill     = flag.Bool("prefill", false, "set every key before starting")
+	retries     = flag.Uint("retries", 0, "connection attempts after the first one fails")
+	useTLS      = flag.Bool("tls", false, "connect to nodes using TLS")
+	tlsCAFile   = flag.String("tls-ca-file", "", "CAs used to verify node certificates, system CAs by default")
+	tlsCertFile = flag.String("tls-cert-file", "", "client certificate for nodes that require one")
+	tlsKeyFile  = flag.String("tls-key-file", "", "client certificate key")
+	user        = flag.String("user", "", "user to authenticate as, the default user if empty")
+	password    = flag.String("password", "", "secret to authenticate with, read from $DCACHE_PASSWORD if empty")
+	loadFactor  = flag.Float64("load-factor", 0, "bound the commands in flight to each node to this factor of the average, 0 disables it")
+	nearKeys    = flag.Int("near-keys", 0, "keys kept by a near cache in the client, 0 disables it; pipelined reads bypass it")
+	nearTTL     = flag.Duration("near-ttl", time.Second, "how long the near cache keeps values")
+)
+
+// Settings shared by all workers
+type benchmark struct {
+	client    *client.DCacheClient
+	mix       *mix
+	values    valueSize
+	valueData []byte
+	// Remaining requests when running a fixed amount, negative means no more work
+	remaining atomic.Int64
+	deadline  time.Time
+}
+
+func main() {
+	flag.Parse()
+	log.SetOutput(io.Discard)
+
+	m, err := parseMix(*mixFlag)
+	if err != nil {
+		fatal(err)
+	}
+
+	values, err := parseValueSize(*valueFlag)
+	if err != nil {
+		fatal(err)
+	}
+
+	if *concurrency < 1 || *pipeline < 1 || *keys < 2 {
+		fatal(fmt.Errorf("concurrency and pipeline must be at least 1 and keys at least 2"))
+	}
+
+	// Validates the distribution before connecting
+	if _, err := newKeyGenerator(*dist, rand.New(rand.NewSource(0)), *keys, *zipfS); err != nil {
+		fatal(err)
+	}
+
+	c, err := newClient()
+	if err != nil {
+		fatal(err)
+	}
+	if err := c.Connect(*retries, time.Second); err != nil {
+		fatal(err)
+	}
+	defer c.End()
+
+	b := &benchmark{
+		client:    c,
+		mix:       m,
+		values:    values,
+		valueData: make([]byte, values.max),
+	}
+	rand.New(rand.NewSource(time.Now().UnixNano())).Read(b.valueData)
+
+	if *prefill {
+		fmt.Fprintf(os.Stderr, "prefilling %d keys\n", *keys)
+		b.prefill()
+	}
+
+	b.remaining.Store(*requests)
+	start := time.Now()
+	b.deadline = start.Add(*duration)
+
+	results := make([]*stats, *concurrency)
+	wg := &sync.WaitGroup{}
+	for i := range results {
+		results[i] = newStats()
+		wg.Add(1)
+		go func(s *stats, seed int64) {
+			defer wg.Done()
+			b.work(s, seed)
+		}(results[i], start.UnixNano()+int64(i))
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	total := newStats()
+	for _, s := range results {
+		total.merge(s)
+	}
+	total.report(os.Stdout, elapsed)
+	if *loadFactor != 0 {
+		reportSpills(os.Stdout, c.LoadStats())
+	}
+	if *nearKeys != 0 {
+		reportNearCache(os.Stdout, c.NearCacheStats())
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "error: %s\n", err)
+	os.Exit(1)
+}
+
+func (b *benchmark) key(idx uint64) string {
+	return fmt.Sprintf("%s%d", *keyPrefix, idx)
+}
+
+func (b *benchmark) prefill() {
+	p := b.client.Pipeline()
+	r := rand.New(rand.NewSource(0))
+	for idx := uint64(0); idx < *keys; idx++ {
+		p.Set(b.key(idx), b.valueData[:b.values.pick(r)], uint32(ttl.Milliseconds()))
+		if p.Len() == 1000 || idx == *keys-1 {
+			p.Exec()
+		}
+	}
+}
+
+// Claims n requests, returns how many can be sent, 0 means the benchmark is over.
+func (b *benchmark) claim(n int) int {
+	if *requests == 0 {
+		if time.Now().After(b.deadline) {
+			return 0
+		}
+		return n
+	}
+
+	left := b.remaining.Add(-int64(n))
+	if left >= 0 {
+		return n
+	} else if left > -int64(n) {
+		return n + int(left)
+	}
+
+	return 0
+}
+
+func (b *benchmark) work(s *stats, seed int64) {
+	r := rand.New(rand.NewSource(seed))
+	gen, _ := newKeyGenerator(*dist, r, *keys, *zipfS)
+
+	for {
+		n := b.claim(*pipeline)
+		if n == 0 {
+			return
+		}
+
+		if *pipeline == 1 {
+			b.single(s, r, b.key(gen.Uint64()))
+		} else {
+			b.batch(s, r, gen, n)
+		}
+	}
+}
+
+// Sends a single command and waits for its response.
+func (b *benchmark) single(s *stats, r *rand.Rand, key string) {
+	op := b.mix.pick(r)
+	node, _ := b.client.NodeFor(key)
+
+	var found bool
+	var err *client.DCacheError
+	start := time.Now()
+	switch op {
+	case OP_GET:
+		_, found, err = b.client.Get(key)
+	case OP_SET:
+		err = b.client.Set(key, b.valueData[:b.values.pick(r)], uint32(ttl.Milliseconds()))
+	case OP_HAS:
+		found, err = b.client.Has(key)
+	case OP_DELETE:
+		err = b.client.Delete(key)
+	}
+
+	s.record(op, node, time.Since(start), found, err != nil)
+}
+
+// Sends n commands in a pipeline, each command is recorded with the latency of the whole round trip.
+func (b *benchmark) batch(s *stats, r *rand.Rand, gen keyGenerator, n int) {
+	p := b.client.Pipeline()
+	ops := make([]string, n)
+	for i := range ops {
+		key := b.key(gen.Uint64())
+		ops[i] = b.mix.pick(r)
+		switch ops[i] {
+		case OP_GET:
+			p.Get(key)
+		case OP_SET:
+			p.Set(key, b.valueData[:b.values.pick(r)], uint32(ttl.Milliseconds()))
+		case OP_HAS:
+			p.Has(key)
+		case OP_DELETE:
+			p.Delete(key)
+		}
+	}
+
+	start := time.Now()
+	results := p.Exec()
+	latency := time.Since(start)
+
+	for i, res := range results {
+		s.record(ops[i], res.Node, latency, res.Found, res.Err != nil)
+	}
+}
+
+// Creates a client for the nodes flag, using TLS if any of the TLS flags is set and authenticating
+// if a password is given.
+func newClient() (*client.DCacheClient, error) {
+	opts := []client.Option{client.WithNodes(strings.Split(*nodes, ",")...)}
+	if *useTLS || *tlsCAFile != "" || *tlsCertFile != "" || *tlsKeyFile != "" {
+		cfg, err := client.NewTLSConfig(*tlsCAFile, *tlsCertFile, *tlsKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, client.WithTLS(cfg))
+	}
+
+	secret := *password
+	if secret == "" {
+		secret = os.Getenv("DCACHE_PASSWORD")
+	}
+	if secret != "" {
+		opts = append(opts, client.WithAuth(*user, secret))
+	}
+
+	if *loadFactor != 0 {
+		opts = append(opts, client.WithBoundedLoads(*loadFactor))
+	}
+
+	if *nearKeys != 0 {
+		// Already validated by main
+		values, _ := parseValueSize(*valueFlag)
+		opts = append(opts, client.WithNearCache(client.NewNearCache(*nearKeys, values.max), *nearTTL))
+	}
+
+	return client.NewWithOptions(opts...), nil
+}
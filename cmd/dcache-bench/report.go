@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/joaovictorsl/dcache/client"
+	"github.com/joaovictorsl/dcache/client/ring"
+)
+
+// Used for errors that happened before a node was picked
+const noNode = "(none)"
+
+// Results collected by a single worker, merged once the benchmark ends.
+type stats struct {
+	latencies []time.Duration
+	ops       map[string]int
+	hits      int
+	misses    int
+	// Requests and errors keyed by node address
+	nodeRequests map[string]int
+	nodeErrors   map[string]int
+}
+
+func newStats() *stats {
+	return &stats{
+		latencies:    make([]time.Duration, 0, 1024),
+		ops:          make(map[string]int),
+		nodeRequests: make(map[string]int),
+		nodeErrors:   make(map[string]int),
+	}
+}
+
+// Records a finished operation, found is only meaningful for GETs.
+func (s *stats) record(op, node string, latency time.Duration, found, failed bool) {
+	if node == "" {
+		node = noNode
+	}
+
+	s.latencies = append(s.latencies, latency)
+	s.ops[op]++
+	s.nodeRequests[node]++
+
+	if failed {
+		s.nodeErrors[node]++
+		return
+	}
+
+	if op == OP_GET {
+		if found {
+			s.hits++
+		} else {
+			s.misses++
+		}
+	}
+}
+
+func (s *stats) merge(other *stats) {
+	s.latencies = append(s.latencies, other.latencies...)
+	s.hits += other.hits
+	s.misses += other.misses
+	for op, n := range other.ops {
+		s.ops[op] += n
+	}
+	for node, n := range other.nodeRequests {
+		s.nodeRequests[node] += n
+	}
+	for node, n := range other.nodeErrors {
+		s.nodeErrors[node] += n
+	}
+}
+
+// Returns the latency below which p percent of the sorted latencies fall.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	idx := int(float64(len(sorted))*p/100+0.5) - 1
+	if idx < 0 {
+		idx = 0
+	} else if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+
+	return sorted[idx]
+}
+
+func (s *stats) report(w io.Writer, elapsed time.Duration) {
+	sort.Slice(s.latencies, func(i, j int) bool {
+		return s.latencies[i] < s.latencies[j]
+	})
+
+	total := len(s.latencies)
+	fmt.Fprintf(w, "requests:   %d in %s (%.0f req/s)\n", total, elapsed.Round(time.Millisecond), float64(total)/elapsed.Seconds())
+
+	if total > 0 {
+		fmt.Fprintf(w, "latency:    p50 %s  p99 %s  p999 %s  max %s\n",
+			percentile(s.latencies, 50),
+			percentile(s.latencies, 99),
+			percentile(s.latencies, 99.9),
+			s.latencies[total-1],
+		)
+	}
+
+	fmt.Fprintf(w, "operations:")
+	for _, op := range []string{OP_GET, OP_SET, OP_HAS, OP_DELETE} {
+		if n := s.ops[op]; n > 0 {
+			fmt.Fprintf(w, " %s %d", op, n)
+		}
+	}
+	fmt.Fprintln(w)
+
+	if gets := s.hits + s.misses; gets > 0 {
+		fmt.Fprintf(w, "get hits:   %d/%d (%.1f%%)\n", s.hits, gets, 100*float64(s.hits)/float64(gets))
+	}
+
+	nodes := make([]string, 0, len(s.nodeRequests))
+	for node := range s.nodeRequests {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+
+	fmt.Fprintln(w, "nodes:")
+	for _, node := range nodes {
+		fmt.Fprintf(w, "  %-24s requests %-10d errors %d\n", node, s.nodeRequests[node], s.nodeErrors[node])
+	}
+}
+
+// Reports how often commands spilled from their owner with bounded loads, prefill included.
+func reportSpills(w io.Writer, load ring.LoadStats) {
+	if load.Lookups > 0 {
+		fmt.Fprintf(w, "spills:     %d/%d (%.1f%%)\n", load.Spills, load.Lookups, 100*float64(load.Spills)/float64(load.Lookups))
+	}
+}
+
+func reportNearCache(w io.Writer, near client.NearCacheStats) {
+	if lookups := near.Hits + near.Misses; lookups > 0 {
+		fmt.Fprintf(w, "near hits:  %d/%d (%.1f%%)\n", near.Hits, lookups, 100*float64(near.Hits)/float64(lookups))
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	OP_GET    = "get"
+	OP_SET    = "set"
+	OP_HAS    = "has"
+	OP_DELETE = "delete"
+
+	DIST_UNIFORM = "uniform"
+	DIST_ZIPF    = "zipf"
+)
+
+// Operation weights, e.g. get:80,set:20 picks a GET 80% of the time
+type mix struct {
+	ops []string
+	// Cumulative weights, same length as ops
+	cumulative []int
+	total      int
+}
+
+// Parses a mix such as "get:80,set:15,delete:5".
+func parseMix(s string) (*mix, error) {
+	m := &mix{}
+	for _, part := range strings.Split(s, ",") {
+		op, weight, found := strings.Cut(strings.TrimSpace(part), ":")
+		if !found {
+			return nil, fmt.Errorf("invalid mix entry %q, expected op:weight", part)
+		}
+
+		op = strings.ToLower(op)
+		switch op {
+		case OP_GET, OP_SET, OP_HAS, OP_DELETE:
+		default:
+			return nil, fmt.Errorf("unknown op %q in mix", op)
+		}
+
+		w, err := strconv.Atoi(weight)
+		if err != nil || w < 0 {
+			return nil, fmt.Errorf("invalid weight %q for %s", weight, op)
+		}
+
+		if w == 0 {
+			continue
+		}
+
+		m.total += w
+		m.ops = append(m.ops, op)
+		m.cumulative = append(m.cumulative, m.total)
+	}
+
+	if m.total == 0 {
+		return nil, fmt.Errorf("mix %q has no operations", s)
+	}
+
+	return m, nil
+}
+
+// Picks an operation according to the weights.
+func (m *mix) pick(r *rand.Rand) string {
+	n := r.Intn(m.total)
+	return m.ops[sort.SearchInts(m.cumulative, n+1)]
+}
+
+// Range of value sizes in bytes
+type valueSize struct {
+	min, max int
+}
+
+// Parses a fixed size such as "100" or a range such as "64-1024".
+func parseValueSize(s string) (valueSize, error) {
+	minStr, maxStr, isRange := strings.Cut(s, "-")
+	if !isRange {
+		maxStr = minStr
+	}
+
+	min, err := strconv.Atoi(minStr)
+	if err != nil || min < 0 {
+		return valueSize{}, fmt.Errorf("invalid value size %q", s)
+	}
+
+	max, err := strconv.Atoi(maxStr)
+	if err != nil || max < min {
+		return valueSize{}, fmt.Errorf("invalid value size %q", s)
+	}
+
+	return valueSize{min: min, max: max}, nil
+}
+
+func (vs valueSize) pick(r *rand.Rand) int {
+	return vs.min + r.Intn(vs.max-vs.min+1)
+}
+
+// Generates key indexes in [0, keys) following a distribution, *rand.Zipf implements it.
+type keyGenerator interface {
+	Uint64() uint64
+}
+
+type uniformKeys struct {
+	r    *rand.Rand
+	keys uint64
+}
+
+func (u *uniformKeys) Uint64() uint64 {
+	return uint64(u.r.Int63n(int64(u.keys)))
+}
+
+// Creates a generator for dist, zipfS is the zipf exponent and must be greater than 1.
+func newKeyGenerator(dist string, r *rand.Rand, keys uint64, zipfS float64) (keyGenerator, error) {
+	switch dist {
+	case DIST_UNIFORM:
+		return &uniformKeys{r: r, keys: keys}, nil
+	case DIST_ZIPF:
+		if zipfS <= 1 {
+			return nil, fmt.Errorf("zipf exponent must be greater than 1, got %v", zipfS)
+		}
+		return rand.NewZipf(r, zipfS, 1, keys-1), nil
+	default:
+		return nil, fmt.Errorf("unknown key distribution %q", dist)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestParseMix(t *testing.T) {
+	m, err := parseMix("get:70, SET:20,has:0,delete:10")
+	if err != nil {
+		t.Fatalf("parseMix returned error %q", err)
+	}
+
+	r := rand.New(rand.NewSource(1))
+	counts := make(map[string]int)
+	for i := 0; i < 10000; i++ {
+		counts[m.pick(r)]++
+	}
+
+	if counts[OP_HAS] != 0 {
+		t.Errorf("expected ops with weight 0 to never be picked, got %d HAS", counts[OP_HAS])
+	}
+
+	expected := map[string]int{OP_GET: 7000, OP_SET: 2000, OP_DELETE: 1000}
+	for op, n := range expected {
+		if counts[op] < n*9/10 || counts[op] > n*11/10 {
+			t.Errorf("expected around %d %s, got %d", n, op, counts[op])
+		}
+	}
+
+	for _, invalid := range []string{"", "get", "get:x", "get:-1", "flush:10", "get:0"} {
+		if _, err := parseMix(invalid); err == nil {
+			t.Errorf("parseMix(%q) should return error", invalid)
+		}
+	}
+}
+
+func TestParseValueSize(t *testing.T) {
+	cases := map[string]valueSize{
+		"100":     {100, 100},
+		"64-1024": {64, 1024},
+		"0":       {0, 0},
+	}
+
+	for s, expected := range cases {
+		actual, err := parseValueSize(s)
+		if err != nil || actual != expected {
+			t.Errorf("parseValueSize(%q) = %v, %v, want %v", s, actual, err, expected)
+		}
+	}
+
+	for _, invalid := range []string{"", "a", "10-5", "-5", "5-"} {
+		if _, err := parseValueSize(invalid); err == nil {
+			t.Errorf("parseValueSize(%q) should return error", invalid)
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	latencies := make([]time.Duration, 1000)
+	for i := range latencies {
+		latencies[i] = time.Duration(i+1) * time.Millisecond
+	}
+
+	cases := map[float64]time.Duration{
+		50:   500 * time.Millisecond,
+		99:   990 * time.Millisecond,
+		99.9: 999 * time.Millisecond,
+		100:  1000 * time.Millisecond,
+	}
+
+	for p, expected := range cases {
+		if actual := percentile(latencies, p); actual != expected {
+			t.Errorf("percentile(%v) = %s, want %s", p, actual, expected)
+		}
+	}
+}
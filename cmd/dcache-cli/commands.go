@@ -0,0 +1,442 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joaovictorsl/dcache/client"
+	"github.com/joaovictorsl/dcache/client/ring"
+)
+
+type cli struct {
+	client *client.DCacheClient
+	format string
+	// Used by SET when no ttl is given
+	ttl  time.Duration
+	quit bool
+}
+
+type cliCommand struct {
+	usage   string
+	help    string
+	minArgs int
+	maxArgs int
+	run     func(cli *cli, args []string) (string, error)
+}
+
+var commands map[string]cliCommand
+
+// Initialized in init since HELP refers to the commands table
+func init() {
+	commands = map[string]cliCommand{
+		"SET": {
+			usage:   "SET key value [ttl]",
+			help:    "stores value under key, ttl is a duration such as 30s or 5m",
+			minArgs: 2,
+			maxArgs: 3,
+			run:     runSet,
+		},
+		"GET": {
+			usage:   "GET key",
+			help:    "prints the value stored under key",
+			minArgs: 1,
+			maxArgs: 1,
+			run:     runGet,
+		},
+		"HAS": {
+			usage:   "HAS key",
+			help:    "prints wether key is stored",
+			minArgs: 1,
+			maxArgs: 1,
+			run:     runHas,
+		},
+		"DELETE": {
+			usage:   "DELETE key",
+			help:    "removes key",
+			minArgs: 1,
+			maxArgs: 1,
+			run:     runDelete,
+		},
+		"OWNER": {
+			usage:   "OWNER key",
+			help:    "prints the nodes responsible for key",
+			minArgs: 1,
+			maxArgs: 1,
+			run:     runOwner,
+		},
+		"NODES": {
+			usage: "NODES",
+			help:  "lists ring nodes and their connection status",
+			run:   runNodes,
+		},
+		"CLIENT": {
+			usage:   "CLIENT LIST [node]",
+			help:    "lists clients connected to a node, or to every node",
+			minArgs: 1,
+			maxArgs: 2,
+			run:     runClient,
+		},
+		"ACL": {
+			usage:   "ACL WHOAMI|LIST [node]",
+			help:    "shows the authenticated user, or lists users and their permissions, of a node or every node",
+			minArgs: 1,
+			maxArgs: 2,
+			run:     runACL,
+		},
+		"SAVE": {
+			usage:   "SAVE [node]",
+			help:    "writes a snapshot of a node, or of every node, to disk",
+			maxArgs: 1,
+			run:     runSave,
+		},
+		"STATS": {
+			usage:   "STATS [node]",
+			help:    "shows the keys, clients and replication state of a node, or of every node",
+			maxArgs: 1,
+			run:     runStats,
+		},
+		"CLUSTER": {
+			usage:   "CLUSTER NODES [node]",
+			help:    "lists the cluster members known by a node, or by every node, and their state",
+			minArgs: 1,
+			maxArgs: 2,
+			run:     runCluster,
+		},
+		"REBALANCE": {
+			usage:   "REBALANCE old-nodes",
+			help:    "moves keys from the comma separated nodes of the previous ring to their owners in the current one",
+			minArgs: 1,
+			maxArgs: 1,
+			run:     runRebalance,
+		},
+		"RING": {
+			usage:   "RING EXPORT|DIFF [nodes|file]",
+			help:    "prints the ring as JSON, or the share of keys moving to a ring of the comma separated nodes or saved in file",
+			minArgs: 1,
+			maxArgs: 2,
+			run:     runRing,
+		},
+		"FORMAT": {
+			usage:   "FORMAT utf8|hex|json",
+			help:    "changes how values are printed",
+			minArgs: 1,
+			maxArgs: 1,
+			run:     runFormat,
+		},
+		"HELP": {
+			usage: "HELP",
+			help:  "lists available commands",
+			run:   runHelp,
+		},
+		"QUIT": {
+			usage: "QUIT",
+			help:  "exits the cli",
+			run:   runQuit,
+		},
+	}
+}
+
+// Aliases to commands
+var aliases = map[string]string{
+	"DEL":  "DELETE",
+	"EXIT": "QUIT",
+}
+
+// Parses and runs a single command line, returns false if it failed.
+func (cli *cli) runLine(w io.Writer, line string) bool {
+	args, err := splitArgs(line)
+	if err != nil {
+		fmt.Fprintf(w, "(error) %s\n", err)
+		return false
+	}
+
+	return cli.run(w, args)
+}
+
+// Runs the command args[0] with the remaining args, returns false if it failed.
+func (cli *cli) run(w io.Writer, args []string) bool {
+	name := strings.ToUpper(args[0])
+	if alias, ok := aliases[name]; ok {
+		name = alias
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(w, "(error) unknown command %q, try HELP\n", args[0])
+		return false
+	}
+
+	args = args[1:]
+	if len(args) < cmd.minArgs || len(args) > cmd.maxArgs {
+		fmt.Fprintf(w, "(error) usage: %s\n", cmd.usage)
+		return false
+	}
+
+	out, err := cmd.run(cli, args)
+	if err != nil {
+		fmt.Fprintf(w, "(error) %s\n", err)
+		return false
+	}
+
+	fmt.Fprintln(w, out)
+	return true
+}
+
+func runSet(cli *cli, args []string) (string, error) {
+	ttl := cli.ttl
+	if len(args) == 3 {
+		var err error
+		if ttl, err = time.ParseDuration(args[2]); err != nil {
+			return "", err
+		}
+	}
+
+	if err := cli.client.Set(args[0], []byte(args[1]), uint32(ttl.Milliseconds())); err != nil {
+		return "", err
+	}
+
+	return "OK", nil
+}
+
+func runGet(cli *cli, args []string) (string, error) {
+	v, ok, err := cli.client.Get(args[0])
+	if err != nil {
+		return "", err
+	} else if !ok {
+		return "(nil)", nil
+	}
+
+	return formatters[cli.format](v), nil
+}
+
+func runHas(cli *cli, args []string) (string, error) {
+	ok, err := cli.client.Has(args[0])
+	if err != nil {
+		return "", err
+	}
+
+	return strconv.FormatBool(ok), nil
+}
+
+func runDelete(cli *cli, args []string) (string, error) {
+	if err := cli.client.Delete(args[0]); err != nil {
+		return "", err
+	}
+
+	return "OK", nil
+}
+
+func runOwner(cli *cli, args []string) (string, error) {
+	owners := cli.client.NodesFor(args[0])
+	if len(owners) == 0 {
+		return "", fmt.Errorf("ring has no nodes")
+	}
+
+	return strings.Join(owners, "\n"), nil
+}
+
+func runNodes(cli *cli, _ []string) (string, error) {
+	nodes := cli.client.Nodes()
+	addrs := make([]string, 0, len(nodes))
+	for addr := range nodes {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+
+	lines := make([]string, len(addrs))
+	for i, addr := range addrs {
+		status := "inactive"
+		if nodes[addr] {
+			status = "active"
+		}
+		lines[i] = fmt.Sprintf("%s %s", addr, status)
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+func runClient(cli *cli, args []string) (string, error) {
+	if strings.ToUpper(args[0]) != "LIST" {
+		return "", fmt.Errorf("unknown CLIENT subcommand %q", args[0])
+	}
+
+	return cli.eachNode(args[1:], cli.client.ClientList)
+}
+
+func runACL(cli *cli, args []string) (string, error) {
+	switch strings.ToUpper(args[0]) {
+	case "WHOAMI":
+		return cli.eachNode(args[1:], cli.client.ACLWhoami)
+	case "LIST":
+		return cli.eachNode(args[1:], cli.client.ACLList)
+	default:
+		return "", fmt.Errorf("unknown ACL subcommand %q", args[0])
+	}
+}
+
+func runSave(cli *cli, args []string) (string, error) {
+	return cli.eachNode(args, cli.client.Save)
+}
+
+func runStats(cli *cli, args []string) (string, error) {
+	return cli.eachNode(args, cli.client.Stats)
+}
+
+func runCluster(cli *cli, args []string) (string, error) {
+	if strings.ToUpper(args[0]) != "NODES" {
+		return "", fmt.Errorf("unknown CLUSTER subcommand %q", args[0])
+	}
+
+	return cli.eachNode(args[1:], func(addr string) (string, *client.DCacheError) {
+		nodes, err := cli.client.ClusterNodes(addr)
+		if err != nil {
+			return "", err
+		}
+
+		lines := make([]string, len(nodes))
+		for i, node := range nodes {
+			lines[i] = fmt.Sprintf("%s %s", node.Addr, node.State)
+			if node.Self {
+				lines[i] += " myself"
+			}
+		}
+		return strings.Join(lines, "\n"), nil
+	})
+}
+
+func runRebalance(cli *cli, args []string) (string, error) {
+	if err := cli.client.MigrateFrom(strings.Split(args[0], ","), 1, time.Second); err != nil {
+		return "", err
+	}
+
+	stats, err := cli.client.Rebalance()
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("scanned=%d moved=%d skipped=%d failed=%d", stats.Scanned, stats.Moved, stats.Skipped, stats.Failed), nil
+}
+
+func runRing(cli *cli, args []string) (string, error) {
+	current, ok := cli.client.Ring().(*ring.ConsistentHash)
+	if !ok {
+		return "", fmt.Errorf("only consistent hash rings can be inspected")
+	}
+
+	switch strings.ToUpper(args[0]) {
+	case "EXPORT":
+		data, err := json.MarshalIndent(current, "", "  ")
+		return string(data), err
+	case "DIFF":
+		if len(args) != 2 {
+			return "", fmt.Errorf("usage: RING DIFF nodes|file")
+		}
+	default:
+		return "", fmt.Errorf("unknown RING subcommand %q", args[0])
+	}
+
+	other, err := loadRing(current, args[1])
+	if err != nil {
+		return "", err
+	}
+
+	moves, err := ring.Diff(current, other)
+	if err != nil {
+		return "", err
+	}
+
+	lines := make([]string, 0, len(moves)+1)
+	total := 0.0
+	for _, m := range moves {
+		lines = append(lines, fmt.Sprintf("%s -> %s %.2f%%", ringNode(m.From), ringNode(m.To), 100*m.Fraction))
+		total += m.Fraction
+	}
+	lines = append(lines, fmt.Sprintf("moved %.2f%%", 100*total))
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// Loads the ring saved in the file at arg, or builds one of the comma separated nodes in arg like current.
+func loadRing(current *ring.ConsistentHash, arg string) (*ring.ConsistentHash, error) {
+	if data, err := os.ReadFile(arg); err == nil {
+		other := &ring.ConsistentHash{}
+		return other, json.Unmarshal(data, other)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	other := current.Clone().(*ring.ConsistentHash)
+	for _, node := range other.Nodes() {
+		other.Remove(node)
+	}
+	for _, node := range strings.Split(arg, ",") {
+		other.Add(node)
+	}
+
+	return other, nil
+}
+
+func ringNode(node string) string {
+	if node == "" {
+		return "(none)"
+	}
+	return node
+}
+
+// Runs fn on the node given in args, or on every node if args is empty, joining the output of each node under its address.
+func (cli *cli) eachNode(args []string, fn func(addr string) (string, *client.DCacheError)) (string, error) {
+	addrs := args
+	if len(addrs) == 0 {
+		for addr := range cli.client.Nodes() {
+			addrs = append(addrs, addr)
+		}
+		sort.Strings(addrs)
+	}
+
+	sections := make([]string, len(addrs))
+	for i, addr := range addrs {
+		out, err := fn(addr)
+		if err != nil {
+			return "", err
+		}
+		sections[i] = fmt.Sprintf("# %s\n%s", addr, out)
+	}
+
+	return strings.Join(sections, "\n"), nil
+}
+
+func runFormat(cli *cli, args []string) (string, error) {
+	f := strings.ToLower(args[0])
+	if _, ok := formatters[f]; !ok {
+		return "", fmt.Errorf("unknown format %q", args[0])
+	}
+
+	cli.format = f
+	return "OK", nil
+}
+
+func runHelp(_ *cli, _ []string) (string, error) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lines := make([]string, len(names))
+	for i, name := range names {
+		lines[i] = fmt.Sprintf("%-24s %s", commands[name].usage, commands[name].help)
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+func runQuit(cli *cli, _ []string) (string, error) {
+	cli.quit = true
+	return "bye", nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	FORMAT_UTF8 = "utf8"
+	FORMAT_HEX  = "hex"
+	FORMAT_JSON = "json"
+)
+
+// Renders values in a human readable way
+var formatters = map[string]func(v []byte) string{
+	FORMAT_UTF8: formatUTF8,
+	FORMAT_HEX:  formatHex,
+	FORMAT_JSON: formatJSON,
+}
+
+// Quotes the value, non printable characters are escaped. Values that aren't valid utf-8 are printed as hex.
+func formatUTF8(v []byte) string {
+	if !utf8.Valid(v) {
+		return formatHex(v)
+	}
+
+	return strconv.Quote(string(v))
+}
+
+func formatHex(v []byte) string {
+	if len(v) == 0 {
+		return "(empty)"
+	}
+
+	return strings.TrimSuffix(hex.Dump(v), "\n")
+}
+
+// Indents the value if it's valid json, otherwise it's printed as utf-8.
+func formatJSON(v []byte) string {
+	buf := &bytes.Buffer{}
+	if err := json.Indent(buf, v, "", "  "); err != nil {
+		return fmt.Sprintf("%s (not valid json)", formatUTF8(v))
+	}
+
+	return buf.String()
+}
+
+// Splits a command line into arguments separated by spaces.
+//
+// Arguments with spaces can be wrapped in single quotes, which are taken literally, or double quotes,
+// which accept Go escape sequences such as \n or \x00.
+func splitArgs(line string) ([]string, error) {
+	args := make([]string, 0)
+	for i := 0; i < len(line); {
+		switch c := line[i]; {
+		case c == ' ' || c == '\t':
+			i++
+
+		case c == '"' || c == '\'':
+			end := closingQuote(line, i)
+			if end == -1 {
+				return nil, fmt.Errorf("unterminated quote at position %d", i)
+			}
+
+			arg := line[i+1 : end]
+			if c == '"' {
+				unquoted, err := strconv.Unquote(line[i : end+1])
+				if err != nil {
+					return nil, fmt.Errorf("invalid quoted argument %s", line[i:end+1])
+				}
+				arg = unquoted
+			}
+
+			args = append(args, arg)
+			i = end + 1
+
+		default:
+			end := strings.IndexAny(line[i:], " \t")
+			if end == -1 {
+				end = len(line) - i
+			}
+
+			args = append(args, line[i:i+end])
+			i += end
+		}
+	}
+
+	if len(args) == 0 {
+		return nil, fmt.Errorf("empty command")
+	}
+
+	return args, nil
+}
+
+// Returns the index of the quote closing the one at start, or -1 if there's none.
+func closingQuote(line string, start int) int {
+	quote := line[start]
+	for i := start + 1; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			if quote == '"' {
+				// Skips the escaped character
+				i++
+			}
+		case quote:
+			return i
+		}
+	}
+
+	return -1
+}
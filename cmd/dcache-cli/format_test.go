@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/exp/slices"
+)
+
+func TestSplitArgs(t *testing.T) {
+	cases := map[string][]string{
+		"GET foo":                      {"GET", "foo"},
+		"  SET   foo  bar  ":           {"SET", "foo", "bar"},
+		`SET "my key" 'a "b" c' 30s`:   {"SET", "my key", `a "b" c`, "30s"},
+		`SET foo "line\nbreak\x00"`:    {"SET", "foo", "line\nbreak\x00"},
+		`SET foo "escaped \" quote"`:   {"SET", "foo", `escaped " quote`},
+		`SET foo 'single \ backslash'`: {"SET", "foo", `single \ backslash`},
+	}
+
+	for line, expected := range cases {
+		actual, err := splitArgs(line)
+		if err != nil {
+			t.Errorf("splitArgs(%q) returned error %q", line, err)
+		} else if !slices.Equal(actual, expected) {
+			t.Errorf("splitArgs(%q) = %q, want %q", line, actual, expected)
+		}
+	}
+
+	for _, line := range []string{"", "   ", `GET "foo`, `GET 'foo`, `GET "\q"`} {
+		if _, err := splitArgs(line); err == nil {
+			t.Errorf("splitArgs(%q) should return error", line)
+		}
+	}
+}
+
+func TestFormatters(t *testing.T) {
+	if actual := formatUTF8([]byte("tab\there")); actual != `"tab\there"` {
+		t.Errorf("formatUTF8 = %s, want %s", actual, `"tab\there"`)
+	}
+
+	if actual := formatUTF8([]byte{0xff, 0xfe}); !strings.Contains(actual, "ff fe") {
+		t.Errorf("formatUTF8 of invalid utf-8 = %s, want hex dump", actual)
+	}
+
+	if actual := formatJSON([]byte(`{"a":1}`)); actual != "{\n  \"a\": 1\n}" {
+		t.Errorf("formatJSON = %s, want indented json", actual)
+	}
+
+	if actual := formatJSON([]byte("plain")); !strings.HasSuffix(actual, "(not valid json)") {
+		t.Errorf("formatJSON of invalid json = %s, want it flagged", actual)
+	}
+}
@@ -0,0 +1,225 @@
+// Command dcache-cli runs commands against a DCache node or a whole ring.
+//
+// Commands given as arguments are run once, e.g. "dcache-cli GET foo". Without arguments an interactive
+// prompt with history is started when stdin is a terminal, otherwise commands are read line by line
+// from stdin so scripts can be piped in.
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joaovictorsl/dcache/client"
+	"github.com/joaovictorsl/dcache/client/ring"
+	"github.com/peterh/liner"
+)
+
+const historyFile = ".dcache_cli_history"
+
+// Rings selectable with the ring flag
+var rings = map[string]func() ring.Ring{
+	"consistent": func() ring.Ring {
+		hashFn, _ := ring.HashFunc(*hashFunc)
+		return ring.NewCustomConsistentHash(0, hashFn)
+	},
+	"rendezvous": func() ring.Ring { return ring.NewRendezvous() },
+	"jump":       func() ring.Ring { return ring.NewJumpHash() },
+	"maglev":     func() ring.Ring { return ring.NewMaglev() },
+}
+
+var (
+	nodes    = flag.String("nodes", "127.0.0.1:3000", "comma separated list of node addresses")
+	format   = flag.String("format", FORMAT_UTF8, "value format: utf8, hex or json")
+	ttl      = flag.Duration("ttl", time.Hour, "ttl used by SET when none is given")
+	retries  = flag.Uint("retries", 0, "connection attempts after the first one fails")
+	verbose  = flag.Bool("v", false, "log connection events")
+	ringKind = flag.String("ring", "consistent", "how keys map to nodes: consistent, rendezvous, jump or maglev")
+	hashFunc = flag.String("hash", "md5", "hash function of the consistent ring: md5, xxhash, fnv1a or murmur3")
+	weights  = flag.String("weights", "", "comma separated node=weight pairs, weights go from 1 to 100, e.g. 10.0.0.1:3000=50")
+
+	useTLS      = flag.Bool("tls", false, "connect to nodes using TLS")
+	tlsCAFile   = flag.String("tls-ca-file", "", "CAs used to verify node certificates, system CAs by default")
+	tlsCertFile = flag.String("tls-cert-file", "", "client certificate for nodes that require one")
+	tlsKeyFile  = flag.String("tls-key-file", "", "client certificate key")
+
+	user     = flag.String("user", "", "user to authenticate as, the default user if empty")
+	password = flag.String("password", "", "secret to authenticate with, read from $DCACHE_PASSWORD if empty")
+
+	replicas     = flag.Int("replicas", 1, "nodes each key is stored in")
+	writeAcks    = flag.Int("write-acks", 1, "replicas that must acknowledge a write")
+	readReplicas = flag.Int("read-replicas", 1, "replicas queried by a read")
+)
+
+func main() {
+	flag.Parse()
+
+	if !*verbose {
+		log.SetOutput(io.Discard)
+	}
+
+	if _, ok := formatters[*format]; !ok {
+		fmt.Fprintf(os.Stderr, "unknown format %q\n", *format)
+		os.Exit(2)
+	}
+	if _, ok := rings[*ringKind]; !ok {
+		fmt.Fprintf(os.Stderr, "unknown ring %q\n", *ringKind)
+		os.Exit(2)
+	}
+	if _, ok := ring.HashFunc(*hashFunc); !ok {
+		fmt.Fprintf(os.Stderr, "unknown hash function %q\n", *hashFunc)
+		os.Exit(2)
+	}
+
+	c, err := newClient()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "(error) %s\n", err)
+		os.Exit(2)
+	}
+	if err := c.Connect(*retries, time.Second); err != nil {
+		fmt.Fprintf(os.Stderr, "(error) %s\n", err)
+	}
+	defer c.End()
+
+	cli := &cli{client: c, format: *format, ttl: *ttl}
+
+	var ok bool
+	switch {
+	case flag.NArg() > 0:
+		ok = cli.run(os.Stdout, flag.Args())
+	case isTerminal(os.Stdin):
+		ok = cli.repl()
+	default:
+		ok = cli.script(os.Stdin)
+	}
+
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// Reads commands from r until EOF, returns false if any of them failed.
+func (cli *cli) script(r io.Reader) bool {
+	ok := true
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if !cli.runLine(os.Stdout, line) {
+			ok = false
+		}
+
+		if cli.quit {
+			break
+		}
+	}
+
+	return ok
+}
+
+// Runs an interactive prompt, history is kept in the user's home directory.
+func (cli *cli) repl() bool {
+	line := liner.NewLiner()
+	defer line.Close()
+	line.SetCtrlCAborts(true)
+
+	historyPath := ""
+	if home, err := os.UserHomeDir(); err == nil {
+		historyPath = filepath.Join(home, historyFile)
+		if f, err := os.Open(historyPath); err == nil {
+			line.ReadHistory(f)
+			f.Close()
+		}
+	}
+
+	for !cli.quit {
+		input, err := line.Prompt(fmt.Sprintf("%s> ", *nodes))
+		if errors.Is(err, liner.ErrPromptAborted) {
+			continue
+		} else if err != nil {
+			break
+		}
+
+		input = strings.TrimSpace(input)
+		if input == "" {
+			continue
+		}
+
+		line.AppendHistory(input)
+		cli.runLine(os.Stdout, input)
+	}
+
+	if historyPath != "" {
+		if f, err := os.Create(historyPath); err == nil {
+			line.WriteHistory(f)
+			f.Close()
+		}
+	}
+
+	return true
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Creates a client for the nodes flag, using TLS if any of the TLS flags is set and authenticating
+// if a password is given.
+func newClient() (*client.DCacheClient, error) {
+	opts := []client.Option{client.WithNodes(strings.Split(*nodes, ",")...), client.WithRing(rings[*ringKind]())}
+	if *weights != "" {
+		nodeWeights, err := parseWeights(*weights)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, client.WithWeightedNodes(nodeWeights))
+	}
+	if *useTLS || *tlsCAFile != "" || *tlsCertFile != "" || *tlsKeyFile != "" {
+		cfg, err := client.NewTLSConfig(*tlsCAFile, *tlsCertFile, *tlsKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, client.WithTLS(cfg))
+	}
+
+	secret := *password
+	if secret == "" {
+		secret = os.Getenv("DCACHE_PASSWORD")
+	}
+	if secret != "" {
+		opts = append(opts, client.WithAuth(*user, secret))
+	}
+
+	if *replicas > 1 {
+		opts = append(opts, client.WithReplication(*replicas, *writeAcks, *readReplicas))
+	}
+
+	return client.NewWithOptions(opts...), nil
+}
+
+// Parses the weights flag, nodes not in the nodes flag are added to the ring.
+func parseWeights(s string) (map[string]int, error) {
+	nodeWeights := make(map[string]int)
+	for _, pair := range strings.Split(s, ",") {
+		addr, weight, ok := strings.Cut(pair, "=")
+		w, err := strconv.Atoi(weight)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("invalid node weight %q, expected node=weight", pair)
+		}
+		nodeWeights[addr] = w
+	}
+
+	return nodeWeights, nil
+}
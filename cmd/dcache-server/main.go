@@ -0,0 +1,140 @@
+// Command dcache-server runs a single DCache node.
+//
+// Settings are read from the config file, then from DCACHE_* environment variables and finally
+// from flags, each one overriding the previous. SIGTERM and SIGINT shut the server down gracefully,
+// SIGHUP reloads the configuration and TLS certificates.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/joaovictorsl/dcache"
+)
+
+var (
+	configPath      = flag.String("config", "", "path to a .yaml, .json or .toml config file")
+	shutdownTimeout = flag.Duration("shutdown-timeout", 10*time.Second, "time to wait for in-flight commands on shutdown")
+
+	// Flags named after config settings, "-max-memory" sets "max_memory"
+	_ = flag.String("port", "", "port to listen on")
+	_ = flag.String("bind-addr", "", "address to bind to, all interfaces by default")
+	_ = flag.String("max-memory", "", "memory the cache may use for values, e.g. 512MB, 0 means unbounded")
+	_ = flag.String("eviction-policy", "", "policy used when max memory is reached: lru or none")
+	_ = flag.String("max-key-length", "", "maximum key length in bytes")
+	_ = flag.String("max-value-length", "", "maximum value length, e.g. 64KB")
+	_ = flag.String("max-conns", "", "maximum simultaneous connections, 0 means unlimited")
+	_ = flag.String("max-conns-per-client", "", "maximum simultaneous connections from a single host, 0 means unlimited")
+	_ = flag.String("snapshot-file", "", "file the cache is saved to on shutdown and loaded from on start")
+	_ = flag.String("snapshot-interval", "", "interval in which snapshots are written, e.g. 5m, 0 saves only on shutdown and SAVE")
+	_ = flag.String("aof-file", "", "file every write is appended to and replayed from on start, requires -snapshot-file")
+	_ = flag.String("aof-fsync", "", "when the aof is synced to disk: always, everysec or never")
+	_ = flag.String("aof-compact-size", "", "size the aof may reach before being compacted into a snapshot, e.g. 64MB")
+	_ = flag.String("replica-of", "", "address of the leader to replicate, e.g. 10.0.0.1:3000, makes the server a read only follower")
+	_ = flag.String("replica-user", "", "user the follower authenticates to its leader as")
+	_ = flag.String("replica-password", "", "secret the follower authenticates to its leader with")
+	_ = flag.String("replica-tls-ca-file", "", "CAs used to verify the certificates of the leader and cluster members, connecting to them with TLS when set")
+	_ = flag.String("cluster-addr", "", "address cluster members and clients reach this server at, enables cluster membership")
+	_ = flag.String("cluster-seeds", "", "comma separated members contacted to join the cluster")
+	_ = flag.String("cluster-user", "", "user the server authenticates to other cluster members as")
+	_ = flag.String("cluster-password", "", "secret the server authenticates to other cluster members with")
+	_ = flag.String("cluster-gossip-interval", "", "interval in which cluster members gossip, e.g. 1s")
+	_ = flag.String("cluster-redirect", "", "answer commands on keys owned by other members with MOVED: true or false")
+	_ = flag.String("cluster-replicas", "", "members owning each key when redirecting, the replication factor of clients")
+	_ = flag.String("tracking-max-keys", "", "keys read by clients tracking their reads that are remembered, 0 disables tracking")
+	_ = flag.String("tls-cert-file", "", "certificate file in PEM format, enables TLS along with -tls-key-file")
+	_ = flag.String("tls-key-file", "", "private key file in PEM format")
+	_ = flag.String("tls-client-ca-file", "", "CAs used to verify client certificates, clients must present one when set")
+	_ = flag.String("acl-file", "", "file defining users and their permissions, connections must authenticate when set")
+	_ = flag.String("log-level", "", "debug, info, error or none")
+)
+
+// Flags that are not config settings
+var nonSettingFlags = map[string]bool{
+	"config":           true,
+	"shutdown-timeout": true,
+}
+
+func main() {
+	flag.Parse()
+
+	cfg, err := loadConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	s := dcache.NewServerWithOptions(dcache.WithConfig(cfg))
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.Start()
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
+
+	for {
+		select {
+		case err := <-errs:
+			if !errors.Is(err, dcache.ErrServerClosed) {
+				log.Fatal(err)
+			}
+			return
+
+		case sig := <-signals:
+			if sig == syscall.SIGHUP {
+				reload(s)
+				continue
+			}
+
+			log.Printf("received %s, shutting down\n", sig)
+			ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+			err := s.Shutdown(ctx)
+			cancel()
+			if err != nil {
+				log.Fatalf("shutdown error: %s", err)
+			}
+			return
+		}
+	}
+}
+
+func reload(s *dcache.Server) {
+	cfg, err := loadConfig()
+	if err != nil {
+		log.Printf("config not reloaded: %s\n", err)
+		return
+	}
+
+	if err := s.Reload(cfg); err != nil {
+		log.Printf("config not reloaded: %s\n", err)
+	}
+}
+
+// Loads the config file and environment variables, then applies the flags given in the command line.
+func loadConfig() (dcache.Config, error) {
+	cfg, err := dcache.LoadConfig(*configPath)
+	if err != nil {
+		return cfg, err
+	}
+
+	flag.Visit(func(f *flag.Flag) {
+		if err != nil || nonSettingFlags[f.Name] {
+			return
+		}
+
+		err = cfg.Set(strings.ReplaceAll(f.Name, "-", "_"), f.Value.String())
+	})
+	if err != nil {
+		return cfg, fmt.Errorf("flags: %s", err)
+	}
+
+	return cfg, cfg.Validate()
+}
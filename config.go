@@ -0,0 +1,633 @@
+package dcache
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/joaovictorsl/dcache/core/keyspace"
+	"github.com/joaovictorsl/dcache/core/persist"
+	"github.com/joaovictorsl/dcache/core/protocol"
+	"github.com/joaovictorsl/fooche"
+	"github.com/joaovictorsl/fooche/evict"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// Prefix of the environment variables read by LoadConfig
+	ENV_PREFIX = "DCACHE_"
+
+	EVICTION_LRU  = "lru"
+	EVICTION_NONE = "none"
+
+	// Key length is a single byte in the protocol
+	maxProtocolKeyLength = 255
+	// Smallest bucket used when the cache memory is bounded
+	minBucketSize = 64
+)
+
+// Server configuration. Use DefaultConfig to get a valid starting point, or LoadConfig to build it
+// from a file and environment variables.
+type Config struct {
+	// Address the server binds to, empty means all interfaces
+	BindAddr string
+	Port     uint16
+
+	// Maximum key length in bytes, can't be bigger than 255
+	MaxKeyLength uint
+	// Maximum value length in bytes
+	MaxValueLength uint
+	// Maximum amount of simultaneous connections, 0 means unlimited
+	MaxConns uint
+	// Maximum amount of simultaneous connections from a single host, 0 means unlimited
+	MaxConnsPerClient uint
+
+	// Maximum time a connection may wait for a command, 0 means no timeout
+	ReadTimeout time.Duration
+	// Maximum time a response may take to be written, 0 means no timeout
+	WriteTimeout time.Duration
+
+	// Memory in bytes the cache may use for values, 0 means unbounded.
+	// Ignored when a cache is given through WithCache.
+	MaxMemory uint64
+	// Policy used to evict keys when MaxMemory is reached, EVICTION_LRU or EVICTION_NONE
+	EvictionPolicy string
+	// Interval in which expired keys are cleaned, 0 disables expiration
+	CleanInterval time.Duration
+
+	// File the cache is saved to and loaded from on start, empty disables snapshots
+	SnapshotFile string
+	// Interval in which snapshots are written, 0 means only on SAVE and shutdown
+	SnapshotInterval time.Duration
+	// File every command changing the cache is appended to and replayed from on start, empty disables it.
+	// Requires SnapshotFile, which the log is compacted into.
+	AOFFile string
+	// When appended commands are synced to disk: persist.FSYNC_ALWAYS, persist.FSYNC_EVERYSEC or persist.FSYNC_NEVER
+	AOFFsync string
+	// Size in bytes the log may reach before being compacted into a snapshot, 0 compacts only on snapshots
+	AOFCompactSize uint64
+
+	// Address of the leader this server follows as a read only replica, empty if it's a leader.
+	// Clearing it on Reload promotes the follower to leader.
+	ReplicaOf string
+	// Credentials the follower authenticates to its leader with, when the leader requires authentication
+	ReplicaUser     string
+	ReplicaPassword string
+	// CAs file in PEM format used to verify the certificates of the leader and other cluster members, the
+	// server connects to them with TLS when set
+	ReplicaTLSCAFile string
+
+	// Address other cluster members and clients reach this server at, cluster membership is enabled when set
+	ClusterAddr string
+	// Members contacted to join the cluster, and again whenever they are not known to be up
+	ClusterSeeds []string
+	// Credentials the server authenticates to other members with, when they require authentication
+	ClusterUser     string
+	ClusterPassword string
+	// Interval in which members gossip, a member is suspected after missing 5 of them and dead after 15
+	ClusterGossipInterval time.Duration
+	// Whether commands on keys this server doesn't own are answered with MOVED and the owner's address.
+	// Owners are picked from the members that aren't dead the same way clients do.
+	ClusterRedirect bool
+	// Owners of each key when checking ownership, must match the replication factor of clients
+	ClusterReplicas uint
+
+	// Keys whose readers are remembered so their invalidation streams are told when they change, 0 disables
+	// TRACKING. Once reached, the streams of a tracked key are told it changed to make room for another.
+	TrackingMaxKeys uint
+
+	// Certificate and private key files in PEM format, TLS is enabled when both are set.
+	// The files are read again on Reload, so certificates can be renewed without a restart.
+	TLSCertFile string
+	TLSKeyFile  string
+	// CAs file in PEM format used to verify client certificates, when set clients must present one
+	TLSClientCAFile string
+
+	// Secret of the default user, when it or AuthTokens is set connections must authenticate with AUTH
+	// before running commands
+	AuthPassword string
+	// Additional secrets accepted for the default user, e.g. one per service so they can be revoked separately
+	AuthTokens []string
+	// File defining named users and their permissions, read again on Reload.
+	// Connections must authenticate when it's set.
+	ACLFile string
+
+	// Minimum level of logged messages: LOG_DEBUG, LOG_INFO, LOG_ERROR or LOG_NONE
+	LogLevel string
+}
+
+// Returns the configuration used when no option overrides it.
+func DefaultConfig() Config {
+	return Config{
+		Port:           3000,
+		MaxKeyLength:   maxProtocolKeyLength,
+		MaxValueLength: 1024 * 1024,
+		EvictionPolicy: EVICTION_LRU,
+		CleanInterval:  time.Second,
+		AOFFsync:       persist.FSYNC_EVERYSEC,
+		AOFCompactSize: 64 * 1024 * 1024,
+		LogLevel:       LOG_INFO,
+
+		ClusterGossipInterval: time.Second,
+		ClusterReplicas:       1,
+
+		TrackingMaxKeys: 1_000_000,
+	}
+}
+
+// Address the server listens on.
+func (c Config) Addr() string {
+	return net.JoinHostPort(c.BindAddr, strconv.Itoa(int(c.Port)))
+}
+
+// Checks if the configuration can be used to start a server.
+func (c Config) Validate() error {
+	if c.MaxKeyLength == 0 || c.MaxKeyLength > maxProtocolKeyLength {
+		return fmt.Errorf("max key length must be in range [1, %d], got %d", maxProtocolKeyLength, c.MaxKeyLength)
+	}
+
+	// Frame lengths are uint32, the biggest frame is a replication record with a SET of the longest value
+	maxValueLength := uint(math.MaxUint32 - replHeaderSize - protocol.MaxCommandSize(c.MaxKeyLength, 0))
+	if c.MaxValueLength == 0 || c.MaxValueLength > maxValueLength {
+		return fmt.Errorf("max value length must be in range [1, %d], got %d", maxValueLength, c.MaxValueLength)
+	}
+
+	if c.EvictionPolicy != EVICTION_LRU && c.EvictionPolicy != EVICTION_NONE {
+		return fmt.Errorf("unknown eviction policy %q", c.EvictionPolicy)
+	}
+
+	if _, ok := logLevels[c.LogLevel]; !ok {
+		return fmt.Errorf("unknown log level %q", c.LogLevel)
+	}
+
+	if c.SnapshotInterval != 0 && c.SnapshotFile == "" {
+		return fmt.Errorf("snapshot interval requires a snapshot file")
+	}
+
+	if c.AOFFile != "" && c.SnapshotFile == "" {
+		return fmt.Errorf("aof file requires a snapshot file")
+	}
+
+	switch c.AOFFsync {
+	case persist.FSYNC_ALWAYS, persist.FSYNC_EVERYSEC, persist.FSYNC_NEVER:
+	default:
+		return fmt.Errorf("unknown aof fsync policy %q", c.AOFFsync)
+	}
+
+	if c.ReplicaOf != "" {
+		if _, _, err := net.SplitHostPort(c.ReplicaOf); err != nil {
+			return fmt.Errorf("invalid replica of address: %s", err)
+		}
+	}
+
+	for _, addr := range append([]string{c.ClusterAddr}, c.ClusterSeeds...) {
+		if addr == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return fmt.Errorf("invalid cluster address: %s", err)
+		}
+	}
+
+	if c.ClusterAddr != "" && c.ClusterGossipInterval <= 0 {
+		return fmt.Errorf("cluster gossip interval must be greater than 0")
+	}
+
+	if c.ClusterRedirect && c.ClusterAddr == "" {
+		return fmt.Errorf("cluster redirect requires a cluster addr")
+	}
+
+	if c.ClusterReplicas == 0 {
+		return fmt.Errorf("cluster replicas must be greater than 0")
+	}
+
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return fmt.Errorf("tls cert file and tls key file must be set together")
+	}
+
+	if c.TLSClientCAFile != "" && !c.tlsEnabled() {
+		return fmt.Errorf("tls client ca file requires tls cert file and tls key file")
+	}
+
+	if c.MaxMemory != 0 && c.MaxMemory < c.minMemory() {
+		return fmt.Errorf("max memory must be at least %d to store values of max value length (%d), got %d", c.minMemory(), c.MaxValueLength, c.MaxMemory)
+	}
+
+	return nil
+}
+
+func (c Config) tlsEnabled() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
+
+// Creates the cache backend described by the configuration, recording the keys it evicts in evictions.
+func (c Config) newCache(evictions *keyspace.Evictions) fooche.ICache {
+	if c.MaxMemory == 0 {
+		if c.CleanInterval == 0 {
+			return fooche.NewSimple()
+		}
+
+		return fooche.NewCleanInterval(c.CleanInterval)
+	}
+
+	createPolicy := func(capacity int) evict.EvictionPolicy[string] {
+		if c.EvictionPolicy == EVICTION_LRU {
+			return evictions.Policy(evict.NewLRU[string](capacity))
+		}
+
+		return &evict.NoPolicy[string]{}
+	}
+
+	if c.CleanInterval == 0 {
+		return fooche.NewSimpleBounded(c.bucketCapacities(), createPolicy)
+	}
+
+	return fooche.NewCleanIntervalBounded(c.CleanInterval, c.bucketCapacities(), createPolicy)
+}
+
+// Bucket sizes used when memory is bounded. Sizes grow by a factor of 4 starting at 64 bytes,
+// the last bucket holds values of MaxValueLength bytes.
+func (c Config) bucketSizes() []int {
+	sizes := make([]int, 0)
+	for size := minBucketSize; size < int(c.MaxValueLength); size *= 4 {
+		sizes = append(sizes, size)
+	}
+
+	return append(sizes, int(c.MaxValueLength))
+}
+
+// Memory needed to store a single value in every bucket.
+func (c Config) minMemory() uint64 {
+	total := uint64(0)
+	for _, size := range c.bucketSizes() {
+		// Storage uses 4 extra bytes per value to store its length
+		total += uint64(size + 4)
+	}
+
+	return total
+}
+
+// Splits MaxMemory evenly between buckets of growing sizes, so small values don't take the place of big ones.
+//
+// Every bucket holds at least one value.
+func (c Config) bucketCapacities() map[int]int {
+	sizes := c.bucketSizes()
+	share := c.MaxMemory / uint64(len(sizes))
+	capacities := make(map[int]int, len(sizes))
+	for _, size := range sizes {
+		capacities[size] = 1
+		if capacity := share / uint64(size+4); capacity > 1 {
+			capacities[size] = int(capacity)
+		}
+	}
+
+	return capacities
+}
+
+// Setting names as used in configuration files. Environment variables use the same names
+// in upper case prefixed by ENV_PREFIX, e.g. DCACHE_MAX_CONNS.
+var settings = map[string]func(c *Config, v string) error{
+	"bind_addr": func(c *Config, v string) error {
+		c.BindAddr = v
+		return nil
+	},
+	"port": func(c *Config, v string) error {
+		port, err := strconv.ParseUint(v, 10, 16)
+		c.Port = uint16(port)
+		return err
+	},
+	"max_key_length": func(c *Config, v string) (err error) {
+		c.MaxKeyLength, err = parseUint(v)
+		return err
+	},
+	"max_value_length": func(c *Config, v string) (err error) {
+		size, err := parseSize(v)
+		c.MaxValueLength = uint(size)
+		return err
+	},
+	"max_conns": func(c *Config, v string) (err error) {
+		c.MaxConns, err = parseUint(v)
+		return err
+	},
+	"max_conns_per_client": func(c *Config, v string) (err error) {
+		c.MaxConnsPerClient, err = parseUint(v)
+		return err
+	},
+	"read_timeout": func(c *Config, v string) (err error) {
+		c.ReadTimeout, err = time.ParseDuration(v)
+		return err
+	},
+	"write_timeout": func(c *Config, v string) (err error) {
+		c.WriteTimeout, err = time.ParseDuration(v)
+		return err
+	},
+	"max_memory": func(c *Config, v string) (err error) {
+		c.MaxMemory, err = parseSize(v)
+		return err
+	},
+	"eviction_policy": func(c *Config, v string) error {
+		c.EvictionPolicy = strings.ToLower(v)
+		return nil
+	},
+	"clean_interval": func(c *Config, v string) (err error) {
+		c.CleanInterval, err = time.ParseDuration(v)
+		return err
+	},
+	"snapshot_file": func(c *Config, v string) error {
+		c.SnapshotFile = v
+		return nil
+	},
+	"snapshot_interval": func(c *Config, v string) (err error) {
+		c.SnapshotInterval, err = time.ParseDuration(v)
+		return err
+	},
+	"aof_file": func(c *Config, v string) error {
+		c.AOFFile = v
+		return nil
+	},
+	"aof_fsync": func(c *Config, v string) error {
+		c.AOFFsync = strings.ToLower(v)
+		return nil
+	},
+	"aof_compact_size": func(c *Config, v string) (err error) {
+		c.AOFCompactSize, err = parseSize(v)
+		return err
+	},
+	"replica_of": func(c *Config, v string) error {
+		c.ReplicaOf = v
+		return nil
+	},
+	"replica_user": func(c *Config, v string) error {
+		c.ReplicaUser = v
+		return nil
+	},
+	"replica_password": func(c *Config, v string) error {
+		c.ReplicaPassword = v
+		return nil
+	},
+	"replica_tls_ca_file": func(c *Config, v string) error {
+		c.ReplicaTLSCAFile = v
+		return nil
+	},
+	"cluster_addr": func(c *Config, v string) error {
+		c.ClusterAddr = v
+		return nil
+	},
+	"cluster_seeds": func(c *Config, v string) error {
+		c.ClusterSeeds = nil
+		for _, seed := range strings.Split(v, ",") {
+			if seed = strings.TrimSpace(seed); seed != "" {
+				c.ClusterSeeds = append(c.ClusterSeeds, seed)
+			}
+		}
+		return nil
+	},
+	"cluster_user": func(c *Config, v string) error {
+		c.ClusterUser = v
+		return nil
+	},
+	"cluster_password": func(c *Config, v string) error {
+		c.ClusterPassword = v
+		return nil
+	},
+	"cluster_gossip_interval": func(c *Config, v string) (err error) {
+		c.ClusterGossipInterval, err = time.ParseDuration(v)
+		return err
+	},
+	"cluster_redirect": func(c *Config, v string) (err error) {
+		c.ClusterRedirect, err = strconv.ParseBool(v)
+		return err
+	},
+	"cluster_replicas": func(c *Config, v string) (err error) {
+		c.ClusterReplicas, err = parseUint(v)
+		return err
+	},
+	"tracking_max_keys": func(c *Config, v string) (err error) {
+		c.TrackingMaxKeys, err = parseUint(v)
+		return err
+	},
+	"tls_cert_file": func(c *Config, v string) error {
+		c.TLSCertFile = v
+		return nil
+	},
+	"tls_key_file": func(c *Config, v string) error {
+		c.TLSKeyFile = v
+		return nil
+	},
+	"tls_client_ca_file": func(c *Config, v string) error {
+		c.TLSClientCAFile = v
+		return nil
+	},
+	"auth_password": func(c *Config, v string) error {
+		c.AuthPassword = v
+		return nil
+	},
+	"auth_tokens": func(c *Config, v string) error {
+		c.AuthTokens = nil
+		for _, token := range strings.Split(v, ",") {
+			if token = strings.TrimSpace(token); token != "" {
+				c.AuthTokens = append(c.AuthTokens, token)
+			}
+		}
+		return nil
+	},
+	"acl_file": func(c *Config, v string) error {
+		c.ACLFile = v
+		return nil
+	},
+	"log_level": func(c *Config, v string) error {
+		c.LogLevel = strings.ToLower(v)
+		return nil
+	},
+}
+
+// Builds a configuration starting from DefaultConfig, then applying the settings in the file at path
+// and finally the environment variables prefixed by ENV_PREFIX.
+//
+// The file format is picked by its extension: .yaml, .yml, .json or .toml. If path is empty only
+// environment variables are applied.
+func LoadConfig(path string) (Config, error) {
+	cfg := DefaultConfig()
+
+	if path != "" {
+		values, err := decodeConfigFile(path)
+		if err != nil {
+			return cfg, err
+		}
+
+		for name, v := range values {
+			if err := cfg.Set(name, settingValue(v)); err != nil {
+				return cfg, fmt.Errorf("%s: %s", path, err)
+			}
+		}
+	}
+
+	for name := range settings {
+		v, ok := os.LookupEnv(ENV_PREFIX + strings.ToUpper(name))
+		if !ok {
+			continue
+		}
+
+		if err := cfg.Set(name, v); err != nil {
+			return cfg, fmt.Errorf("environment: %s", err)
+		}
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// Applies a single setting by its file name, e.g. "max_conns".
+func (c *Config) Set(name, v string) error {
+	set, ok := settings[name]
+	if !ok {
+		return fmt.Errorf("unknown setting %q", name)
+	}
+
+	if err := set(c, strings.TrimSpace(v)); err != nil {
+		return fmt.Errorf("invalid value %q for %s: %s", v, name, err)
+	}
+
+	return nil
+}
+
+// Formats a value decoded from a config file as a setting, lists become comma separated.
+func settingValue(v any) string {
+	list, ok := v.([]any)
+	if !ok {
+		return fmt.Sprint(v)
+	}
+
+	items := make([]string, len(list))
+	for i, item := range list {
+		items[i] = fmt.Sprint(item)
+	}
+
+	return strings.Join(items, ",")
+}
+
+func decodeConfigFile(path string) (map[string]any, error) {
+	values := make(map[string]any)
+	if err := decodeFile(path, &values); err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+// Decodes the file at path into v, the format is picked by its extension: .yaml, .yml, .json or .toml.
+func decodeFile(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, v)
+	case ".json":
+		// Keeps big numbers from being formatted in scientific notation
+		d := json.NewDecoder(bytes.NewReader(data))
+		d.UseNumber()
+		err = d.Decode(v)
+	case ".toml":
+		err = toml.Unmarshal(data, v)
+	default:
+		return fmt.Errorf("unsupported file extension %q", ext)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+
+	return nil
+}
+
+func parseUint(v string) (uint, error) {
+	n, err := strconv.ParseUint(v, 10, 0)
+	return uint(n), err
+}
+
+// Parses sizes such as 512, 64KB, 10MB or 1GB into bytes.
+func parseSize(v string) (uint64, error) {
+	units := []struct {
+		suffix     string
+		multiplier uint64
+	}{
+		{"GB", 1 << 30},
+		{"MB", 1 << 20},
+		{"KB", 1 << 10},
+		{"B", 1},
+	}
+
+	upper := strings.ToUpper(v)
+	for _, unit := range units {
+		if strings.HasSuffix(upper, unit.suffix) {
+			n, err := strconv.ParseUint(strings.TrimSpace(strings.TrimSuffix(upper, unit.suffix)), 10, 64)
+			if err == nil && n > math.MaxUint64/unit.multiplier {
+				return 0, fmt.Errorf("size %q is too big", v)
+			}
+			return n * unit.multiplier, err
+		}
+	}
+
+	return strconv.ParseUint(v, 10, 64)
+}
+
+// Returns the names of the settings that differ between c and other and can't be changed
+// without restarting the server.
+func (c Config) restartRequired(other Config) []string {
+	changed := make([]string, 0)
+	if c.BindAddr != other.BindAddr {
+		changed = append(changed, "bind_addr")
+	}
+	if c.Port != other.Port {
+		changed = append(changed, "port")
+	}
+	if c.MaxMemory != other.MaxMemory {
+		changed = append(changed, "max_memory")
+	}
+	if c.EvictionPolicy != other.EvictionPolicy {
+		changed = append(changed, "eviction_policy")
+	}
+	// Buckets of a bounded cache are sized after it
+	if c.MaxMemory != 0 && c.MaxValueLength != other.MaxValueLength {
+		changed = append(changed, "max_value_length")
+	}
+	if c.CleanInterval != other.CleanInterval {
+		changed = append(changed, "clean_interval")
+	}
+	if c.SnapshotFile != other.SnapshotFile {
+		changed = append(changed, "snapshot_file")
+	}
+	if c.SnapshotInterval != other.SnapshotInterval {
+		changed = append(changed, "snapshot_interval")
+	}
+	if c.AOFFile != other.AOFFile {
+		changed = append(changed, "aof_file")
+	}
+	if c.AOFFsync != other.AOFFsync {
+		changed = append(changed, "aof_fsync")
+	}
+	if c.ClusterAddr != other.ClusterAddr {
+		changed = append(changed, "cluster_addr")
+	}
+	if c.TLSCertFile != other.TLSCertFile {
+		changed = append(changed, "tls_cert_file")
+	}
+	if c.TLSKeyFile != other.TLSKeyFile {
+		changed = append(changed, "tls_key_file")
+	}
+	if c.TLSClientCAFile != other.TLSClientCAFile {
+		changed = append(changed, "tls_client_ca_file")
+	}
+
+	return changed
+}
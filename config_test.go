@@ -0,0 +1,135 @@
+package dcache
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %s", err)
+	}
+
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	expected := DefaultConfig()
+	expected.BindAddr = "127.0.0.1"
+	expected.Port = 4000
+	expected.MaxValueLength = 2048
+	expected.MaxConns = 100
+	expected.ReadTimeout = 30 * time.Second
+	expected.MaxMemory = 64 * 1024 * 1024
+	expected.EvictionPolicy = EVICTION_NONE
+	expected.AuthTokens = []string{"svc-a", "svc-b"}
+
+	files := map[string]string{
+		"dcache.yaml": `
+bind_addr: 127.0.0.1
+port: 4000
+max_value_length: 2KB
+max_conns: 100
+read_timeout: 30s
+max_memory: 64MB
+eviction_policy: none
+auth_tokens:
+  - svc-a
+  - svc-b
+`,
+		"dcache.json": `{
+	"bind_addr": "127.0.0.1",
+	"port": 4000,
+	"max_value_length": 2048,
+	"max_conns": 100,
+	"read_timeout": "30s",
+	"max_memory": 67108864,
+	"eviction_policy": "none",
+	"auth_tokens": ["svc-a", "svc-b"]
+}`,
+		"dcache.toml": `
+bind_addr = "127.0.0.1"
+port = 4000
+max_value_length = "2KB"
+max_conns = 100
+read_timeout = "30s"
+max_memory = "64MB"
+eviction_policy = "none"
+auth_tokens = ["svc-a", "svc-b"]
+`,
+	}
+
+	for name, content := range files {
+		t.Run("should load "+name, func(t *testing.T) {
+			cfg, err := LoadConfig(writeConfigFile(t, name, content))
+			if err != nil {
+				t.Fatalf("LoadConfig returned error %q", err)
+			}
+
+			if !reflect.DeepEqual(cfg, expected) {
+				t.Errorf("LoadConfig = %+v, want %+v", cfg, expected)
+			}
+		})
+	}
+
+	t.Run("should override file settings with environment variables", func(t *testing.T) {
+		t.Setenv("DCACHE_PORT", "5000")
+		t.Setenv("DCACHE_WRITE_TIMEOUT", "1s")
+
+		cfg, err := LoadConfig(writeConfigFile(t, "dcache.yaml", "port: 4000\nmax_conns: 10\n"))
+		if err != nil {
+			t.Fatalf("LoadConfig returned error %q", err)
+		}
+
+		if cfg.Port != 5000 || cfg.WriteTimeout != time.Second || cfg.MaxConns != 10 {
+			t.Errorf("LoadConfig = %+v, want port 5000, write timeout 1s and max conns 10", cfg)
+		}
+	})
+
+	t.Run("should return an error on invalid settings", func(t *testing.T) {
+		invalid := map[string]string{
+			"unknown.yaml":   "foo: bar\n",
+			"port.yaml":      "port: 70000\n",
+			"key.yaml":       "max_key_length: 256\n",
+			"eviction.yaml":  "eviction_policy: random\n",
+			"memory.yaml":    "max_memory: 1KB\n",
+			"value.yaml":     "max_value_length: 4GB\n",
+			"overflow.yaml":  "max_memory: 17179869184GB\n",
+			"duration.json":  `{"read_timeout": 30}`,
+			"extension.conf": "port = 4000\n",
+		}
+
+		for name, content := range invalid {
+			if _, err := LoadConfig(writeConfigFile(t, name, content)); err == nil {
+				t.Errorf("LoadConfig(%s) should return error", name)
+			}
+		}
+	})
+}
+
+func TestConfigBucketCapacities(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxValueLength = 1000
+	cfg.MaxMemory = 3 * 1024 * 1024
+
+	capacities := cfg.bucketCapacities()
+	expected := map[int]int{
+		64:   1024 * 1024 / 68,
+		256:  1024 * 1024 / 260,
+		1000: 1024 * 1024 / 1004,
+	}
+
+	if len(capacities) != len(expected) {
+		t.Fatalf("bucketCapacities = %v, want %v", capacities, expected)
+	}
+
+	for size, capacity := range expected {
+		if capacities[size] != capacity {
+			t.Errorf("bucketCapacities = %v, want %v", capacities, expected)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package command
+
+import (
+	"github.com/joaovictorsl/dcache/core"
+	"github.com/joaovictorsl/fooche"
+)
+
+// Returns the user the connection is authenticated as, answered by the server.
+type ACLWhoamiCommand struct{}
+
+func (msg *ACLWhoamiCommand) String() string {
+	return "ACL WHOAMI"
+}
+
+func (msg *ACLWhoamiCommand) Type() byte {
+	return core.CMD_ACL_WHOAMI
+}
+
+func (msg *ACLWhoamiCommand) Execute(c fooche.ICache) []byte {
+	return []byte{core.CMD_EXEC_FAILED}
+}
+
+func (msg *ACLWhoamiCommand) ModifiesCache() bool {
+	return false
+}
+
+func NewACLWhoamiCommand() *ACLWhoamiCommand {
+	return &ACLWhoamiCommand{}
+}
+
+// Lists users and their permissions, answered by the server.
+type ACLListCommand struct{}
+
+func (msg *ACLListCommand) String() string {
+	return "ACL LIST"
+}
+
+func (msg *ACLListCommand) Type() byte {
+	return core.CMD_ACL_LIST
+}
+
+func (msg *ACLListCommand) Execute(c fooche.ICache) []byte {
+	return []byte{core.CMD_EXEC_FAILED}
+}
+
+func (msg *ACLListCommand) ModifiesCache() bool {
+	return false
+}
+
+func NewACLListCommand() *ACLListCommand {
+	return &ACLListCommand{}
+}
@@ -0,0 +1,52 @@
+package command
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/joaovictorsl/dcache/core"
+	"github.com/joaovictorsl/fooche"
+)
+
+// Sets a key only if it's not stored, failing otherwise.
+type AddCommand struct {
+	Key   string
+	Value []byte
+	TTL   time.Duration
+}
+
+func (msg *AddCommand) String() string {
+	return fmt.Sprintf("ADD %s %s %d", msg.Key, msg.Value, msg.TTL.Milliseconds())
+}
+
+func (msg *AddCommand) Type() byte {
+	return core.CMD_ADD
+}
+
+// Checking and setting the key is only atomic if no other write runs in between, the server serializes writes.
+func (msg *AddCommand) Execute(c fooche.ICache) []byte {
+	if c.Has(msg.Key) {
+		return []byte{core.CMD_EXEC_FAILED}
+	}
+
+	err := c.Set(msg.Key, msg.Value, msg.TTL)
+	if err != nil {
+		log.Println(err.Error())
+		return []byte{core.CMD_EXEC_FAILED}
+	}
+
+	return []byte{core.CMD_EXEC_SUCCEEDED}
+}
+
+func (msg *AddCommand) ModifiesCache() bool {
+	return true
+}
+
+func NewAddCommand(key string, value []byte, ttl int) *AddCommand {
+	return &AddCommand{
+		Key:   key,
+		Value: value,
+		TTL:   time.Duration(ttl) * time.Millisecond,
+	}
+}
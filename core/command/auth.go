@@ -0,0 +1,39 @@
+package command
+
+import (
+	"fmt"
+
+	"github.com/joaovictorsl/dcache/core"
+	"github.com/joaovictorsl/fooche"
+)
+
+// Authenticates the connection, answered by the server. An empty username means the default user,
+// whose secret is the server password or one of its tokens.
+type AuthCommand struct {
+	Username string
+	Secret   string
+}
+
+// The secret is left out so it doesn't end up in logs
+func (msg *AuthCommand) String() string {
+	return fmt.Sprintf("AUTH %s", msg.Username)
+}
+
+func (msg *AuthCommand) Type() byte {
+	return core.CMD_AUTH
+}
+
+func (msg *AuthCommand) Execute(c fooche.ICache) []byte {
+	return []byte{core.CMD_EXEC_FAILED}
+}
+
+func (msg *AuthCommand) ModifiesCache() bool {
+	return false
+}
+
+func NewAuthCommand(username, secret string) *AuthCommand {
+	return &AuthCommand{
+		Username: username,
+		Secret:   secret,
+	}
+}
@@ -0,0 +1,29 @@
+package command
+
+import (
+	"github.com/joaovictorsl/dcache/core"
+	"github.com/joaovictorsl/fooche"
+)
+
+// Lists the clients connected to the server, answered by the server.
+type ClientListCommand struct{}
+
+func (msg *ClientListCommand) String() string {
+	return "CLIENT LIST"
+}
+
+func (msg *ClientListCommand) Type() byte {
+	return core.CMD_CLIENT_LIST
+}
+
+func (msg *ClientListCommand) Execute(c fooche.ICache) []byte {
+	return []byte{core.CMD_EXEC_FAILED}
+}
+
+func (msg *ClientListCommand) ModifiesCache() bool {
+	return false
+}
+
+func NewClientListCommand() *ClientListCommand {
+	return &ClientListCommand{}
+}
@@ -0,0 +1,29 @@
+package command
+
+import (
+	"github.com/joaovictorsl/dcache/core"
+	"github.com/joaovictorsl/fooche"
+)
+
+// Lists the cluster members known by the server and their state, answered by the server.
+type ClusterNodesCommand struct{}
+
+func (msg *ClusterNodesCommand) String() string {
+	return "CLUSTER NODES"
+}
+
+func (msg *ClusterNodesCommand) Type() byte {
+	return core.CMD_CLUSTER_NODES
+}
+
+func (msg *ClusterNodesCommand) Execute(c fooche.ICache) []byte {
+	return []byte{core.CMD_EXEC_FAILED}
+}
+
+func (msg *ClusterNodesCommand) ModifiesCache() bool {
+	return false
+}
+
+func NewClusterNodesCommand() *ClusterNodesCommand {
+	return &ClusterNodesCommand{}
+}
@@ -17,6 +17,13 @@ func SetCmdAsBytes(k string, v []byte, ttl uint32) []byte {
 	return cmd
 }
 
+// Encoded like SET, only the command type differs.
+func AddCmdAsBytes(k string, v []byte, ttl uint32) []byte {
+	cmd := SetCmdAsBytes(k, v, ttl)
+	cmd[0] = core.CMD_ADD
+	return cmd
+}
+
 func DeleteCmdAsBytes(k string) []byte {
 	return keyOnlyCmdAsBytes(core.CMD_DELETE, k)
 }
@@ -29,6 +36,80 @@ func HasCmdAsBytes(k string) []byte {
 	return keyOnlyCmdAsBytes(core.CMD_HAS, k)
 }
 
+func ClientListCmdAsBytes() []byte {
+	return []byte{core.CMD_CLIENT_LIST}
+}
+
+func HelloCmdAsBytes() []byte {
+	return []byte{core.CMD_HELLO}
+}
+
+// Username and secret can't be longer than 255 bytes, their length is a single byte.
+func AuthCmdAsBytes(username, secret string) []byte {
+	cmd := make([]byte, 0, 3+len(username)+len(secret))
+	cmd = append(cmd, core.CMD_AUTH, byte(len(username)))
+	cmd = append(cmd, username...)
+	cmd = append(cmd, byte(len(secret)))
+	return append(cmd, secret...)
+}
+
+func ACLWhoamiCmdAsBytes() []byte {
+	return []byte{core.CMD_ACL_WHOAMI}
+}
+
+func ACLListCmdAsBytes() []byte {
+	return []byte{core.CMD_ACL_LIST}
+}
+
+func SaveCmdAsBytes() []byte {
+	return []byte{core.CMD_SAVE}
+}
+
+func SyncCmdAsBytes() []byte {
+	return []byte{core.CMD_SYNC}
+}
+
+func StatsCmdAsBytes() []byte {
+	return []byte{core.CMD_STATS}
+}
+
+// Sends the members known by the sender, the receiver answers with the ones it knows.
+func GossipCmdAsBytes(members []GossipMember) []byte {
+	return append([]byte{core.CMD_GOSSIP}, GossipMembersAsBytes(members)...)
+}
+
+// Encodes members as sent by GOSSIP and its response: their count as a uint16 followed by each member's
+// address, prefixed by its length, and heartbeat.
+func GossipMembersAsBytes(members []GossipMember) []byte {
+	b := make([]byte, 2)
+	binary.LittleEndian.PutUint16(b, uint16(len(members)))
+	for _, m := range members {
+		b = append(b, byte(len(m.Addr)))
+		b = append(b, m.Addr...)
+		b = binary.LittleEndian.AppendUint64(b, m.Heartbeat)
+	}
+
+	return b
+}
+
+func ClusterNodesCmdAsBytes() []byte {
+	return []byte{core.CMD_CLUSTER_NODES}
+}
+
+// Lists up to count keys greater than after, an empty after starts from the first key.
+func ScanCmdAsBytes(after string, count uint16) []byte {
+	cmd := make([]byte, 2+len(after)+2)
+	cmd[0] = core.CMD_SCAN
+	cmd[1] = byte(len(after))
+	copy(cmd[2:], after)
+	binary.LittleEndian.PutUint16(cmd[2+len(after):], count)
+	return cmd
+}
+
+func DumpCmdAsBytes(k string) []byte {
+	return keyOnlyCmdAsBytes(core.CMD_DUMP, k)
+}
+
 func keyOnlyCmdAsBytes(cmdType byte, k string) []byte {
 	cmd := make([]byte, 2+len(k))
 	cmd[0] = cmdType
@@ -36,3 +117,26 @@ func keyOnlyCmdAsBytes(cmdType byte, k string) []byte {
 	copy(cmd[2:], k)
 	return cmd
 }
+
+// Opens an invalidation stream, for the keys starting with one of prefixes if any is given. Prefixes can't
+// be longer than 255 bytes, their length is a single byte.
+func TrackingCmdAsBytes(prefixes ...string) []byte {
+	cmd := []byte{core.CMD_TRACKING}
+	return append(cmd, KeysAsBytes(prefixes)...)
+}
+
+// Tracks the reads of the connection for the invalidation stream streamID, 0 stops tracking them.
+func ClientTrackingCmdAsBytes(streamID uint64) []byte {
+	return binary.LittleEndian.AppendUint64([]byte{core.CMD_CLIENT_TRACKING}, streamID)
+}
+
+// Encodes keys as listed by SCAN and invalidation messages, each one prefixed by a byte with its length.
+func KeysAsBytes(keys []string) []byte {
+	b := make([]byte, 0)
+	for _, k := range keys {
+		b = append(b, byte(len(k)))
+		b = append(b, k...)
+	}
+
+	return b
+}
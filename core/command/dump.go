@@ -0,0 +1,33 @@
+package command
+
+import (
+	"fmt"
+
+	"github.com/joaovictorsl/dcache/core"
+	"github.com/joaovictorsl/fooche"
+)
+
+// Gets a value along with its remaining TTL, answered by the server.
+type DumpCommand struct {
+	Key string
+}
+
+func (msg *DumpCommand) String() string {
+	return fmt.Sprintf("DUMP %s", msg.Key)
+}
+
+func (msg *DumpCommand) Type() byte {
+	return core.CMD_DUMP
+}
+
+func (msg *DumpCommand) Execute(c fooche.ICache) []byte {
+	return []byte{core.CMD_EXEC_FAILED}
+}
+
+func (msg *DumpCommand) ModifiesCache() bool {
+	return false
+}
+
+func NewDumpCommand(k string) *DumpCommand {
+	return &DumpCommand{Key: k}
+}
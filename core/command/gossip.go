@@ -0,0 +1,41 @@
+package command
+
+import (
+	"fmt"
+
+	"github.com/joaovictorsl/dcache/core"
+	"github.com/joaovictorsl/fooche"
+)
+
+// A cluster member as gossiped between servers.
+type GossipMember struct {
+	// Address members and clients reach it at
+	Addr string
+	// Increased by the member itself, a member whose heartbeat stops increasing is suspected to be down
+	Heartbeat uint64
+}
+
+// Exchanges cluster members with another server, answered by the server.
+type GossipCommand struct {
+	Members []GossipMember
+}
+
+func (msg *GossipCommand) String() string {
+	return fmt.Sprintf("GOSSIP %d members", len(msg.Members))
+}
+
+func (msg *GossipCommand) Type() byte {
+	return core.CMD_GOSSIP
+}
+
+func (msg *GossipCommand) Execute(c fooche.ICache) []byte {
+	return []byte{core.CMD_EXEC_FAILED}
+}
+
+func (msg *GossipCommand) ModifiesCache() bool {
+	return false
+}
+
+func NewGossipCommand(members []GossipMember) *GossipCommand {
+	return &GossipCommand{Members: members}
+}
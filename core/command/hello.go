@@ -0,0 +1,30 @@
+package command
+
+import (
+	"github.com/joaovictorsl/dcache/core"
+	"github.com/joaovictorsl/fooche"
+)
+
+// Asks the server about the connection, such as whether it must authenticate. Answered by the server
+// and allowed before authenticating.
+type HelloCommand struct{}
+
+func (msg *HelloCommand) String() string {
+	return "HELLO"
+}
+
+func (msg *HelloCommand) Type() byte {
+	return core.CMD_HELLO
+}
+
+func (msg *HelloCommand) Execute(c fooche.ICache) []byte {
+	return []byte{core.CMD_EXEC_FAILED}
+}
+
+func (msg *HelloCommand) ModifiesCache() bool {
+	return false
+}
+
+func NewHelloCommand() *HelloCommand {
+	return &HelloCommand{}
+}
@@ -2,6 +2,8 @@ package command
 
 import "github.com/joaovictorsl/fooche"
 
+// Commands that act on the server instead of the cache, such as CLIENT LIST, are answered by the
+// server itself and fail if executed against a cache.
 type Command interface {
 	String() string
 	Type() byte
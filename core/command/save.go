@@ -0,0 +1,29 @@
+package command
+
+import (
+	"github.com/joaovictorsl/dcache/core"
+	"github.com/joaovictorsl/fooche"
+)
+
+// Writes a snapshot of the cache to disk, answered by the server.
+type SaveCommand struct{}
+
+func (msg *SaveCommand) String() string {
+	return "SAVE"
+}
+
+func (msg *SaveCommand) Type() byte {
+	return core.CMD_SAVE
+}
+
+func (msg *SaveCommand) Execute(c fooche.ICache) []byte {
+	return []byte{core.CMD_EXEC_FAILED}
+}
+
+func (msg *SaveCommand) ModifiesCache() bool {
+	return false
+}
+
+func NewSaveCommand() *SaveCommand {
+	return &SaveCommand{}
+}
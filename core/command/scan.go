@@ -0,0 +1,35 @@
+package command
+
+import (
+	"fmt"
+
+	"github.com/joaovictorsl/dcache/core"
+	"github.com/joaovictorsl/fooche"
+)
+
+// Lists stored keys in lexical order, answered by the server.
+type ScanCommand struct {
+	// Keys listed are greater than After
+	After string
+	Count uint16
+}
+
+func (msg *ScanCommand) String() string {
+	return fmt.Sprintf("SCAN %q %d", msg.After, msg.Count)
+}
+
+func (msg *ScanCommand) Type() byte {
+	return core.CMD_SCAN
+}
+
+func (msg *ScanCommand) Execute(c fooche.ICache) []byte {
+	return []byte{core.CMD_EXEC_FAILED}
+}
+
+func (msg *ScanCommand) ModifiesCache() bool {
+	return false
+}
+
+func NewScanCommand(after string, count uint16) *ScanCommand {
+	return &ScanCommand{After: after, Count: count}
+}
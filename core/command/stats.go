@@ -0,0 +1,29 @@
+package command
+
+import (
+	"github.com/joaovictorsl/dcache/core"
+	"github.com/joaovictorsl/fooche"
+)
+
+// Describes the server and its replication state, answered by the server.
+type StatsCommand struct{}
+
+func (msg *StatsCommand) String() string {
+	return "STATS"
+}
+
+func (msg *StatsCommand) Type() byte {
+	return core.CMD_STATS
+}
+
+func (msg *StatsCommand) Execute(c fooche.ICache) []byte {
+	return []byte{core.CMD_EXEC_FAILED}
+}
+
+func (msg *StatsCommand) ModifiesCache() bool {
+	return false
+}
+
+func NewStatsCommand() *StatsCommand {
+	return &StatsCommand{}
+}
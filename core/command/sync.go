@@ -0,0 +1,29 @@
+package command
+
+import (
+	"github.com/joaovictorsl/dcache/core"
+	"github.com/joaovictorsl/fooche"
+)
+
+// Turns the connection into a replication link, answered by the server.
+type SyncCommand struct{}
+
+func (msg *SyncCommand) String() string {
+	return "SYNC"
+}
+
+func (msg *SyncCommand) Type() byte {
+	return core.CMD_SYNC
+}
+
+func (msg *SyncCommand) Execute(c fooche.ICache) []byte {
+	return []byte{core.CMD_EXEC_FAILED}
+}
+
+func (msg *SyncCommand) ModifiesCache() bool {
+	return false
+}
+
+func NewSyncCommand() *SyncCommand {
+	return &SyncCommand{}
+}
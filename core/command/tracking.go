@@ -0,0 +1,65 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/joaovictorsl/dcache/core"
+	"github.com/joaovictorsl/fooche"
+)
+
+// Turns the connection into a stream of invalidation messages, answered by the server. Without prefixes the
+// stream receives the keys read by connections tracking with it, see ClientTrackingCommand, otherwise every
+// key starting with one of the prefixes.
+type TrackingCommand struct {
+	Prefixes []string
+}
+
+func (msg *TrackingCommand) String() string {
+	if len(msg.Prefixes) == 0 {
+		return "TRACKING"
+	}
+	return fmt.Sprintf("TRACKING PREFIX %s", strings.Join(msg.Prefixes, " "))
+}
+
+func (msg *TrackingCommand) Type() byte {
+	return core.CMD_TRACKING
+}
+
+func (msg *TrackingCommand) Execute(c fooche.ICache) []byte {
+	return []byte{core.CMD_EXEC_FAILED}
+}
+
+func (msg *TrackingCommand) ModifiesCache() bool {
+	return false
+}
+
+func NewTrackingCommand(prefixes []string) *TrackingCommand {
+	return &TrackingCommand{Prefixes: prefixes}
+}
+
+// Makes the server send the keys the connection reads to the invalidation stream StreamID once they change,
+// answered by the server. 0 stops tracking the connection's reads.
+type ClientTrackingCommand struct {
+	StreamID uint64
+}
+
+func (msg *ClientTrackingCommand) String() string {
+	return fmt.Sprintf("CLIENT TRACKING %d", msg.StreamID)
+}
+
+func (msg *ClientTrackingCommand) Type() byte {
+	return core.CMD_CLIENT_TRACKING
+}
+
+func (msg *ClientTrackingCommand) Execute(c fooche.ICache) []byte {
+	return []byte{core.CMD_EXEC_FAILED}
+}
+
+func (msg *ClientTrackingCommand) ModifiesCache() bool {
+	return false
+}
+
+func NewClientTrackingCommand(streamID uint64) *ClientTrackingCommand {
+	return &ClientTrackingCommand{StreamID: streamID}
+}
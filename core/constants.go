@@ -1,14 +1,46 @@
 package core
 
+// Command codes, the first byte of every command. Values are part of the protocol and never change.
 const (
-	CMD_SET byte = iota
-	CMD_GET
-	CMD_HAS
-	CMD_DELETE
+	CMD_SET             byte = 0
+	CMD_GET             byte = 1
+	CMD_HAS             byte = 2
+	CMD_DELETE          byte = 3
+	CMD_CLIENT_LIST     byte = 8
+	CMD_HELLO           byte = 11
+	CMD_AUTH            byte = 12
+	CMD_ACL_WHOAMI      byte = 15
+	CMD_ACL_LIST        byte = 16
+	CMD_SAVE            byte = 19
+	CMD_SYNC            byte = 20
+	CMD_STATS           byte = 21
+	CMD_SCAN            byte = 24
+	CMD_DUMP            byte = 25
+	CMD_GOSSIP          byte = 26
+	CMD_CLUSTER_NODES   byte = 27
+	CMD_ADD             byte = 29
+	CMD_TRACKING        byte = 30
+	CMD_CLIENT_TRACKING byte = 31
+)
 
-	CMD_EXEC_SUCCEEDED
-	CMD_EXEC_FAILED
+// Status codes, the first byte of every response. They share the values of command codes, so no value is
+// used by both.
+const (
+	CMD_EXEC_SUCCEEDED     byte = 4
+	CMD_EXEC_FAILED        byte = 5
+	INVALID_COMMAND_CODE   byte = 6
+	MAX_CONNS_REACHED_CODE byte = 9
+	AUTH_REQUIRED_CODE     byte = 13
+	NO_PERMISSION_CODE     byte = 17
+	READ_ONLY_CODE         byte = 22
+	MOVED_CODE             byte = 28
+)
 
-	INVALID_COMMAND_CODE
-	INVALID_COMMAND string = "invalid command"
+// Messages following the status codes of the same name.
+const (
+	INVALID_COMMAND   string = "invalid command"
+	MAX_CONNS_REACHED string = "max connections reached"
+	AUTH_REQUIRED     string = "auth required"
+	NO_PERMISSION     string = "no permission"
+	READ_ONLY         string = "read only replica"
 )
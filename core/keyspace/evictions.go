@@ -0,0 +1,63 @@
+package keyspace
+
+import (
+	"sync"
+
+	"github.com/joaovictorsl/fooche/evict"
+)
+
+// Records the keys evicted by the policies it wraps, so a Cache can forget them without checking
+// every key. fooche doesn't tell which keys it evicts otherwise.
+type Evictions struct {
+	mu   sync.Mutex
+	keys map[string]bool
+}
+
+func NewEvictions() *Evictions {
+	return &Evictions{keys: make(map[string]bool)}
+}
+
+// Wraps p, recording the keys it evicts.
+func (e *Evictions) Policy(p evict.EvictionPolicy[string]) evict.EvictionPolicy[string] {
+	return &recordingPolicy{EvictionPolicy: p, evictions: e}
+}
+
+func (e *Evictions) add(k string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.keys[k] = true
+}
+
+// Returns the keys evicted since the last call, e may be nil.
+func (e *Evictions) take() []string {
+	if e == nil {
+		return nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	keys := make([]string, 0, len(e.keys))
+	for k := range e.keys {
+		keys = append(keys, k)
+	}
+	e.keys = make(map[string]bool)
+
+	return keys
+}
+
+type recordingPolicy struct {
+	evict.EvictionPolicy[string]
+	evictions *Evictions
+}
+
+// Called by the cache holding its lock, the key is only recorded.
+func (p *recordingPolicy) RecordAccess(k string) (string, bool) {
+	evicted, eviction := p.EvictionPolicy.RecordAccess(k)
+	if eviction {
+		p.evictions.add(evicted)
+	}
+
+	return evicted, eviction
+}
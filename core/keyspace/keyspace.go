@@ -0,0 +1,349 @@
+// Package keyspace wraps a fooche.ICache keeping track of its keys and when they expire, which
+// fooche doesn't expose, so every key can be visited, e.g. to write a snapshot.
+package keyspace
+
+import (
+	"container/heap"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/joaovictorsl/fooche"
+)
+
+// A fooche.ICache that knows its keys.
+//
+// Keys evicted or expired by the underlying cache stay tracked until they are looked up, visited by Range or
+// swept.
+type Cache struct {
+	cache fooche.ICache
+	// Whether the underlying cache honors TTLs, when it doesn't keys never expire
+	expires bool
+
+	// Held while changing the underlying cache so it and keys agree
+	mu sync.Mutex
+	// Maps keys to their expiration time, zero if they don't expire
+	keys map[string]time.Time
+	// Keys that expire by expiration time, entries of keys set again or deleted since are skipped
+	expiring expiryHeap
+	// Keys evicted by the underlying cache, nil if they aren't recorded
+	evictions *Evictions
+	// Called with the keys found evicted or expired, nil if no one is told. Set before the cache is used
+	removed func(k string)
+}
+
+var _ fooche.ICache = &Cache{}
+
+// Wraps c, expires tells whether c honors the TTL given to Set.
+func New(c fooche.ICache, expires bool) *Cache {
+	return &Cache{
+		cache:   c,
+		expires: expires,
+		keys:    make(map[string]time.Time),
+	}
+}
+
+// Makes Sweep forget the keys e records, e must wrap the eviction policy of the underlying cache. Must be
+// called before c is used.
+func (c *Cache) WatchEvictions(e *Evictions) {
+	c.evictions = e
+}
+
+// Makes c call fn, outside of its lock, with every key it finds evicted or expired as it stops tracking it.
+// Keys removed with Delete are not included. Must be called before c is used.
+func (c *Cache) OnRemove(fn func(k string)) {
+	c.removed = fn
+}
+
+func (c *Cache) Set(k string, v []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.cache.Set(k, v, ttl); err != nil {
+		return err
+	}
+
+	var expiresAt time.Time
+	if c.expires {
+		expiresAt = time.Now().Add(ttl)
+		heap.Push(&c.expiring, expiry{k, expiresAt})
+	}
+	c.keys[k] = expiresAt
+	c.compactExpiring()
+
+	return nil
+}
+
+func (c *Cache) Has(k string) bool {
+	if c.cache.Has(k) {
+		return true
+	}
+
+	c.forget(k)
+	return false
+}
+
+func (c *Cache) Get(k string) ([]byte, error) {
+	v, err := c.cache.Get(k)
+	if err != nil {
+		c.forget(k)
+	}
+
+	return v, err
+}
+
+func (c *Cache) ComputeIfAbsent(k string, computeValue func() []byte) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, tracked := c.keys[k]
+	v, err := c.cache.ComputeIfAbsent(k, computeValue)
+	if err == nil && !tracked {
+		// fooche doesn't tell which TTL it used, the key is tracked without expiration
+		c.keys[k] = time.Time{}
+	}
+
+	return v, err
+}
+
+func (c *Cache) Delete(k string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cache.Delete(k)
+	delete(c.keys, k)
+	c.compactExpiring()
+}
+
+// Drops the expirations of keys set again or deleted once they are most of the heap, so keys written often
+// don't grow it. Called holding c's lock.
+func (c *Cache) compactExpiring() {
+	if len(c.expiring) <= 2*len(c.keys)+sweepChunk {
+		return
+	}
+
+	kept := c.expiring[:0]
+	for _, e := range c.expiring {
+		if expiresAt, ok := c.keys[e.key]; ok && expiresAt.Equal(e.at) {
+			kept = append(kept, e)
+		}
+	}
+	for i := len(kept); i < len(c.expiring); i++ {
+		c.expiring[i] = expiry{}
+	}
+	c.expiring = kept
+	heap.Init(&c.expiring)
+}
+
+func (c *Cache) String() string {
+	return c.cache.String()
+}
+
+// Stops tracking k if it's no longer in the underlying cache.
+func (c *Cache) forget(k string) {
+	c.mu.Lock()
+	_, ok := c.keys[k]
+	gone := ok && !c.cache.Has(k)
+	if gone {
+		delete(c.keys, k)
+	}
+	c.mu.Unlock()
+
+	if gone {
+		c.notifyRemoved(k)
+	}
+}
+
+func (c *Cache) notifyRemoved(k string) {
+	if c.removed != nil {
+		c.removed(k)
+	}
+}
+
+// Stops tracking the keys that expired, and the ones evicted, telling OnRemove about them. Keys are checked
+// in chunks of sweepChunk, releasing c's lock in between.
+//
+// Expired keys are found without using the underlying cache. With WatchEvictions evicted keys are checked
+// only once gone, so sweeping doesn't count as using keys for the eviction policy, and must be done regularly
+// as they are kept until swept. Without it sweepSamples keys picked at random are checked against the
+// underlying cache every sweep, which may count as using them.
+func (c *Cache) Sweep() {
+	for c.sweepExpired(time.Now()) {
+	}
+
+	evicted := c.evictions.take()
+	if c.evictions == nil {
+		evicted = c.sample(sweepSamples)
+	}
+	for len(evicted) != 0 {
+		n := len(evicted)
+		if n > sweepChunk {
+			n = sweepChunk
+		}
+		c.sweepEvicted(evicted[:n])
+		evicted = evicted[n:]
+	}
+}
+
+const (
+	// Keys checked at most at once by Sweep
+	sweepChunk = 256
+	// Keys checked for eviction by Sweep when evictions aren't watched
+	sweepSamples = 16 * sweepChunk
+)
+
+// Returns up to n tracked keys. Map iteration starts at a random key, so repeated calls cover every key over
+// time.
+func (c *Cache) sample(n int) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	keys := make([]string, 0, n)
+	for k := range c.keys {
+		if len(keys) == n {
+			break
+		}
+		keys = append(keys, k)
+	}
+
+	return keys
+}
+
+// Forgets up to sweepChunk keys expired by now, returning whether there may be more.
+func (c *Cache) sweepExpired(now time.Time) bool {
+	gone := make([]string, 0)
+
+	c.mu.Lock()
+	checked := 0
+	for ; checked < sweepChunk && len(c.expiring) != 0 && !now.Before(c.expiring[0].at); checked++ {
+		e := heap.Pop(&c.expiring).(expiry)
+		if expiresAt, ok := c.keys[e.key]; ok && expiresAt.Equal(e.at) {
+			delete(c.keys, e.key)
+			gone = append(gone, e.key)
+		}
+	}
+	c.mu.Unlock()
+
+	for _, k := range gone {
+		c.notifyRemoved(k)
+	}
+	return checked == sweepChunk
+}
+
+// Forgets the keys given that are no longer in the underlying cache. Keys recorded as evicted are gone unless
+// set again since, so checking them doesn't use them.
+func (c *Cache) sweepEvicted(keys []string) {
+	gone := make([]string, 0)
+
+	c.mu.Lock()
+	for _, k := range keys {
+		if _, ok := c.keys[k]; ok && !c.cache.Has(k) {
+			delete(c.keys, k)
+			gone = append(gone, k)
+		}
+	}
+	c.mu.Unlock()
+
+	for _, k := range gone {
+		c.notifyRemoved(k)
+	}
+}
+
+type expiry struct {
+	key string
+	at  time.Time
+}
+
+// Min-heap of expirations, see container/heap.
+type expiryHeap []expiry
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].at.Before(h[j].at) }
+func (h expiryHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *expiryHeap) Push(x any)        { *h = append(*h, x.(expiry)) }
+func (h *expiryHeap) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
+
+// Amount of tracked keys, including the ones not yet known to be evicted or expired.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.keys)
+}
+
+// Calls fn for every stored key in lexical order until it returns false. expiresAt is zero for keys
+// that don't expire.
+//
+// Keys changed while iterating are visited with their current value if they weren't visited yet.
+func (c *Cache) Range(fn func(key string, value []byte, expiresAt time.Time) bool) {
+	c.mu.Lock()
+	keys := make([]string, 0, len(c.keys))
+	for k := range c.keys {
+		keys = append(keys, k)
+	}
+	c.mu.Unlock()
+
+	sort.Strings(keys)
+	for _, k := range keys {
+		v, expiresAt, ok := c.lookup(k)
+		if ok && !fn(k, v, expiresAt) {
+			return
+		}
+	}
+}
+
+// Returns the value and expiration time of k, forgetting it if it's gone or expired.
+func (c *Cache) lookup(k string) ([]byte, time.Time, bool) {
+	c.mu.Lock()
+	expiresAt, tracked := c.keys[k]
+	if !tracked {
+		c.mu.Unlock()
+		return nil, expiresAt, false
+	}
+
+	v, err := c.cache.Get(k)
+	if err != nil || (!expiresAt.IsZero() && !time.Now().Before(expiresAt)) {
+		delete(c.keys, k)
+		c.mu.Unlock()
+		c.notifyRemoved(k)
+		return nil, expiresAt, false
+	}
+	c.mu.Unlock()
+
+	return v, expiresAt, true
+}
+
+// Returns up to count stored keys greater than after, in lexical order.
+func (c *Cache) Keys(after string, count int) []string {
+	c.mu.Lock()
+	candidates := make([]string, 0, len(c.keys))
+	for k := range c.keys {
+		if k > after {
+			candidates = append(candidates, k)
+		}
+	}
+	c.mu.Unlock()
+
+	sort.Strings(candidates)
+	keys := make([]string, 0, count)
+	for _, k := range candidates {
+		if len(keys) == count {
+			break
+		}
+		if _, _, ok := c.lookup(k); ok {
+			keys = append(keys, k)
+		}
+	}
+
+	return keys
+}
+
+// Returns the value and expiration time of k, expiresAt is zero if it doesn't expire.
+func (c *Cache) Lookup(k string) (value []byte, expiresAt time.Time, ok bool) {
+	return c.lookup(k)
+}
@@ -0,0 +1,181 @@
+package keyspace
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joaovictorsl/fooche"
+	"github.com/joaovictorsl/fooche/evict"
+)
+
+type rangedKey struct {
+	key     string
+	value   string
+	expires bool
+}
+
+func rangeKeys(c *Cache) []rangedKey {
+	keys := make([]rangedKey, 0)
+	c.Range(func(key string, value []byte, expiresAt time.Time) bool {
+		keys = append(keys, rangedKey{key, string(value), !expiresAt.IsZero()})
+		return true
+	})
+
+	return keys
+}
+
+func TestCacheRange(t *testing.T) {
+	c := New(fooche.NewCleanInterval(time.Hour), true)
+	c.Set("b", []byte("2"), time.Minute)
+	c.Set("a", []byte("1"), time.Minute)
+	c.Set("c", []byte("3"), time.Millisecond)
+	c.Set("d", []byte("4"), time.Minute)
+	c.Delete("d")
+
+	time.Sleep(5 * time.Millisecond)
+
+	keys := rangeKeys(c)
+	expected := []rangedKey{{"a", "1", true}, {"b", "2", true}}
+	if len(keys) != len(expected) || keys[0] != expected[0] || keys[1] != expected[1] {
+		t.Errorf("Range = %v, want %v", keys, expected)
+	}
+
+	if c.Len() != 2 {
+		t.Errorf("expected expired key to be forgotten, got %d keys", c.Len())
+	}
+}
+
+func TestCacheWithoutExpiration(t *testing.T) {
+	c := New(fooche.NewSimple(), false)
+	c.Set("a", []byte("1"), time.Millisecond)
+
+	if keys := rangeKeys(c); len(keys) != 1 || keys[0] != (rangedKey{"a", "1", false}) {
+		t.Errorf("Range = %v, want a single key without expiration", keys)
+	}
+}
+
+func TestCacheKeys(t *testing.T) {
+	c := New(fooche.NewCleanInterval(time.Hour), true)
+	for _, k := range []string{"d", "b", "a", "e"} {
+		c.Set(k, []byte(k), time.Minute)
+	}
+	c.Set("c", []byte("c"), time.Millisecond)
+
+	time.Sleep(5 * time.Millisecond)
+
+	pages := [][]string{c.Keys("", 2), c.Keys("b", 2), c.Keys("e", 2)}
+	expected := [][]string{{"a", "b"}, {"d", "e"}, {}}
+	for i, page := range pages {
+		if strings.Join(page, ",") != strings.Join(expected[i], ",") {
+			t.Errorf("page %d = %v, want %v", i, page, expected[i])
+		}
+	}
+
+	if _, expiresAt, ok := c.Lookup("a"); !ok || expiresAt.IsZero() {
+		t.Errorf("expected a to be found with its expiration time")
+	}
+	if _, _, ok := c.Lookup("c"); ok {
+		t.Errorf("expected expired key not to be found")
+	}
+}
+
+func TestCacheSweep(t *testing.T) {
+	c := New(fooche.NewCleanInterval(time.Hour), true)
+	removed := make([]string, 0)
+	c.OnRemove(func(k string) {
+		removed = append(removed, k)
+	})
+
+	c.Set("a", []byte("1"), time.Minute)
+	c.Set("b", []byte("2"), time.Millisecond)
+	c.Set("c", []byte("3"), time.Millisecond)
+	c.Set("c", []byte("3"), time.Minute)
+	c.Set("d", []byte("4"), time.Millisecond)
+	c.Delete("d")
+	for i := 0; i < 2*sweepChunk; i++ {
+		c.Set(fmt.Sprint("e", i), []byte("5"), time.Millisecond)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	c.Sweep()
+	if len(removed) != 1+2*sweepChunk || removed[0] != "b" {
+		t.Errorf("removed %d keys starting with %v, want b and the e keys", len(removed), removed[:1])
+	}
+	if c.Len() != 2 {
+		t.Errorf("expected keys set again not to expire, got %d keys", c.Len())
+	}
+	if len(c.expiring) != 2 {
+		t.Errorf("expected swept expirations to be dropped, got %d", len(c.expiring))
+	}
+}
+
+func TestCacheSweepEvictions(t *testing.T) {
+	evictions := NewEvictions()
+	lru := fooche.NewSimpleBounded(map[int]int{64: 2}, func(capacity int) evict.EvictionPolicy[string] {
+		return evictions.Policy(evict.NewLRU[string](capacity))
+	})
+	c := New(lru, false)
+	c.WatchEvictions(evictions)
+	removed := make([]string, 0)
+	c.OnRemove(func(k string) {
+		removed = append(removed, k)
+	})
+
+	c.Set("a", []byte("1"), 0)
+	c.Set("b", []byte("2"), 0)
+	c.Sweep()
+	c.Set("c", []byte("3"), 0)
+	if len(removed) != 0 {
+		t.Errorf("expected nothing to be removed before sweeping, got %v", removed)
+	}
+
+	// Sweeping doesn't use b, so it's evicted next
+	c.Sweep()
+	c.Set("d", []byte("4"), 0)
+	c.Sweep()
+	if strings.Join(removed, ",") != "a,b" {
+		t.Errorf("removed = %v, want [a b]", removed)
+	}
+	if c.Len() != 2 {
+		t.Errorf("expected evicted keys to be forgotten, got %d keys", c.Len())
+	}
+}
+
+func TestCacheExpiringCompaction(t *testing.T) {
+	c := New(fooche.NewCleanInterval(time.Hour), true)
+	for i := 0; i < 100*sweepChunk; i++ {
+		c.Set("hot", []byte("1"), time.Hour)
+		c.Set(fmt.Sprint("deleted", i), []byte("1"), time.Hour)
+		c.Delete(fmt.Sprint("deleted", i))
+	}
+
+	if len(c.expiring) > 2+sweepChunk {
+		t.Errorf("expected expirations of keys set again or deleted to be dropped, got %d", len(c.expiring))
+	}
+	if c.Sweep(); c.Len() != 1 {
+		t.Errorf("expected the hot key to stay tracked, got %d keys", c.Len())
+	}
+}
+
+func TestCacheSweepUnwatched(t *testing.T) {
+	// Evictions of caches given from outside aren't recorded
+	lru := fooche.NewSimpleBounded(map[int]int{64: 2}, func(capacity int) evict.EvictionPolicy[string] {
+		return evict.NewLRU[string](capacity)
+	})
+	c := New(lru, false)
+	removed := make([]string, 0)
+	c.OnRemove(func(k string) {
+		removed = append(removed, k)
+	})
+
+	for _, k := range []string{"a", "b", "c"} {
+		c.Set(k, []byte(k), 0)
+	}
+	c.Sweep()
+	if strings.Join(removed, ",") != "a" || c.Len() != 2 {
+		t.Errorf("removed = %v with %d keys left, want [a] with 2", removed, c.Len())
+	}
+}
@@ -0,0 +1,8 @@
+package core
+
+// States of a cluster member as seen by another member.
+const (
+	MEMBER_ALIVE   string = "alive"
+	MEMBER_SUSPECT string = "suspect"
+	MEMBER_DEAD    string = "dead"
+)
@@ -0,0 +1,224 @@
+package persist
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// When appended commands are synced to disk.
+const (
+	// After every command, nothing is lost on a crash but every write waits for the disk
+	FSYNC_ALWAYS = "always"
+	// Once per second by the caller through Sync, up to a second of commands may be lost
+	FSYNC_EVERYSEC = "everysec"
+	// Left to the operating system
+	FSYNC_NEVER = "never"
+)
+
+// Size of a record header: payload length, checksum and time
+const aofHeaderSize = 4 + 4 + 8
+
+// An append-only log of commands.
+//
+// Every record is made of the payload length as a uint32, the CRC-32 (Castagnoli) of the time and payload
+// as a uint32, the time the command ran as unix milliseconds in an int64 and the command as sent by clients.
+// Numbers are little endian.
+type AOF struct {
+	path   string
+	policy string
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+	// Whether there are records not yet synced to disk
+	dirty bool
+}
+
+// Opens the log at path for appending, creating it if needed.
+func OpenAOF(path, policy string) (*AOF, error) {
+	switch policy {
+	case FSYNC_ALWAYS, FSYNC_EVERYSEC, FSYNC_NEVER:
+	default:
+		return nil, fmt.Errorf("unknown fsync policy %q", policy)
+	}
+
+	a := &AOF{path: path, policy: policy}
+	return a, a.open()
+}
+
+func (a *AOF) open() error {
+	f, err := os.OpenFile(a.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	a.f = f
+	a.size = info.Size()
+	return nil
+}
+
+// Appends cmd with the time it ran. Callers keep records in the order commands changed the cache.
+//
+// The record is written before returning and synced to disk if the policy is FSYNC_ALWAYS.
+func (a *AOF) Append(t time.Time, cmd []byte) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	record := make([]byte, aofHeaderSize, aofHeaderSize+len(cmd))
+	binary.LittleEndian.PutUint32(record[0:4], uint32(len(cmd)))
+	binary.LittleEndian.PutUint64(record[8:16], uint64(t.UnixMilli()))
+	record = append(record, cmd...)
+	binary.LittleEndian.PutUint32(record[4:8], crc32.Checksum(record[8:], crcTable))
+
+	n, err := a.f.Write(record)
+	a.size += int64(n)
+	if err != nil {
+		return err
+	}
+
+	if a.policy == FSYNC_ALWAYS {
+		return a.f.Sync()
+	}
+
+	a.dirty = true
+	return nil
+}
+
+// Syncs appended records to disk, meant to be called every second with FSYNC_EVERYSEC.
+func (a *AOF) Sync() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.dirty {
+		return nil
+	}
+
+	a.dirty = false
+	return a.f.Sync()
+}
+
+// Size of the log in bytes.
+func (a *AOF) Size() int64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.size
+}
+
+func (a *AOF) Policy() string {
+	return a.policy
+}
+
+// Moves the log to oldPath and starts a new empty one. Commands appended from then on go to the new log,
+// so oldPath can be removed once a snapshot taken after Rotate returns is on disk.
+func (a *AOF) Rotate(oldPath string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.f.Sync(); err != nil {
+		return err
+	} else if err := a.f.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(a.path, oldPath); err != nil {
+		// Keeps appending to the same log
+		if openErr := a.open(); openErr != nil {
+			return errors.Join(err, openErr)
+		}
+		return err
+	}
+
+	a.dirty = false
+	return a.open()
+}
+
+func (a *AOF) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.f.Sync(); err != nil {
+		a.f.Close()
+		return err
+	}
+
+	return a.f.Close()
+}
+
+// Calls fn for every command in the log at path, in order, with the time it ran. Commands are at most
+// maxSize bytes.
+//
+// A record cut short or failing its checksum at the end of the log, as left by a crash while appending, is
+// removed and reported through truncated. A damaged record followed by more records, or one longer than
+// maxSize, is returned as an error leaving the log as it is. A missing log has no records.
+func ReplayAOF(path string, maxSize uint32, fn func(t time.Time, cmd []byte) error) (n int, truncated bool, err error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, false, err
+	}
+
+	r := bufio.NewReader(f)
+	var offset int64
+	header := make([]byte, aofHeaderSize)
+	for {
+		if _, err := io.ReadFull(r, header); err == io.EOF {
+			return n, false, nil
+		} else if err != nil {
+			break
+		}
+
+		size := binary.LittleEndian.Uint32(header[0:4])
+		if size > maxSize {
+			return n, false, fmt.Errorf("record at offset %d is %d bytes, more than the %d allowed", offset, size, maxSize)
+		}
+
+		cmd := make([]byte, size)
+		if _, err := io.ReadFull(r, cmd); err != nil {
+			break
+		}
+
+		crc := crc32.Update(crc32.Checksum(header[8:16], crcTable), crcTable, cmd)
+		if crc != binary.LittleEndian.Uint32(header[4:8]) {
+			if offset+int64(aofHeaderSize+len(cmd)) < info.Size() {
+				return n, false, fmt.Errorf("record at offset %d failed its checksum", offset)
+			}
+			break
+		}
+
+		t := time.UnixMilli(int64(binary.LittleEndian.Uint64(header[8:16])))
+		if err := fn(t, cmd); err != nil {
+			return n, false, err
+		}
+
+		offset += int64(aofHeaderSize + len(cmd))
+		n++
+	}
+
+	// Drops the damaged tail so new records are appended after the last good one
+	if err := f.Truncate(offset); err != nil {
+		return n, true, err
+	}
+
+	return n, true, f.Sync()
+}
@@ -0,0 +1,134 @@
+package persist
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func replayTestAOF(t *testing.T, path string) ([]string, bool) {
+	cmds := make([]string, 0)
+	n, truncated, err := ReplayAOF(path, 1024, func(_ time.Time, cmd []byte) error {
+		cmds = append(cmds, string(cmd))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReplayAOF returned error %q", err)
+	}
+	if n != len(cmds) {
+		t.Errorf("ReplayAOF returned %d commands, fn was called %d times", n, len(cmds))
+	}
+
+	return cmds, truncated
+}
+
+func TestAOF(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dcache.aof")
+	aof, err := OpenAOF(path, FSYNC_ALWAYS)
+	if err != nil {
+		t.Fatalf("OpenAOF returned error %q", err)
+	}
+
+	for _, cmd := range []string{"first", "second"} {
+		if err := aof.Append(time.Now(), []byte(cmd)); err != nil {
+			t.Fatalf("Append returned error %q", err)
+		}
+	}
+
+	t.Run("should replay the appended commands", func(t *testing.T) {
+		cmds, truncated := replayTestAOF(t, path)
+		if !reflect.DeepEqual(cmds, []string{"first", "second"}) || truncated {
+			t.Errorf("ReplayAOF = %v, truncated %v, want [first second]", cmds, truncated)
+		}
+
+		if aof.Size() != int64(2*aofHeaderSize+len("first")+len("second")) {
+			t.Errorf("unexpected size %d", aof.Size())
+		}
+	})
+
+	t.Run("should start a new log on Rotate", func(t *testing.T) {
+		oldPath := path + ".old"
+		if err := aof.Rotate(oldPath); err != nil {
+			t.Fatalf("Rotate returned error %q", err)
+		}
+		if err := aof.Append(time.Now(), []byte("third")); err != nil {
+			t.Fatalf("Append returned error %q", err)
+		}
+
+		if cmds, _ := replayTestAOF(t, oldPath); !reflect.DeepEqual(cmds, []string{"first", "second"}) {
+			t.Errorf("old log has %v, want [first second]", cmds)
+		}
+		if cmds, _ := replayTestAOF(t, path); !reflect.DeepEqual(cmds, []string{"third"}) {
+			t.Errorf("new log has %v, want [third]", cmds)
+		}
+	})
+
+	if err := aof.Close(); err != nil {
+		t.Fatalf("Close returned error %q", err)
+	}
+
+	t.Run("should remove a torn command at the end", func(t *testing.T) {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte{10, 0, 0, 0, 1, 2})
+		f.Close()
+
+		cmds, truncated := replayTestAOF(t, path)
+		if !reflect.DeepEqual(cmds, []string{"third"}) || !truncated {
+			t.Errorf("ReplayAOF = %v, truncated %v, want [third] truncated", cmds, truncated)
+		}
+
+		if cmds, truncated := replayTestAOF(t, path); len(cmds) != 1 || truncated {
+			t.Errorf("expected the torn command to be gone, got %v, truncated %v", cmds, truncated)
+		}
+	})
+
+	t.Run("should stop at a corrupted command", func(t *testing.T) {
+		data, _ := os.ReadFile(path)
+		data[len(data)-1] ^= 0xff
+		os.WriteFile(path, data, 0o600)
+
+		if cmds, truncated := replayTestAOF(t, path); len(cmds) != 0 || !truncated {
+			t.Errorf("ReplayAOF = %v, truncated %v, want nothing truncated", cmds, truncated)
+		}
+	})
+
+	t.Run("should leave the log as it is when a damaged command is followed by others", func(t *testing.T) {
+		damaged := filepath.Join(t.TempDir(), "damaged.aof")
+		aof, err := OpenAOF(damaged, FSYNC_ALWAYS)
+		if err != nil {
+			t.Fatalf("OpenAOF returned error %q", err)
+		}
+		for _, cmd := range []string{"first", "second"} {
+			aof.Append(time.Now(), []byte(cmd))
+		}
+		aof.Close()
+
+		data, _ := os.ReadFile(damaged)
+		for name, corrupt := range map[string]func([]byte){
+			"checksum": func(d []byte) { d[aofHeaderSize] ^= 0xff },
+			"length":   func(d []byte) { d[3] = 0xff },
+		} {
+			corrupted := append([]byte(nil), data...)
+			corrupt(corrupted)
+			os.WriteFile(damaged, corrupted, 0o600)
+
+			if _, _, err := ReplayAOF(damaged, 1024, func(time.Time, []byte) error { return nil }); err == nil {
+				t.Errorf("expected an error for a damaged %s", name)
+			}
+			if after, _ := os.ReadFile(damaged); !reflect.DeepEqual(after, corrupted) {
+				t.Errorf("expected the log with a damaged %s to be left as it is", name)
+			}
+		}
+	})
+
+	t.Run("should reject unknown policies", func(t *testing.T) {
+		if _, err := OpenAOF(path, "sometimes"); err == nil {
+			t.Error("expected error")
+		}
+	})
+}
@@ -0,0 +1,288 @@
+// Package persist reads and writes the files DCache uses to keep its keys across restarts.
+package persist
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	SNAPSHOT_MAGIC   = "DCSNAP"
+	SNAPSHOT_VERSION = 1
+
+	recordEntry byte = 1
+	recordEnd   byte = 0xff
+)
+
+var (
+	ErrBadSnapshot      = errors.New("not a snapshot file")
+	ErrSnapshotVersion  = errors.New("unsupported snapshot version")
+	ErrSnapshotChecksum = errors.New("snapshot checksum mismatch")
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// A key stored in a snapshot.
+type Entry struct {
+	Key   string
+	Value []byte
+	// Zero if the key doesn't expire
+	ExpiresAt time.Time
+}
+
+// Writes a snapshot, Close must be called to complete it.
+//
+// A snapshot is made of:
+//   - a header with the magic "DCSNAP", the version as a uint16 and the creation time as unix milliseconds in an int64
+//   - an entry record per key: byte 1, key length byte, key, value length uint32, value and expiration time as unix
+//     milliseconds in an int64, 0 if the key doesn't expire
+//   - an end record, byte 0xff
+//   - the CRC-32 (Castagnoli) of everything before it as a uint32
+//
+// Numbers are little endian.
+type SnapshotWriter struct {
+	out io.Writer
+	// Buffers writes to out, updating crc
+	w   *bufio.Writer
+	crc hash.Hash32
+	buf []byte
+}
+
+func NewSnapshotWriter(w io.Writer, createdAt time.Time) (*SnapshotWriter, error) {
+	sw := &SnapshotWriter{out: w, crc: crc32.New(crcTable)}
+	sw.w = bufio.NewWriter(io.MultiWriter(w, sw.crc))
+
+	header := make([]byte, 0, len(SNAPSHOT_MAGIC)+10)
+	header = append(header, SNAPSHOT_MAGIC...)
+	header = binary.LittleEndian.AppendUint16(header, SNAPSHOT_VERSION)
+	header = binary.LittleEndian.AppendUint64(header, uint64(createdAt.UnixMilli()))
+	if _, err := sw.w.Write(header); err != nil {
+		return nil, err
+	}
+
+	return sw, nil
+}
+
+func (sw *SnapshotWriter) Write(e Entry) error {
+	if len(e.Key) == 0 || len(e.Key) > 255 {
+		return fmt.Errorf("invalid key length %d", len(e.Key))
+	}
+
+	var expiresAt int64
+	if !e.ExpiresAt.IsZero() {
+		expiresAt = e.ExpiresAt.UnixMilli()
+	}
+
+	sw.buf = append(sw.buf[:0], recordEntry, byte(len(e.Key)))
+	sw.buf = append(sw.buf, e.Key...)
+	sw.buf = binary.LittleEndian.AppendUint32(sw.buf, uint32(len(e.Value)))
+	sw.buf = append(sw.buf, e.Value...)
+	sw.buf = binary.LittleEndian.AppendUint64(sw.buf, uint64(expiresAt))
+
+	_, err := sw.w.Write(sw.buf)
+	return err
+}
+
+// Writes the end record and checksum and flushes, the underlying writer is not closed.
+func (sw *SnapshotWriter) Close() error {
+	if err := sw.w.WriteByte(recordEnd); err != nil {
+		return err
+	}
+
+	if err := sw.w.Flush(); err != nil {
+		return err
+	}
+
+	_, err := sw.out.Write(binary.LittleEndian.AppendUint32(nil, sw.crc.Sum32()))
+	return err
+}
+
+// Reads a snapshot calling fn for every entry in order, fn may be nil to only verify it.
+//
+// The checksum is only known at the end, so entries passed to fn may belong to a corrupted snapshot.
+// Use LoadSnapshotFile to verify a file before reading its entries.
+func ReadSnapshot(r io.Reader, fn func(Entry) error) (createdAt time.Time, err error) {
+	crc := crc32.New(crcTable)
+	br := bufio.NewReader(r)
+	tr := io.TeeReader(br, crc)
+
+	header := make([]byte, len(SNAPSHOT_MAGIC)+10)
+	if _, err := io.ReadFull(tr, header); err != nil {
+		return createdAt, ErrBadSnapshot
+	} else if string(header[:len(SNAPSHOT_MAGIC)]) != SNAPSHOT_MAGIC {
+		return createdAt, ErrBadSnapshot
+	} else if v := binary.LittleEndian.Uint16(header[len(SNAPSHOT_MAGIC):]); v != SNAPSHOT_VERSION {
+		return createdAt, fmt.Errorf("%w %d", ErrSnapshotVersion, v)
+	}
+	createdAt = time.UnixMilli(int64(binary.LittleEndian.Uint64(header[len(SNAPSHOT_MAGIC)+2:])))
+
+	for {
+		e, end, err := readRecord(tr)
+		if err != nil {
+			return createdAt, err
+		} else if end {
+			break
+		}
+
+		if fn != nil {
+			if err := fn(e); err != nil {
+				return createdAt, err
+			}
+		}
+	}
+
+	sum := crc.Sum32()
+	var stored uint32
+	if err := binary.Read(br, binary.LittleEndian, &stored); err != nil {
+		return createdAt, fmt.Errorf("%w: %s", ErrBadSnapshot, unexpectedEOF(err))
+	} else if stored != sum {
+		return createdAt, ErrSnapshotChecksum
+	}
+
+	return createdAt, nil
+}
+
+func readRecord(r io.Reader) (e Entry, end bool, err error) {
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:1]); err != nil {
+		return e, false, fmt.Errorf("%w: %s", ErrBadSnapshot, unexpectedEOF(err))
+	}
+
+	switch head[0] {
+	case recordEnd:
+		return e, true, nil
+	case recordEntry:
+	default:
+		return e, false, fmt.Errorf("%w: unknown record %d", ErrBadSnapshot, head[0])
+	}
+
+	if _, err := io.ReadFull(r, head[1:]); err != nil {
+		return e, false, fmt.Errorf("%w: %s", ErrBadSnapshot, unexpectedEOF(err))
+	}
+
+	key := make([]byte, head[1])
+	var vLen uint32
+	if _, err := io.ReadFull(r, key); err != nil {
+		return e, false, fmt.Errorf("%w: %s", ErrBadSnapshot, unexpectedEOF(err))
+	} else if err := binary.Read(r, binary.LittleEndian, &vLen); err != nil {
+		return e, false, fmt.Errorf("%w: %s", ErrBadSnapshot, unexpectedEOF(err))
+	}
+
+	var expiresAt int64
+	value, err := readValue(r, vLen)
+	if err != nil {
+		return e, false, fmt.Errorf("%w: %s", ErrBadSnapshot, unexpectedEOF(err))
+	} else if err := binary.Read(r, binary.LittleEndian, &expiresAt); err != nil {
+		return e, false, fmt.Errorf("%w: %s", ErrBadSnapshot, unexpectedEOF(err))
+	}
+
+	e = Entry{Key: string(key), Value: value}
+	if expiresAt != 0 {
+		e.ExpiresAt = time.UnixMilli(expiresAt)
+	}
+
+	return e, false, nil
+}
+
+// Values longer than this are read as they arrive instead of allocated whole
+const valueChunk = 64 * 1024
+
+// Reads a value of n bytes. Long values grow while read, so a corrupted length fails at the end of the
+// file instead of allocating up to 4 GiB.
+func readValue(r io.Reader, n uint32) ([]byte, error) {
+	if n <= valueChunk {
+		value := make([]byte, n)
+		_, err := io.ReadFull(r, value)
+		return value, err
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(valueChunk)
+	if _, err := io.CopyN(&buf, r, int64(n)); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
+
+// Writes a snapshot to path atomically: entries are written to a temporary file that replaces path
+// once complete and synced to disk. entries must call write for every entry to include.
+func SaveSnapshotFile(path string, createdAt time.Time, entries func(write func(Entry) error) error) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	// Does nothing once the file is renamed
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	sw, err := NewSnapshotWriter(f, createdAt)
+	if err != nil {
+		return err
+	}
+
+	if err := entries(sw.Write); err != nil {
+		return err
+	}
+
+	if err := sw.Close(); err != nil {
+		return err
+	} else if err := f.Sync(); err != nil {
+		return err
+	} else if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(f.Name(), path); err != nil {
+		return err
+	}
+
+	return syncDir(filepath.Dir(path))
+}
+
+// Verifies the snapshot at path and then calls fn for every entry. Nothing is passed to fn if the file is corrupted.
+func LoadSnapshotFile(path string, fn func(Entry) error) (createdAt time.Time, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return createdAt, err
+	}
+	defer f.Close()
+
+	if _, err := ReadSnapshot(f, nil); err != nil {
+		return createdAt, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return createdAt, err
+	}
+
+	return ReadSnapshot(f, fn)
+}
+
+// Makes a rename in dir durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
@@ -0,0 +1,115 @@
+package persist
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func writeTestSnapshot(t *testing.T, entries []Entry) []byte {
+	var buf bytes.Buffer
+	sw, err := NewSnapshotWriter(&buf, time.UnixMilli(1700000000000))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, e := range entries {
+		if err := sw.Write(e); err != nil {
+			t.Fatalf("Write returned error %q", err)
+		}
+	}
+
+	if err := sw.Close(); err != nil {
+		t.Fatalf("Close returned error %q", err)
+	}
+
+	return buf.Bytes()
+}
+
+var testEntries = []Entry{
+	{Key: "Foo", Value: []byte("Bar")},
+	{Key: "Baz", Value: []byte{}, ExpiresAt: time.UnixMilli(1700000060000)},
+	{Key: "Qux", Value: bytes.Repeat([]byte{0xff}, 1000), ExpiresAt: time.UnixMilli(1700000120000)},
+	{Key: "Quux", Value: bytes.Repeat([]byte{1}, 3*valueChunk/2)},
+}
+
+func TestSnapshot(t *testing.T) {
+	t.Run("should read the written entries", func(t *testing.T) {
+		data := writeTestSnapshot(t, testEntries)
+
+		read := make([]Entry, 0)
+		createdAt, err := ReadSnapshot(bytes.NewReader(data), func(e Entry) error {
+			read = append(read, e)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("ReadSnapshot returned error %q", err)
+		}
+
+		if createdAt.UnixMilli() != 1700000000000 {
+			t.Errorf("expected creation time 1700000000000, got %d", createdAt.UnixMilli())
+		}
+
+		if !reflect.DeepEqual(read, testEntries) {
+			t.Errorf("ReadSnapshot = %v, want %v", read, testEntries)
+		}
+	})
+
+	t.Run("should detect corruption", func(t *testing.T) {
+		data := writeTestSnapshot(t, testEntries)
+
+		flipped := bytes.Clone(data)
+		flipped[len(flipped)-20] ^= 1
+		if _, err := ReadSnapshot(bytes.NewReader(flipped), nil); !errors.Is(err, ErrSnapshotChecksum) {
+			t.Errorf("ReadSnapshot with flipped byte = %v, want %v", err, ErrSnapshotChecksum)
+		}
+
+		if _, err := ReadSnapshot(bytes.NewReader(data[:len(data)-30]), nil); !errors.Is(err, ErrBadSnapshot) {
+			t.Errorf("ReadSnapshot of truncated snapshot = %v, want %v", err, ErrBadSnapshot)
+		}
+
+		// A length of 4 GiB with a few bytes after it
+		long := append(bytes.Clone(data[:len(SNAPSHOT_MAGIC)+10]), recordEntry, 3, 'F', 'o', 'o', 0xff, 0xff, 0xff, 0xff, 1, 2, 3)
+		if _, err := ReadSnapshot(bytes.NewReader(long), nil); !errors.Is(err, ErrBadSnapshot) {
+			t.Errorf("ReadSnapshot with a corrupted value length = %v, want %v", err, ErrBadSnapshot)
+		}
+
+		version := bytes.Clone(data)
+		version[len(SNAPSHOT_MAGIC)] = 2
+		if _, err := ReadSnapshot(bytes.NewReader(version), nil); !errors.Is(err, ErrSnapshotVersion) {
+			t.Errorf("ReadSnapshot of version 2 = %v, want %v", err, ErrSnapshotVersion)
+		}
+	})
+
+	t.Run("should not load entries of a corrupted file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "dcache.snap")
+		err := SaveSnapshotFile(path, time.Now(), func(write func(Entry) error) error {
+			for _, e := range testEntries {
+				if err := write(e); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("SaveSnapshotFile returned error %q", err)
+		}
+
+		data, _ := os.ReadFile(path)
+		data[len(data)-20] ^= 1
+		os.WriteFile(path, data, 0600)
+
+		loaded := 0
+		_, err = LoadSnapshotFile(path, func(Entry) error {
+			loaded++
+			return nil
+		})
+		if !errors.Is(err, ErrSnapshotChecksum) || loaded != 0 {
+			t.Errorf("LoadSnapshotFile = %v with %d entries, want %v with none", err, loaded, ErrSnapshotChecksum)
+		}
+	})
+}
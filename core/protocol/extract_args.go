@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/joaovictorsl/dcache/core"
+	"github.com/joaovictorsl/dcache/core/command"
 )
 
 // Extracts Set command args, if something is wrong throws core.INVALID_COMMAND
@@ -87,3 +88,110 @@ func extractDeleteArgs(raw []byte) (k []byte, err error) {
 
 	return raw[2 : 2+kLen], nil
 }
+
+// Extracts Scan command args, if something is wrong throws core.INVALID_COMMAND
+func extractScanArgs(raw []byte) (after []byte, count uint16, err error) {
+	rawLen := len(raw)
+	if rawLen < 2 {
+		return nil, 0, fmt.Errorf(core.INVALID_COMMAND)
+	}
+
+	aLen := int(raw[1])
+	if rawLen != 2+aLen+2 {
+		// Should have first byte, after length byte, all after bytes and two count bytes
+		return nil, 0, fmt.Errorf(core.INVALID_COMMAND)
+	}
+
+	return raw[2 : 2+aLen], binary.LittleEndian.Uint16(raw[2+aLen:]), nil
+}
+
+// Decodes the members sent by GOSSIP and its response, as encoded by command.GossipMembersAsBytes. If
+// something is wrong throws core.INVALID_COMMAND
+func ParseGossipMembers(data []byte) ([]command.GossipMember, error) {
+	if len(data) < 2 {
+		return nil, fmt.Errorf(core.INVALID_COMMAND)
+	}
+
+	count := int(binary.LittleEndian.Uint16(data))
+	data = data[2:]
+	members := make([]command.GossipMember, 0, count)
+	for i := 0; i < count; i++ {
+		if len(data) < 1 || len(data) < 1+int(data[0])+8 {
+			return nil, fmt.Errorf(core.INVALID_COMMAND)
+		}
+
+		aLen := int(data[0])
+		members = append(members, command.GossipMember{
+			Addr:      string(data[1 : 1+aLen]),
+			Heartbeat: binary.LittleEndian.Uint64(data[1+aLen:]),
+		})
+		data = data[1+aLen+8:]
+	}
+
+	if len(data) != 0 {
+		return nil, fmt.Errorf(core.INVALID_COMMAND)
+	}
+
+	return members, nil
+}
+
+// Decodes keys as encoded by command.KeysAsBytes, if something is wrong throws core.INVALID_COMMAND
+func ParseKeys(data []byte) ([]string, error) {
+	keys := make([]string, 0)
+	for len(data) != 0 {
+		kLen := int(data[0])
+		if len(data) < 1+kLen {
+			// Should have the length byte and all key bytes
+			return nil, fmt.Errorf(core.INVALID_COMMAND)
+		}
+
+		keys = append(keys, string(data[1:1+kLen]))
+		data = data[1+kLen:]
+	}
+
+	return keys, nil
+}
+
+// Extracts Client Tracking command args, if something is wrong throws core.INVALID_COMMAND
+func extractClientTrackingArgs(raw []byte) (streamID uint64, err error) {
+	if len(raw) != 1+8 {
+		// Should have first byte and eight stream id bytes
+		return 0, fmt.Errorf(core.INVALID_COMMAND)
+	}
+
+	return binary.LittleEndian.Uint64(raw[1:]), nil
+}
+
+// Checks commands that take no args, if something is wrong throws core.INVALID_COMMAND
+func extractNoArgs(raw []byte) error {
+	if len(raw) != 1 {
+		// Should have only the first byte
+		return fmt.Errorf(core.INVALID_COMMAND)
+	}
+
+	return nil
+}
+
+// Extracts Auth command args, if something is wrong throws core.INVALID_COMMAND
+func extractAuthArgs(raw []byte) (username, secret []byte, err error) {
+	rawLen := len(raw)
+	if rawLen < 3 {
+		// Should have first byte, username length byte and secret length byte
+		return nil, nil, fmt.Errorf(core.INVALID_COMMAND)
+	}
+
+	uLen := int(raw[1])
+	if rawLen < 2+uLen+1 {
+		// Should have first byte, username length byte, all username bytes and secret length byte
+		return nil, nil, fmt.Errorf(core.INVALID_COMMAND)
+	}
+
+	sLen := int(raw[2+uLen])
+	if rawLen != 2+uLen+1+sLen {
+		// Should have first byte, username length byte, all username bytes, secret length byte
+		// and all secret bytes
+		return nil, nil, fmt.Errorf(core.INVALID_COMMAND)
+	}
+
+	return raw[2 : 2+uLen], raw[3+uLen:], nil
+}
@@ -0,0 +1,61 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// Size of the length prefix that precedes every frame
+const frameHeaderSize = 4
+
+// Returned by ReadFrame when a frame is bigger than the allowed size.
+// The frame payload is discarded so the next frame can still be read.
+var ErrFrameTooLarge = errors.New("frame too large")
+
+// Returns the biggest command a server accepts given its key and value limits.
+//
+// The biggest command is a SET: type byte, key length byte, key, four value length bytes,
+// value and four ttl bytes.
+func MaxCommandSize(maxKeyLength, maxValueLength uint) uint32 {
+	return uint32(1 + 1 + maxKeyLength + 4 + maxValueLength + 4)
+}
+
+// Writes payload to w prefixed by its length as a little endian uint32.
+func WriteFrame(w io.Writer, payload []byte) error {
+	frame := make([]byte, frameHeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(frame, uint32(len(payload)))
+	copy(frame[frameHeaderSize:], payload)
+
+	_, err := w.Write(frame)
+	return err
+}
+
+// Reads a single frame from r and returns its payload.
+//
+// If maxSize is not 0 and the frame is bigger than maxSize, the payload is discarded and ErrFrameTooLarge is returned.
+func ReadFrame(r io.Reader, maxSize uint32) ([]byte, error) {
+	header := make([]byte, frameHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	size := binary.LittleEndian.Uint32(header)
+	if maxSize != 0 && size > maxSize {
+		if _, err := io.CopyN(io.Discard, r, int64(size)); err != nil {
+			return nil, err
+		}
+
+		return nil, ErrFrameTooLarge
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	return payload, nil
+}
@@ -0,0 +1,73 @@
+package protocol
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/joaovictorsl/dcache/core/command"
+)
+
+func TestFrame(t *testing.T) {
+	t.Run("should read back written frames in order", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		payloads := [][]byte{
+			command.GetCmdAsBytes("Foo"),
+			command.SetCmdAsBytes("Foo", []byte("Bar"), 5000),
+			{},
+		}
+
+		for _, p := range payloads {
+			if err := WriteFrame(buf, p); err != nil {
+				t.Fatalf("WriteFrame(%q) returned error %q", p, err)
+			}
+		}
+
+		for _, expected := range payloads {
+			actual, err := ReadFrame(buf, 0)
+			if err != nil {
+				t.Fatalf("ReadFrame returned error %q", err)
+			}
+
+			if !bytes.Equal(actual, expected) {
+				t.Errorf("ReadFrame = %q, want %q", actual, expected)
+			}
+		}
+
+		if _, err := ReadFrame(buf, 0); err != io.EOF {
+			t.Errorf("ReadFrame on empty reader = %v, want %v", err, io.EOF)
+		}
+	})
+
+	t.Run("should discard frames bigger than max size", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		big := command.SetCmdAsBytes("Foo", make([]byte, 100), 5000)
+		small := command.GetCmdAsBytes("Foo")
+		WriteFrame(buf, big)
+		WriteFrame(buf, small)
+
+		max := MaxCommandSize(3, 10)
+		if _, err := ReadFrame(buf, max); err != ErrFrameTooLarge {
+			t.Errorf("ReadFrame = %v, want %v", err, ErrFrameTooLarge)
+		}
+
+		actual, err := ReadFrame(buf, max)
+		if err != nil {
+			t.Fatalf("ReadFrame returned error %q", err)
+		}
+
+		if !bytes.Equal(actual, small) {
+			t.Errorf("ReadFrame = %q, want %q", actual, small)
+		}
+	})
+
+	t.Run("should return an error on truncated frames", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		WriteFrame(buf, command.GetCmdAsBytes("Foo"))
+		truncated := bytes.NewReader(buf.Bytes()[:buf.Len()-1])
+
+		if _, err := ReadFrame(truncated, 0); err != io.ErrUnexpectedEOF {
+			t.Errorf("ReadFrame = %v, want %v", err, io.ErrUnexpectedEOF)
+		}
+	})
+}
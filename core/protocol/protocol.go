@@ -1,6 +1,7 @@
 package protocol
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net"
 
@@ -18,6 +19,16 @@ func Connect(addr string) (net.Conn, error) {
 	return conn, nil
 }
 
+// Connects to a DCache server using TLS, the handshake is done before returning.
+func ConnectTLS(addr string, cfg *tls.Config) (net.Conn, error) {
+	conn, err := tls.Dial("tcp", addr, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return conn, nil
+}
+
 // Parses a byte array into a Command
 func ParseCommand(raw []byte) (command.Command, error) {
 	if len(raw) == 0 {
@@ -34,6 +45,13 @@ func ParseCommand(raw []byte) (command.Command, error) {
 		}
 		cmd = command.NewSetCommand(string(k), v, ttl)
 
+	case core.CMD_ADD:
+		k, v, ttl, err := extractSetArgs(raw)
+		if err != nil {
+			return nil, err
+		}
+		cmd = command.NewAddCommand(string(k), v, ttl)
+
 	case core.CMD_GET:
 		k, err := extractGetArgs(raw)
 		if err != nil {
@@ -55,6 +73,96 @@ func ParseCommand(raw []byte) (command.Command, error) {
 		}
 		cmd = command.NewDeleteCommand(string(k))
 
+	case core.CMD_CLIENT_LIST:
+		if err := extractNoArgs(raw); err != nil {
+			return nil, err
+		}
+		cmd = command.NewClientListCommand()
+
+	case core.CMD_HELLO:
+		if err := extractNoArgs(raw); err != nil {
+			return nil, err
+		}
+		cmd = command.NewHelloCommand()
+
+	case core.CMD_AUTH:
+		username, secret, err := extractAuthArgs(raw)
+		if err != nil {
+			return nil, err
+		}
+		cmd = command.NewAuthCommand(string(username), string(secret))
+
+	case core.CMD_ACL_WHOAMI:
+		if err := extractNoArgs(raw); err != nil {
+			return nil, err
+		}
+		cmd = command.NewACLWhoamiCommand()
+
+	case core.CMD_ACL_LIST:
+		if err := extractNoArgs(raw); err != nil {
+			return nil, err
+		}
+		cmd = command.NewACLListCommand()
+
+	case core.CMD_SAVE:
+		if err := extractNoArgs(raw); err != nil {
+			return nil, err
+		}
+		cmd = command.NewSaveCommand()
+
+	case core.CMD_SYNC:
+		if err := extractNoArgs(raw); err != nil {
+			return nil, err
+		}
+		cmd = command.NewSyncCommand()
+
+	case core.CMD_STATS:
+		if err := extractNoArgs(raw); err != nil {
+			return nil, err
+		}
+		cmd = command.NewStatsCommand()
+
+	case core.CMD_SCAN:
+		after, count, err := extractScanArgs(raw)
+		if err != nil {
+			return nil, err
+		}
+		cmd = command.NewScanCommand(string(after), count)
+
+	case core.CMD_DUMP:
+		k, err := extractGetArgs(raw)
+		if err != nil {
+			return nil, err
+		}
+		cmd = command.NewDumpCommand(string(k))
+
+	case core.CMD_GOSSIP:
+		members, err := ParseGossipMembers(raw[1:])
+		if err != nil {
+			return nil, err
+		}
+		cmd = command.NewGossipCommand(members)
+
+	case core.CMD_CLUSTER_NODES:
+		if err := extractNoArgs(raw); err != nil {
+			return nil, err
+		}
+		cmd = command.NewClusterNodesCommand()
+
+	case core.CMD_TRACKING:
+		prefixes, err := ParseKeys(raw[1:])
+		if err != nil {
+			return nil, err
+		}
+		cmd = command.NewTrackingCommand(prefixes)
+
+	case core.CMD_CLIENT_TRACKING:
+		streamID, err := extractClientTrackingArgs(raw)
+		if err != nil {
+			return nil, err
+		}
+		cmd = command.NewClientTrackingCommand(streamID)
+
 	default:
 		return nil, fmt.Errorf(core.INVALID_COMMAND)
 	}
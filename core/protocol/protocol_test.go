@@ -140,6 +140,26 @@ func TestParseCommandSet(t *testing.T) {
 	})
 }
 
+func TestParseCommandAdd(t *testing.T) {
+	t.Run("should return an add command with ttl 5000ms", func(t *testing.T) {
+		cmd := command.AddCmdAsBytes("Foo", []byte("Bar"), 5000)
+		actual, err := ParseCommand(cmd)
+		if err != nil {
+			t.Errorf("parseCommand(%q) returned error %q", cmd, err)
+		} else if add, ok := actual.(*command.AddCommand); !ok || add.Key != "Foo" || string(add.Value) != "Bar" || add.TTL != 5000*time.Millisecond {
+			t.Errorf("parseCommand(%q) = %v, want %v", cmd, actual, command.NewAddCommand("Foo", []byte("Bar"), 5000))
+		}
+	})
+
+	t.Run("should return an error if lengths don't match", func(t *testing.T) {
+		cmd := command.AddCmdAsBytes("Foo", []byte("Bar"), 5000)
+		cmd[1] = 4
+		if _, err := ParseCommand(cmd); err == nil || err.Error() != core.INVALID_COMMAND {
+			t.Errorf("parseCommand(%q) = %v, want %q", cmd, err, core.INVALID_COMMAND)
+		}
+	})
+}
+
 func TestParseCommandDelete(t *testing.T) {
 	t.Run("should return a delete command for key Foo", func(t *testing.T) {
 		// "DELETE Foo"
@@ -417,3 +437,224 @@ func TestParseCommandHas(t *testing.T) {
 		}
 	})
 }
+
+func TestParseCommandClientList(t *testing.T) {
+	t.Run("should return a client list command", func(t *testing.T) {
+		cmd := command.ClientListCmdAsBytes()
+		actual, err := ParseCommand(cmd)
+		if err != nil {
+			t.Errorf("parseCommand(%q) returned error %q", cmd, err)
+		}
+
+		if _, ok := actual.(*command.ClientListCommand); !ok {
+			t.Errorf("parseCommand(%q) = %v, want %v", cmd, actual, &command.ClientListCommand{})
+		}
+	})
+
+	t.Run("should return an error if command has args", func(t *testing.T) {
+		cmdWithArgs := []byte{core.CMD_CLIENT_LIST, 1}
+		_, err := ParseCommand(cmdWithArgs)
+		if err == nil || err.Error() != core.INVALID_COMMAND {
+			t.Errorf("parseCommand(%q) = %v, want %q", cmdWithArgs, err, core.INVALID_COMMAND)
+		}
+	})
+}
+
+func TestParseCommandAuth(t *testing.T) {
+	t.Run("should return an auth command", func(t *testing.T) {
+		cmd := command.AuthCmdAsBytes("svc", "s3cret")
+		actual, err := ParseCommand(cmd)
+		if err != nil {
+			t.Errorf("parseCommand(%q) returned error %q", cmd, err)
+		}
+
+		auth, ok := actual.(*command.AuthCommand)
+		if !ok || auth.Username != "svc" || auth.Secret != "s3cret" {
+			t.Errorf("parseCommand(%q) = %v, want %v", cmd, actual, command.NewAuthCommand("svc", "s3cret"))
+		}
+	})
+
+	t.Run("should accept an empty username", func(t *testing.T) {
+		cmd := command.AuthCmdAsBytes("", "s3cret")
+		actual, err := ParseCommand(cmd)
+		if err != nil {
+			t.Errorf("parseCommand(%q) returned error %q", cmd, err)
+		}
+
+		if auth, ok := actual.(*command.AuthCommand); !ok || auth.Username != "" || auth.Secret != "s3cret" {
+			t.Errorf("parseCommand(%q) = %v, want default user", cmd, actual)
+		}
+	})
+
+	t.Run("should return an error if lengths don't match", func(t *testing.T) {
+		cmdSecretOver := command.AuthCmdAsBytes("svc", "s3cret")
+		cmdSecretOver[5] = 7
+		cmdUserOver := command.AuthCmdAsBytes("svc", "s3cret")
+		cmdUserOver[1] = 20
+
+		for _, cmd := range [][]byte{cmdSecretOver, cmdUserOver, {core.CMD_AUTH, 0}} {
+			_, err := ParseCommand(cmd)
+			if err == nil || err.Error() != core.INVALID_COMMAND {
+				t.Errorf("parseCommand(%q) = %v, want %q", cmd, err, core.INVALID_COMMAND)
+			}
+		}
+	})
+}
+
+func TestParseCommandACL(t *testing.T) {
+	t.Run("should return acl commands", func(t *testing.T) {
+		if actual, err := ParseCommand(command.ACLWhoamiCmdAsBytes()); err != nil {
+			t.Errorf("parseCommand(ACL WHOAMI) returned error %q", err)
+		} else if _, ok := actual.(*command.ACLWhoamiCommand); !ok {
+			t.Errorf("parseCommand(ACL WHOAMI) = %v, want %v", actual, &command.ACLWhoamiCommand{})
+		}
+
+		if actual, err := ParseCommand(command.SaveCmdAsBytes()); err != nil {
+			t.Errorf("parseCommand(SAVE) returned error %q", err)
+		} else if _, ok := actual.(*command.SaveCommand); !ok {
+			t.Errorf("parseCommand(SAVE) = %v, want %v", actual, &command.SaveCommand{})
+		}
+
+		if actual, err := ParseCommand(command.ACLListCmdAsBytes()); err != nil {
+			t.Errorf("parseCommand(ACL LIST) returned error %q", err)
+		} else if _, ok := actual.(*command.ACLListCommand); !ok {
+			t.Errorf("parseCommand(ACL LIST) = %v, want %v", actual, &command.ACLListCommand{})
+		}
+
+		if actual, err := ParseCommand(command.SyncCmdAsBytes()); err != nil {
+			t.Errorf("parseCommand(SYNC) returned error %q", err)
+		} else if _, ok := actual.(*command.SyncCommand); !ok {
+			t.Errorf("parseCommand(SYNC) = %v, want %v", actual, &command.SyncCommand{})
+		}
+
+		if actual, err := ParseCommand(command.StatsCmdAsBytes()); err != nil {
+			t.Errorf("parseCommand(STATS) returned error %q", err)
+		} else if _, ok := actual.(*command.StatsCommand); !ok {
+			t.Errorf("parseCommand(STATS) = %v, want %v", actual, &command.StatsCommand{})
+		}
+	})
+
+	t.Run("should return an error if command has args", func(t *testing.T) {
+		for _, cmd := range [][]byte{{core.CMD_ACL_WHOAMI, 1}, {core.CMD_ACL_LIST, 1}, {core.CMD_SYNC, 1}, {core.CMD_STATS, 1}} {
+			_, err := ParseCommand(cmd)
+			if err == nil || err.Error() != core.INVALID_COMMAND {
+				t.Errorf("parseCommand(%q) = %v, want %q", cmd, err, core.INVALID_COMMAND)
+			}
+		}
+	})
+}
+
+func TestParseCommandScan(t *testing.T) {
+	t.Run("should return scan and dump commands", func(t *testing.T) {
+		for _, after := range []string{"", "foo"} {
+			cmd := command.ScanCmdAsBytes(after, 100)
+			actual, err := ParseCommand(cmd)
+			if err != nil {
+				t.Errorf("parseCommand(%q) returned error %q", cmd, err)
+			} else if scan, ok := actual.(*command.ScanCommand); !ok || scan.After != after || scan.Count != 100 {
+				t.Errorf("parseCommand(%q) = %v, want %v", cmd, actual, command.NewScanCommand(after, 100))
+			}
+		}
+
+		cmd := command.DumpCmdAsBytes("foo")
+		actual, err := ParseCommand(cmd)
+		if err != nil {
+			t.Errorf("parseCommand(%q) returned error %q", cmd, err)
+		} else if dump, ok := actual.(*command.DumpCommand); !ok || dump.Key != "foo" {
+			t.Errorf("parseCommand(%q) = %v, want %v", cmd, actual, command.NewDumpCommand("foo"))
+		}
+	})
+
+	t.Run("should return an error if lengths don't match", func(t *testing.T) {
+		scanAfterOver := command.ScanCmdAsBytes("foo", 100)
+		scanAfterOver[1] = 4
+		dumpKeyOver := command.DumpCmdAsBytes("foo")
+		dumpKeyOver[1] = 4
+
+		for _, cmd := range [][]byte{scanAfterOver, dumpKeyOver, {core.CMD_SCAN, 0, 1}, {core.CMD_DUMP, 0}} {
+			_, err := ParseCommand(cmd)
+			if err == nil || err.Error() != core.INVALID_COMMAND {
+				t.Errorf("parseCommand(%q) = %v, want %q", cmd, err, core.INVALID_COMMAND)
+			}
+		}
+	})
+}
+
+func TestParseCommandGossip(t *testing.T) {
+	t.Run("should return gossip and cluster nodes commands", func(t *testing.T) {
+		members := []command.GossipMember{{Addr: "10.0.0.1:3000", Heartbeat: 7}, {Addr: "10.0.0.2:3000", Heartbeat: 1 << 40}}
+		cmd := command.GossipCmdAsBytes(members)
+		actual, err := ParseCommand(cmd)
+		if err != nil {
+			t.Errorf("parseCommand(%q) returned error %q", cmd, err)
+		} else if gossip, ok := actual.(*command.GossipCommand); !ok || len(gossip.Members) != 2 || gossip.Members[0] != members[0] || gossip.Members[1] != members[1] {
+			t.Errorf("parseCommand(%q) = %v, want %v", cmd, actual, command.NewGossipCommand(members))
+		}
+
+		if actual, err := ParseCommand(command.GossipCmdAsBytes(nil)); err != nil {
+			t.Errorf("parseCommand(GOSSIP) returned error %q", err)
+		} else if gossip, ok := actual.(*command.GossipCommand); !ok || len(gossip.Members) != 0 {
+			t.Errorf("parseCommand(GOSSIP) = %v, want no members", actual)
+		}
+
+		if actual, err := ParseCommand(command.ClusterNodesCmdAsBytes()); err != nil {
+			t.Errorf("parseCommand(CLUSTER NODES) returned error %q", err)
+		} else if _, ok := actual.(*command.ClusterNodesCommand); !ok {
+			t.Errorf("parseCommand(CLUSTER NODES) = %v, want %v", actual, &command.ClusterNodesCommand{})
+		}
+	})
+
+	t.Run("should return an error if lengths don't match", func(t *testing.T) {
+		members := []command.GossipMember{{Addr: "10.0.0.1:3000", Heartbeat: 7}}
+		countOver := command.GossipCmdAsBytes(members)
+		countOver[1] = 2
+		addrOver := command.GossipCmdAsBytes(members)
+		addrOver[3] = 30
+		trailing := append(command.GossipCmdAsBytes(members), 0)
+
+		for _, cmd := range [][]byte{countOver, addrOver, trailing, {core.CMD_GOSSIP, 0}, {core.CMD_CLUSTER_NODES, 1}} {
+			_, err := ParseCommand(cmd)
+			if err == nil || err.Error() != core.INVALID_COMMAND {
+				t.Errorf("parseCommand(%q) = %v, want %q", cmd, err, core.INVALID_COMMAND)
+			}
+		}
+	})
+}
+
+func TestParseCommandTracking(t *testing.T) {
+	t.Run("should return tracking commands", func(t *testing.T) {
+		if actual, err := ParseCommand(command.TrackingCmdAsBytes()); err != nil {
+			t.Errorf("parseCommand(TRACKING) returned error %q", err)
+		} else if tracking, ok := actual.(*command.TrackingCommand); !ok || len(tracking.Prefixes) != 0 {
+			t.Errorf("parseCommand(TRACKING) = %v, want no prefixes", actual)
+		}
+
+		cmd := command.TrackingCmdAsBytes("orders:", "carts:")
+		if actual, err := ParseCommand(cmd); err != nil {
+			t.Errorf("parseCommand(%q) returned error %q", cmd, err)
+		} else if tracking, ok := actual.(*command.TrackingCommand); !ok || len(tracking.Prefixes) != 2 ||
+			tracking.Prefixes[0] != "orders:" || tracking.Prefixes[1] != "carts:" {
+			t.Errorf("parseCommand(%q) = %v, want %v", cmd, actual, command.NewTrackingCommand([]string{"orders:", "carts:"}))
+		}
+
+		cmd = command.ClientTrackingCmdAsBytes(42)
+		if actual, err := ParseCommand(cmd); err != nil {
+			t.Errorf("parseCommand(%q) returned error %q", cmd, err)
+		} else if tracking, ok := actual.(*command.ClientTrackingCommand); !ok || tracking.StreamID != 42 {
+			t.Errorf("parseCommand(%q) = %v, want %v", cmd, actual, command.NewClientTrackingCommand(42))
+		}
+	})
+
+	t.Run("should return an error if lengths don't match", func(t *testing.T) {
+		prefixOver := command.TrackingCmdAsBytes("orders:")
+		prefixOver[1] = 8
+		idShort := command.ClientTrackingCmdAsBytes(42)[:8]
+
+		for _, cmd := range [][]byte{prefixOver, idShort, {core.CMD_CLIENT_TRACKING}} {
+			_, err := ParseCommand(cmd)
+			if err == nil || err.Error() != core.INVALID_COMMAND {
+				t.Errorf("parseCommand(%q) = %v, want %q", cmd, err, core.INVALID_COMMAND)
+			}
+		}
+	})
+}
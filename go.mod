@@ -5,14 +5,24 @@ go 1.20
 require golang.org/x/exp v0.0.0-20240222234643-814bf88cf225
 
 require (
+	github.com/BurntSushi/toml v1.3.2
+	github.com/cespare/xxhash/v2 v2.2.0
 	github.com/joaovictorsl/fooche v0.0.0-20240323045813-ad9ffc9aebe6
+	github.com/peterh/liner v1.2.2
+	github.com/spaolacci/murmur3 v1.1.0
 	github.com/stretchr/testify v1.8.4
+	github.com/vmihailenco/msgpack/v5 v5.4.1
 	github.com/zeromicro/go-zero v1.6.2
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/joaovictorsl/gollections v0.0.0-20240225183410-42aed52553f8 // indirect
+	github.com/mattn/go-runewidth v0.0.15 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
+	github.com/rivo/uniseg v0.2.0 // indirect
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+	golang.org/x/sys v0.16.0 // indirect
 )
+
@@ -0,0 +1,38 @@
+package dcache
+
+import "strings"
+
+// Log levels, messages below the configured level are dropped.
+const (
+	LOG_DEBUG = "debug"
+	LOG_INFO  = "info"
+	LOG_ERROR = "error"
+	LOG_NONE  = "none"
+)
+
+var logLevels = map[string]int{
+	LOG_DEBUG: 0,
+	LOG_INFO:  1,
+	LOG_ERROR: 2,
+	LOG_NONE:  3,
+}
+
+func (s *Server) logf(level, format string, v ...any) {
+	if logLevels[level] < logLevels[s.config().LogLevel] {
+		return
+	}
+
+	s.logger.Printf(strings.ToUpper(level)+" "+format, v...)
+}
+
+func (s *Server) debugf(format string, v ...any) {
+	s.logf(LOG_DEBUG, format, v...)
+}
+
+func (s *Server) infof(format string, v ...any) {
+	s.logf(LOG_INFO, format, v...)
+}
+
+func (s *Server) errorf(format string, v ...any) {
+	s.logf(LOG_ERROR, format, v...)
+}
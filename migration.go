@@ -0,0 +1,46 @@
+package dcache
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+
+	"github.com/joaovictorsl/dcache/core"
+	"github.com/joaovictorsl/dcache/core/command"
+)
+
+// Most keys answered by a single SCAN
+const maxScanCount = 1000
+
+// Answers SCAN with the page of keys following c.After, each one prefixed by its length. An empty page means
+// there are no more keys.
+func (s *Server) scanCommand(c *command.ScanCommand) []byte {
+	count := int(c.Count)
+	if count == 0 || count > maxScanCount {
+		count = maxScanCount
+	}
+
+	return append([]byte{core.CMD_EXEC_SUCCEEDED}, command.KeysAsBytes(s.keys.Keys(c.After, count))...)
+}
+
+// Answers DUMP with the remaining TTL of the key in milliseconds, 0 if it doesn't expire, followed by its value.
+func (s *Server) dumpCommand(c *command.DumpCommand) []byte {
+	value, expiresAt, ok := s.keys.Lookup(c.Key)
+	if !ok {
+		return []byte{core.CMD_EXEC_FAILED}
+	}
+
+	var ttl int64
+	if !expiresAt.IsZero() {
+		if ttl = time.Until(expiresAt).Milliseconds(); ttl <= 0 {
+			return []byte{core.CMD_EXEC_FAILED}
+		} else if ttl > math.MaxUint32 {
+			ttl = math.MaxUint32
+		}
+	}
+
+	res := make([]byte, 5, 5+len(value))
+	res[0] = core.CMD_EXEC_SUCCEEDED
+	binary.LittleEndian.PutUint32(res[1:], uint32(ttl))
+	return append(res, value...)
+}
@@ -0,0 +1,221 @@
+package dcache
+
+import (
+	"crypto/tls"
+	"log"
+	"time"
+
+	"github.com/joaovictorsl/fooche"
+)
+
+// Option configures a Server created by NewServerWithOptions.
+type Option func(*Server)
+
+// Replaces the whole configuration, options given before it are overwritten.
+func WithConfig(cfg Config) Option {
+	return func(s *Server) {
+		s.cfg = cfg
+	}
+}
+
+// Sets the address the server binds to, empty means all interfaces.
+func WithBindAddr(addr string) Option {
+	return func(s *Server) {
+		s.cfg.BindAddr = addr
+	}
+}
+
+// Sets the port the server listens on, 0 picks a free one, see Server.Addr.
+func WithPort(port uint16) Option {
+	return func(s *Server) {
+		s.cfg.Port = port
+	}
+}
+
+// Sets the maximum key length in bytes, it can't be bigger than 255.
+func WithMaxKeyLength(n uint) Option {
+	return func(s *Server) {
+		s.cfg.MaxKeyLength = n
+	}
+}
+
+// Sets the maximum value length in bytes.
+func WithMaxValueLength(n uint) Option {
+	return func(s *Server) {
+		s.cfg.MaxValueLength = n
+	}
+}
+
+// Sets the maximum amount of simultaneous connections, 0 means unlimited.
+func WithMaxConns(n uint) Option {
+	return func(s *Server) {
+		s.cfg.MaxConns = n
+	}
+}
+
+// Sets the maximum amount of simultaneous connections from a single host, 0 means unlimited.
+func WithMaxConnsPerClient(n uint) Option {
+	return func(s *Server) {
+		s.cfg.MaxConnsPerClient = n
+	}
+}
+
+// Sets how long a connection may wait for a command before being closed, 0 means no timeout.
+func WithReadTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.cfg.ReadTimeout = d
+	}
+}
+
+// Sets how long writing a response may take before the connection is closed, 0 means no timeout.
+func WithWriteTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.cfg.WriteTimeout = d
+	}
+}
+
+// Saves the cache to path every interval and on shutdown, and loads it on start. An interval of 0
+// saves only on shutdown and SAVE.
+func WithSnapshotFile(path string, interval time.Duration) Option {
+	return func(s *Server) {
+		s.cfg.SnapshotFile = path
+		s.cfg.SnapshotInterval = interval
+	}
+}
+
+// Appends every command changing the cache to path and replays it on start, syncing it to disk as told by
+// fsync, one of persist.FSYNC_ALWAYS, persist.FSYNC_EVERYSEC or persist.FSYNC_NEVER. Requires WithSnapshotFile.
+func WithAOFFile(path, fsync string) Option {
+	return func(s *Server) {
+		s.cfg.AOFFile = path
+		s.cfg.AOFFsync = fsync
+	}
+}
+
+// Sets the size in bytes the append-only log may reach before being compacted into a snapshot.
+func WithAOFCompactSize(size uint64) Option {
+	return func(s *Server) {
+		s.cfg.AOFCompactSize = size
+	}
+}
+
+// Makes the server a read only replica of the leader at addr.
+func WithReplicaOf(addr string) Option {
+	return func(s *Server) {
+		s.cfg.ReplicaOf = addr
+	}
+}
+
+// Sets the credentials a follower authenticates to its leader with.
+func WithReplicaAuth(username, secret string) Option {
+	return func(s *Server) {
+		s.cfg.ReplicaUser = username
+		s.cfg.ReplicaPassword = secret
+	}
+}
+
+// Makes a follower connect to its leader with TLS, verifying the leader's certificate against the CAs in caFile.
+func WithReplicaTLS(caFile string) Option {
+	return func(s *Server) {
+		s.cfg.ReplicaTLSCAFile = caFile
+	}
+}
+
+// Makes the server a cluster member reachable at addr, joining the cluster through seeds.
+func WithCluster(addr string, seeds ...string) Option {
+	return func(s *Server) {
+		s.cfg.ClusterAddr = addr
+		s.cfg.ClusterSeeds = seeds
+	}
+}
+
+// Sets the credentials the server authenticates to other cluster members with.
+func WithClusterAuth(username, secret string) Option {
+	return func(s *Server) {
+		s.cfg.ClusterUser = username
+		s.cfg.ClusterPassword = secret
+	}
+}
+
+// Sets the interval in which cluster members gossip.
+func WithGossipInterval(interval time.Duration) Option {
+	return func(s *Server) {
+		s.cfg.ClusterGossipInterval = interval
+	}
+}
+
+// Makes the server answer commands on keys it doesn't own with MOVED, replicas is the amount of members
+// owning each key, as given to clients.
+func WithClusterRedirect(replicas uint) Option {
+	return func(s *Server) {
+		s.cfg.ClusterRedirect = true
+		s.cfg.ClusterReplicas = replicas
+	}
+}
+
+// Sets how many keys read by connections tracking their reads are remembered, 0 disables TRACKING.
+func WithTrackingMaxKeys(n uint) Option {
+	return func(s *Server) {
+		s.cfg.TrackingMaxKeys = n
+	}
+}
+
+// Enables TLS using the certificate and key files. If clientCAFile is not empty, clients must present a
+// certificate signed by one of its CAs.
+func WithTLSFiles(certFile, keyFile, clientCAFile string) Option {
+	return func(s *Server) {
+		s.cfg.TLSCertFile = certFile
+		s.cfg.TLSKeyFile = keyFile
+		s.cfg.TLSClientCAFile = clientCAFile
+	}
+}
+
+// Enables TLS using cfg, the TLS files in the configuration are ignored and not reloaded.
+//
+// Use cfg.GetCertificate to rotate certificates without restarting the server.
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(s *Server) {
+		s.tlsConfig = cfg
+	}
+}
+
+// Requires connections to authenticate with password, or one of the tokens set by WithAuthTokens.
+func WithAuthPassword(password string) Option {
+	return func(s *Server) {
+		s.cfg.AuthPassword = password
+	}
+}
+
+// Requires connections to authenticate with one of tokens, or the password set by WithAuthPassword.
+func WithAuthTokens(tokens ...string) Option {
+	return func(s *Server) {
+		s.cfg.AuthTokens = tokens
+	}
+}
+
+// Loads named users and their permissions from the ACL file at path, see the README for its format.
+func WithACLFile(path string) Option {
+	return func(s *Server) {
+		s.cfg.ACLFile = path
+	}
+}
+
+// Sets the minimum level of logged messages: LOG_DEBUG, LOG_INFO, LOG_ERROR or LOG_NONE.
+func WithLogLevel(level string) Option {
+	return func(s *Server) {
+		s.cfg.LogLevel = level
+	}
+}
+
+func WithLogger(l *log.Logger) Option {
+	return func(s *Server) {
+		s.logger = l
+	}
+}
+
+// Sets the cache backend, the cache settings in the configuration are ignored.
+func WithCache(c fooche.ICache) Option {
+	return func(s *Server) {
+		s.cache = c
+	}
+}
@@ -0,0 +1,68 @@
+package dcache
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/joaovictorsl/dcache/core"
+	"github.com/joaovictorsl/dcache/core/command"
+	"github.com/joaovictorsl/dcache/core/protocol"
+)
+
+// A connection to another server, made by followers to their leader and by cluster members to each other.
+type peerConn struct {
+	net.Conn
+	r *bufio.Reader
+	// Biggest frame read, peers send at most the biggest command this server accepts
+	maxSize uint32
+}
+
+// Connects to the server at addr, with TLS when ReplicaTLSCAFile is set, and authenticates with user and
+// password unless both are empty.
+func dialPeer(cfg Config, addr, user, password string, timeout time.Duration) (*peerConn, error) {
+	tlsConfig, err := replicaTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	if tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	pc := &peerConn{Conn: conn, r: bufio.NewReader(conn), maxSize: protocol.MaxCommandSize(cfg.MaxKeyLength, cfg.MaxValueLength)}
+	if user != "" || password != "" {
+		if _, err := pc.exec("AUTH", command.AuthCmdAsBytes(user, password), timeout); err != nil {
+			pc.Close()
+			return nil, err
+		}
+	}
+
+	return pc, nil
+}
+
+// Sends cmd and returns the data of its response, failing if the command didn't succeed.
+func (pc *peerConn) exec(name string, cmd []byte, timeout time.Duration) ([]byte, error) {
+	pc.SetDeadline(time.Now().Add(timeout))
+	if err := protocol.WriteFrame(pc, cmd); err != nil {
+		return nil, err
+	}
+
+	res, err := protocol.ReadFrame(pc.r, pc.maxSize)
+	if err != nil {
+		return nil, err
+	} else if len(res) == 0 || res[0] != core.CMD_EXEC_SUCCEEDED {
+		return nil, fmt.Errorf("%s refused %s: %q", pc.RemoteAddr(), name, res)
+	}
+
+	return res[1:], nil
+}
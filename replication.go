@@ -0,0 +1,378 @@
+package dcache
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/joaovictorsl/dcache/core"
+	"github.com/joaovictorsl/dcache/core/command"
+	"github.com/joaovictorsl/dcache/core/protocol"
+)
+
+// Roles a server can have.
+const (
+	ROLE_LEADER   = "leader"
+	ROLE_FOLLOWER = "follower"
+)
+
+// Status of a follower's link to its leader.
+const (
+	LINK_CONNECTING = "connecting"
+	// Receiving every key from the leader
+	LINK_SYNCING = "syncing"
+	// Applying the leader's commands as they run
+	LINK_UP   = "up"
+	LINK_DOWN = "down"
+)
+
+// Types of the records a leader sends to its followers after SYNC
+const (
+	replCommand byte = iota
+	replSynced
+	replHeartbeat
+)
+
+// Size of a record header: type and time
+const replHeaderSize = 1 + 8
+
+const (
+	// How often a leader sends a heartbeat to its followers
+	replHeartbeatInterval = time.Second
+	// How long a follower waits for a record before considering the link down
+	replTimeout = 3 * replHeartbeatInterval
+	// How long a follower waits before connecting again after the link goes down
+	replRetryInterval = time.Second
+	// Records buffered per follower, a follower falling further behind is disconnected and syncs again
+	replBacklog = 64 * 1024
+)
+
+var errInvalidReplRecord = errors.New("invalid replication record")
+
+func replRecord(recordType byte, t time.Time, cmd []byte) []byte {
+	record := make([]byte, replHeaderSize, replHeaderSize+len(cmd))
+	record[0] = recordType
+	binary.LittleEndian.PutUint64(record[1:replHeaderSize], uint64(t.UnixMilli()))
+	return append(record, cmd...)
+}
+
+// A follower connected to this server.
+type follower struct {
+	cc      *clientConn
+	records chan []byte
+	// Closed once the follower is disconnected
+	gone     chan struct{}
+	goneOnce sync.Once
+	// Whether every key was sent and it's receiving commands as they run
+	synced atomic.Bool
+}
+
+func (f *follower) disconnect() {
+	f.goneOnce.Do(func() {
+		close(f.gone)
+		f.cc.Close()
+	})
+}
+
+// Queues rawCmd, which ran at t, to every follower. Called by write while holding writeMu.
+func (s *Server) replicate(t time.Time, rawCmd []byte) {
+	if len(s.followers) == 0 {
+		return
+	}
+
+	record := replRecord(replCommand, t, rawCmd)
+	for f := range s.followers {
+		select {
+		case f.records <- record:
+		default:
+			s.errorf("follower %s is %d commands behind, disconnecting it\n", f.cc.RemoteAddr(), replBacklog)
+			delete(s.followers, f)
+			f.disconnect()
+		}
+	}
+}
+
+// Serves a follower that sent SYNC until it disconnects or the server shuts down. Every key is sent,
+// followed by the commands that changed the cache since the follower connected.
+//
+// Commands running while keys are sent may reach the follower twice, once through the key and again as
+// a command, which leaves it in the same state.
+func (s *Server) serveFollower(cc *clientConn) {
+	f := &follower{cc: cc, records: make(chan []byte, replBacklog), gone: make(chan struct{})}
+
+	s.writeMu.Lock()
+	s.followers[f] = true
+	s.writeMu.Unlock()
+	defer func() {
+		s.writeMu.Lock()
+		delete(s.followers, f)
+		s.writeMu.Unlock()
+		f.disconnect()
+	}()
+
+	// Followers send nothing after SYNC, reading only tells when they disconnect or the server shuts down
+	if !s.prepareRead(cc, 0) {
+		return
+	}
+	go func() {
+		io.Copy(io.Discard, cc)
+		f.disconnect()
+	}()
+
+	s.infof("follower %s connected, syncing\n", cc.RemoteAddr())
+	w := bufio.NewWriter(cc)
+	err := s.syncFollower(w)
+	if err == nil {
+		f.synced.Store(true)
+		s.infof("follower %s synced\n", cc.RemoteAddr())
+		err = s.streamToFollower(f, w)
+	}
+
+	select {
+	case <-f.gone:
+		s.infof("follower %s disconnected\n", cc.RemoteAddr())
+	default:
+		s.errorf("replication to follower %s failed: %s\n", cc.RemoteAddr(), err)
+	}
+}
+
+// Answers SYNC and sends every key as a SET, followed by a record telling the follower it has them all.
+func (s *Server) syncFollower(w *bufio.Writer) (err error) {
+	if err := protocol.WriteFrame(w, []byte{core.CMD_EXEC_SUCCEEDED}); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	s.keys.Range(func(key string, value []byte, expiresAt time.Time) bool {
+		var ttl int64
+		if !expiresAt.IsZero() {
+			if ttl = expiresAt.Sub(now).Milliseconds(); ttl <= 0 {
+				return true
+			} else if ttl > math.MaxUint32 {
+				ttl = math.MaxUint32
+			}
+		}
+
+		err = protocol.WriteFrame(w, replRecord(replCommand, now, command.SetCmdAsBytes(key, value, uint32(ttl))))
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := protocol.WriteFrame(w, replRecord(replSynced, time.Now(), nil)); err != nil {
+		return err
+	}
+
+	return w.Flush()
+}
+
+// Sends the follower's queued commands as they come, and a heartbeat every replHeartbeatInterval.
+func (s *Server) streamToFollower(f *follower, w *bufio.Writer) error {
+	heartbeat := time.NewTicker(replHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		var err error
+		select {
+		case record := <-f.records:
+			err = protocol.WriteFrame(w, record)
+			// Flushes once caught up, so records are batched while commands keep coming
+			if err == nil && len(f.records) == 0 {
+				err = w.Flush()
+			}
+		case <-heartbeat.C:
+			if err = protocol.WriteFrame(w, replRecord(replHeartbeat, time.Now(), nil)); err == nil {
+				err = w.Flush()
+			}
+		case <-f.gone:
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// State of the link to the leader, kept by replicaLoop.
+type replica struct {
+	leader string
+	// Closed to stop following the leader
+	stop chan struct{}
+	// Closed once replicaLoop returns
+	stopped chan struct{}
+
+	mu     sync.Mutex
+	status string
+	// Time between the leader sending the last record and the follower receiving it
+	lag    time.Duration
+	lastIO time.Time
+}
+
+func (r *replica) setStatus(status string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.status = status
+}
+
+// Records a record sent by the leader at t.
+func (r *replica) received(t time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastIO = time.Now()
+	// Negative when the clocks disagree
+	if r.lag = r.lastIO.Sub(t); r.lag < 0 {
+		r.lag = 0
+	}
+}
+
+// Stops following the current leader, if any, and starts following leader unless it's empty.
+func (s *Server) follow(leader string) {
+	s.mu.Lock()
+	old := s.replica
+	s.replica = nil
+	s.mu.Unlock()
+
+	if old != nil {
+		close(old.stop)
+		<-old.stopped
+		s.infof("stopped following %s\n", old.leader)
+	}
+
+	if leader == "" {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Started by Start otherwise
+	if s.closing || s.keys == nil {
+		return
+	}
+
+	r := &replica{leader: leader, stop: make(chan struct{}), stopped: make(chan struct{}), status: LINK_CONNECTING}
+	s.replica = r
+	s.background.Add(1)
+	go s.replicaLoop(r)
+}
+
+// Follows the leader until told to stop or the server shuts down, connecting again whenever the link goes down.
+func (s *Server) replicaLoop(r *replica) {
+	defer s.background.Done()
+	defer close(r.stopped)
+
+	s.infof("following %s\n", r.leader)
+	for {
+		err := s.followLeader(r)
+		r.setStatus(LINK_DOWN)
+
+		select {
+		case <-r.stop:
+			return
+		case <-s.done:
+			return
+		default:
+		}
+
+		s.errorf("replication link to %s down: %s\n", r.leader, err)
+		select {
+		case <-r.stop:
+			return
+		case <-s.done:
+			return
+		case <-time.After(replRetryInterval):
+		}
+	}
+}
+
+// Connects to the leader, syncs every key and applies its commands until the link goes down.
+func (s *Server) followLeader(r *replica) error {
+	r.setStatus(LINK_CONNECTING)
+	cfg := s.config()
+	conn, err := dialPeer(cfg, r.leader, cfg.ReplicaUser, cfg.ReplicaPassword, replTimeout)
+	if err != nil {
+		return err
+	}
+
+	closed := make(chan struct{})
+	defer close(closed)
+	go func() {
+		select {
+		case <-r.stop:
+		case <-s.done:
+		case <-closed:
+		}
+		conn.Close()
+	}()
+
+	if _, err := conn.exec("SYNC", command.SyncCmdAsBytes(), replTimeout); err != nil {
+		return err
+	}
+	conn.SetWriteDeadline(time.Time{})
+
+	r.setStatus(LINK_SYNCING)
+	s.infof("syncing with leader %s\n", r.leader)
+	expires := cfg.CleanInterval != 0
+	// Keys sent by the leader during the sync, the rest are removed once it ends
+	synced := make(map[string]bool)
+	for {
+		conn.SetReadDeadline(time.Now().Add(replTimeout))
+		record, err := protocol.ReadFrame(conn.r, replHeaderSize+conn.maxSize)
+		if err != nil {
+			return err
+		} else if len(record) < replHeaderSize {
+			return errInvalidReplRecord
+		}
+
+		t := time.UnixMilli(int64(binary.LittleEndian.Uint64(record[1:replHeaderSize])))
+		r.received(t)
+
+		switch record[0] {
+		case replCommand:
+			rawCmd := record[replHeaderSize:]
+			cmd, err := protocol.ParseCommand(rawCmd)
+			if err != nil || !cmd.ModifiesCache() {
+				return errInvalidReplRecord
+			}
+
+			if key, ok := commandKey(cmd); ok && synced != nil {
+				synced[key] = true
+			}
+			s.write(t, sinceRan(cmd, t, time.Now(), expires), rawCmd)
+		case replSynced:
+			removed := s.removeUnsynced(synced)
+			synced = nil
+			r.setStatus(LINK_UP)
+			s.infof("synced with leader %s, removed %d stale keys\n", r.leader, removed)
+		case replHeartbeat:
+		default:
+			return errInvalidReplRecord
+		}
+	}
+}
+
+// Deletes the keys the leader didn't send during a sync, returning how many were deleted.
+func (s *Server) removeUnsynced(synced map[string]bool) int {
+	stale := make([]string, 0)
+	s.keys.Range(func(key string, _ []byte, _ time.Time) bool {
+		if !synced[key] {
+			stale = append(stale, key)
+		}
+		return true
+	})
+
+	for _, key := range stale {
+		s.write(time.Now(), command.NewDeleteCommand(key), command.DeleteCmdAsBytes(key))
+	}
+
+	return len(stale)
+}
@@ -0,0 +1,85 @@
+package dcache
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joaovictorsl/dcache/core"
+	"github.com/joaovictorsl/dcache/core/command"
+)
+
+// Retries fn until it returns true or a few seconds pass.
+func eventually(t *testing.T, what string, fn func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !fn(); time.Sleep(20 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+}
+
+func TestServerReplication(t *testing.T) {
+	leader, _ := startTestServer(t, WithAuthPassword("secret"))
+	defer leader.Shutdown(context.Background())
+	leaderConn := dialTestServer(t, leader)
+	defer leaderConn.Close()
+
+	exec := func(conn *testConn, cmd []byte, status byte) []byte {
+		t.Helper()
+		res, err := conn.exec(cmd)
+		if err != nil || res[0] != status {
+			t.Fatalf("%v = %q, %v, want status %d", cmd, res, err, status)
+		}
+		return res[1:]
+	}
+
+	exec(leaderConn, command.AuthCmdAsBytes("", "secret"), core.CMD_EXEC_SUCCEEDED)
+	exec(leaderConn, command.SetCmdAsBytes("Synced", []byte("Foo"), 60000), core.CMD_EXEC_SUCCEEDED)
+
+	follower, _ := startTestServer(t, WithReplicaOf(testAddr(leader)), WithReplicaAuth("", "secret"))
+	defer follower.Shutdown(context.Background())
+	followerConn := dialTestServer(t, follower)
+	defer followerConn.Close()
+
+	eventually(t, "follower to sync", func() bool {
+		return follower.Stats().LinkStatus == LINK_UP
+	})
+
+	exec(leaderConn, command.SetCmdAsBytes("Streamed", []byte("Bar"), 60000), core.CMD_EXEC_SUCCEEDED)
+	exec(leaderConn, command.DeleteCmdAsBytes("Synced"), core.CMD_EXEC_SUCCEEDED)
+
+	eventually(t, "follower to apply commands", func() bool {
+		res, err := followerConn.exec(command.GetCmdAsBytes("Streamed"))
+		return err == nil && string(res[1:]) == "Bar"
+	})
+	exec(followerConn, command.HasCmdAsBytes("Synced"), core.CMD_EXEC_FAILED)
+
+	if res := exec(followerConn, command.SetCmdAsBytes("Foo", []byte("Bar"), 60000), core.READ_ONLY_CODE); string(res) != core.READ_ONLY {
+		t.Errorf("SET on follower = %q, want %q", res, core.READ_ONLY)
+	}
+
+	stats := string(exec(followerConn, command.StatsCmdAsBytes(), core.CMD_EXEC_SUCCEEDED))
+	for _, line := range []string{"role=follower", "leader=" + testAddr(leader), "link_status=up", "keys=1"} {
+		if !strings.Contains(stats, line) {
+			t.Errorf("follower STATS = %q, want it to have %q", stats, line)
+		}
+	}
+
+	stats = string(exec(leaderConn, command.StatsCmdAsBytes(), core.CMD_EXEC_SUCCEEDED))
+	if !strings.Contains(stats, "role=leader") || !strings.Contains(stats, "state=online pending=0") {
+		t.Errorf("leader STATS = %q, want a leader with an online follower", stats)
+	}
+
+	// Promoted on reload without replica_of
+	cfg := follower.config()
+	cfg.ReplicaOf = ""
+	if err := follower.Reload(cfg); err != nil {
+		t.Fatalf("Reload returned error %q", err)
+	}
+	exec(followerConn, command.SetCmdAsBytes("Foo", []byte("Bar"), 60000), core.CMD_EXEC_SUCCEEDED)
+	if role := follower.Stats().Role; role != ROLE_LEADER {
+		t.Errorf("expected promoted follower to be a leader, got %s", role)
+	}
+}
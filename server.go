@@ -1,69 +1,568 @@
 package dcache
 
 import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/joaovictorsl/dcache/core"
+	"github.com/joaovictorsl/dcache/core/command"
+	"github.com/joaovictorsl/dcache/core/keyspace"
+	"github.com/joaovictorsl/dcache/core/persist"
 	"github.com/joaovictorsl/dcache/core/protocol"
 	"github.com/joaovictorsl/fooche"
 )
 
+// Returned by Start after the server is shut down.
+var ErrServerClosed = errors.New("server closed")
+
 type Server struct {
+	cfgMu sync.RWMutex
+	cfg   Config
+	// Users defined in cfg.ACLFile, guarded by cfgMu
+	aclUsers map[string]*aclUser
 	cache    fooche.ICache
-	buffSize uint
-	port     uint16
+	logger   *log.Logger
+	// Given through WithTLSConfig, takes precedence over the TLS files in cfg
+	tlsConfig *tls.Config
+
+	mu sync.Mutex
+	ln net.Listener
+	// Wraps cache once started, so its keys can be saved
+	keys *keyspace.Cache
+	// Open on Start when AOFFile is set
+	aof *persist.AOF
+	// Link to the leader when following one
+	replica *replica
+	// Set on Start when ClusterAddr is set
+	cluster *membership
+	// Set on Start when TLS is configured through files
+	tlsReloader  *tlsReloader
+	clients      map[uint64]*clientConn
+	nextClientID uint64
+	// Amount of connections per remote host
+	hostConns map[string]int
+	closing   bool
+	// Tracks running connection handlers so Shutdown can wait for them
+	handlers sync.WaitGroup
+
+	// Closed on Shutdown to stop background tasks such as periodic snapshots
+	done     chan struct{}
+	stopOnce sync.Once
+	// Tracks background tasks so Shutdown can wait for them
+	background sync.WaitGroup
+	// Serializes snapshot writes
+	saveMu sync.Mutex
+	// Set while the append-only log is compacted in the background
+	compacting atomic.Bool
+
+	// Serializes commands changing the cache so the log and followers see them in order
+	writeMu sync.Mutex
+	// Followers connected through SYNC, guarded by writeMu
+	followers map[*follower]bool
+	// Invalidation streams opened with TRACKING and the keys they are told about
+	tracking *tracker
 }
 
+// Creates a server listening on all interfaces.
+//
+// Kept for compatibility, see NewServerWithOptions for all available settings.
 func NewServer(port uint16, c fooche.ICache, maxValueLength uint) *Server {
-	return &Server{
-		cache:    c,
-		port:     port,
-		buffSize: 265 + maxValueLength,
+	return NewServerWithOptions(
+		WithPort(port),
+		WithCache(c),
+		WithMaxValueLength(maxValueLength),
+	)
+}
+
+// Creates a server starting from DefaultConfig and applying opts in order.
+//
+// If no cache is given through WithCache, one is created on Start based on the configuration.
+func NewServerWithOptions(opts ...Option) *Server {
+	s := &Server{
+		cfg:       DefaultConfig(),
+		logger:    log.Default(),
+		clients:   make(map[uint64]*clientConn),
+		hostConns: make(map[string]int),
+		done:      make(chan struct{}),
+		followers: make(map[*follower]bool),
+		tracking:  newTracker(),
+	}
+
+	for _, opt := range opts {
+		opt(s)
 	}
+
+	return s
 }
 
+// Listens and serves connections until the server is shut down, in which case ErrServerClosed is returned.
 func (s *Server) Start() (err error) {
-	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", s.port))
+	cfg := s.config()
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid config: %s", err)
+	}
+
+	users, err := loadACL(cfg.ACLFile)
+	if err != nil {
+		return err
+	}
+	s.cfgMu.Lock()
+	s.aclUsers = users
+	s.cfgMu.Unlock()
+
+	var evictions *keyspace.Evictions
+	if s.cache == nil {
+		evictions = keyspace.NewEvictions()
+		s.cache = cfg.newCache(evictions)
+	}
+	keys := keyspace.New(s.cache, cfg.CleanInterval != 0)
+	keys.WatchEvictions(evictions)
+	keys.OnRemove(s.tracking.invalidate)
+	s.mu.Lock()
+	s.keys = keys
+	s.mu.Unlock()
+	s.cache = keys
+
+	tlsConfig, err := s.listenerTLSConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	ln, err := net.Listen("tcp", cfg.Addr())
 	if err != nil {
 		return fmt.Errorf("listen error: %s", err)
 	}
+	if tlsConfig != nil {
+		ln = tls.NewListener(ln, tlsConfig)
+	}
+
+	// Loaded once listening so nothing is left open when the port is taken, connections wait until accepted
+	s.loadSnapshot(cfg)
+	aof, err := s.openAOF(cfg)
+	if err != nil {
+		ln.Close()
+		return err
+	}
+
+	s.mu.Lock()
+	if s.closing {
+		s.mu.Unlock()
+		ln.Close()
+		if aof != nil {
+			aof.Close()
+		}
+		return ErrServerClosed
+	}
+	s.ln = ln
+	s.aof = aof
+	if cfg.ClusterAddr != "" {
+		s.cluster = newMembership(cfg.ClusterAddr)
+	}
+	s.mu.Unlock()
+
+	if cfg.SnapshotFile != "" && cfg.SnapshotInterval != 0 {
+		s.background.Add(1)
+		go s.snapshotLoop(cfg.SnapshotInterval)
+	}
+	if cfg.AOFFile != "" {
+		s.background.Add(1)
+		go s.aofLoop()
+	}
+	s.follow(cfg.ReplicaOf)
+	if s.cluster != nil {
+		s.background.Add(1)
+		go s.gossipLoop(s.cluster)
+	}
+	s.background.Add(1)
+	go s.sweepLoop()
 
-	log.Printf("server starting on port [%d]\n", s.port)
+	s.infof("server starting on [%s]\n", ln.Addr())
+	if tlsConfig != nil {
+		s.infof("tls enabled\n")
+	}
 
 	for {
 		conn, err := ln.Accept()
 		if err != nil {
-			log.Printf("accept conn error: %s\n", err)
+			if s.isClosing() {
+				return ErrServerClosed
+			}
+
+			s.errorf("accept conn error: %s\n", err)
+			continue
+		}
+
+		cc, err := s.acquireConn(conn)
+		if err != nil {
+			s.infof("conn from %s rejected: %s\n", conn.RemoteAddr(), err)
+			go s.rejectConn(conn, err)
 			continue
 		}
 
-		go s.handleConn(conn)
+		go s.handleConn(cc)
+	}
+}
+
+// Stops accepting connections, closes idle ones and waits for in-flight commands to be answered.
+//
+// If ctx is done before all connections are closed, remaining connections are closed forcibly and ctx's error is returned.
+// When snapshots are enabled the cache is saved last, followed by closing the append-only log.
+func (s *Server) Shutdown(ctx context.Context) (err error) {
+	s.mu.Lock()
+	s.closing = true
+	if s.ln != nil {
+		s.ln.Close()
+	}
+	for _, cc := range s.clients {
+		// Unblocks connections waiting for a command, busy ones stop after answering
+		cc.SetReadDeadline(time.Now())
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.handlers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.mu.Lock()
+		for _, cc := range s.clients {
+			cc.Close()
+		}
+		s.mu.Unlock()
+		err = ctx.Err()
+	}
+
+	s.stopOnce.Do(func() {
+		close(s.done)
+		s.background.Wait()
+		s.saveOnShutdown()
+		s.closeAOF()
+	})
+
+	if err == nil {
+		s.infof("server shut down\n")
+	}
+	return err
+}
+
+// Applies a new configuration to the running server.
+//
+// Limits, timeouts and the log level take effect immediately, settings that require a restart are kept and logged.
+// TLS certificate files and the ACL file are read again, if that fails nothing is reloaded. A changed ReplicaOf
+// makes the server follow the new leader, or become a leader when cleared.
+func (s *Server) Reload(cfg Config) error {
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid config: %s", err)
+	}
+
+	users, err := loadACL(cfg.ACLFile)
+	if err != nil {
+		return err
+	}
+
+	if err := s.reloadTLS(); err != nil {
+		return err
+	}
+
+	s.cfgMu.Lock()
+	s.aclUsers = users
+	leaderChanged := s.cfg.ReplicaOf != cfg.ReplicaOf
+	changed := s.cfg.restartRequired(cfg)
+	cfg.BindAddr = s.cfg.BindAddr
+	cfg.Port = s.cfg.Port
+	if s.cfg.MaxMemory != 0 {
+		cfg.MaxValueLength = s.cfg.MaxValueLength
+	}
+	cfg.MaxMemory = s.cfg.MaxMemory
+	cfg.EvictionPolicy = s.cfg.EvictionPolicy
+	cfg.CleanInterval = s.cfg.CleanInterval
+	cfg.SnapshotFile = s.cfg.SnapshotFile
+	cfg.SnapshotInterval = s.cfg.SnapshotInterval
+	cfg.AOFFile = s.cfg.AOFFile
+	cfg.AOFFsync = s.cfg.AOFFsync
+	cfg.ClusterAddr = s.cfg.ClusterAddr
+	cfg.TLSCertFile = s.cfg.TLSCertFile
+	cfg.TLSKeyFile = s.cfg.TLSKeyFile
+	cfg.TLSClientCAFile = s.cfg.TLSClientCAFile
+	s.cfg = cfg
+	s.cfgMu.Unlock()
+
+	if leaderChanged {
+		s.follow(cfg.ReplicaOf)
+	}
+
+	if len(changed) != 0 {
+		s.infof("settings %v require a restart and were not reloaded\n", changed)
+	}
+	s.infof("config reloaded\n")
+	return nil
+}
+
+// Returns the address the server listens on, nil until Start is listening. With port 0 it has the port picked
+// by the system.
+func (s *Server) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ln == nil {
+		return nil
 	}
+	return s.ln.Addr()
 }
 
-func (s *Server) handleConn(conn net.Conn) {
-	defer conn.Close()
+// Returns a copy of the current configuration.
+func (s *Server) config() Config {
+	s.cfgMu.RLock()
+	defer s.cfgMu.RUnlock()
 
-	buf := make([]byte, s.buffSize)
+	return s.cfg
+}
+
+func (s *Server) isClosing() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.closing
+}
+
+// Sets the deadline for the next command, returns false if the server is shutting down.
+//
+// Done while holding s.mu so it can't override the deadline set by Shutdown.
+func (s *Server) prepareRead(conn net.Conn, timeout time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closing {
+		return false
+	}
+
+	var deadline time.Time
+	if timeout != 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	conn.SetReadDeadline(deadline)
+
+	return true
+}
+
+func (s *Server) handleConn(cc *clientConn) {
+	defer s.releaseConn(cc)
+
+	s.debugf("conn %d from %s opened\n", cc.id, cc.RemoteAddr())
+	r := bufio.NewReader(cc)
 	for {
-		n, err := conn.Read(buf)
-		if err != nil {
-			log.Printf("conn read error: %s", err)
-			break
+		cfg := s.config()
+		if !s.prepareRead(cc, cfg.ReadTimeout) {
+			return
+		}
+
+		rawCmd, err := protocol.ReadFrame(r, protocol.MaxCommandSize(cfg.MaxKeyLength, cfg.MaxValueLength))
+		var res []byte
+		switch err {
+		case nil:
+			cc.touch()
+			if res = s.handleCommand(cc, rawCmd); res == nil {
+				// The connection was handed over, e.g. to a follower
+				return
+			}
+		case protocol.ErrFrameTooLarge:
+			res = []byte{core.INVALID_COMMAND_CODE}
+		case io.EOF:
+			s.debugf("conn %d from %s closed\n", cc.id, cc.RemoteAddr())
+			return
+		default:
+			if !s.isClosing() {
+				s.errorf("conn read error: %s\n", err)
+			}
+			return
 		}
 
-		s.handleCommand(conn, buf[:n])
+		if cfg.WriteTimeout != 0 {
+			cc.SetWriteDeadline(time.Now().Add(cfg.WriteTimeout))
+		}
+
+		if err := protocol.WriteFrame(cc, res); err != nil {
+			s.errorf("conn write error: %s\n", err)
+			return
+		}
 	}
 }
 
-func (s *Server) handleCommand(conn net.Conn, rawCmd []byte) {
+func (s *Server) handleCommand(cc *clientConn, rawCmd []byte) []byte {
 	cmd, err := protocol.ParseCommand(rawCmd)
-	if err != nil {
-		conn.Write([]byte{core.INVALID_COMMAND_CODE})
-		return
+	if err != nil || !s.withinLimits(cmd) {
+		return []byte{core.INVALID_COMMAND_CODE}
+	}
+
+	// Commands about the server are answered here, the rest goes to the cache
+	switch c := cmd.(type) {
+	case *command.HelloCommand:
+		return s.hello(cc)
+	case *command.AuthCommand:
+		return s.auth(cc, c)
+	}
+
+	user := s.connUser(cc)
+	if user == nil {
+		return append([]byte{core.AUTH_REQUIRED_CODE}, core.AUTH_REQUIRED...)
+	} else if !user.allows(cmd) {
+		return append([]byte{core.NO_PERMISSION_CODE}, core.NO_PERMISSION...)
+	}
+
+	if cmd.ModifiesCache() && s.config().ReplicaOf != "" {
+		return append([]byte{core.READ_ONLY_CODE}, core.READ_ONLY...)
+	}
+
+	if owner, moved := s.movedTo(cmd); moved {
+		// Copies left behind by ring changes are removed before sending the client to the owner
+		if _, ok := cmd.(*command.DeleteCommand); ok {
+			s.execute(cmd, rawCmd)
+		}
+		return append([]byte{core.MOVED_CODE}, owner...)
+	}
+
+	switch c := cmd.(type) {
+	case *command.ClientListCommand:
+		return s.clientList()
+	case *command.ACLWhoamiCommand:
+		return s.aclWhoami(user)
+	case *command.ACLListCommand:
+		return s.aclList()
+	case *command.SaveCommand:
+		return s.saveCommand()
+	case *command.StatsCommand:
+		return s.statsCommand()
+	case *command.SyncCommand:
+		s.serveFollower(cc)
+		return nil
+	case *command.ScanCommand:
+		return s.scanCommand(c)
+	case *command.DumpCommand:
+		return s.dumpCommand(c)
+	case *command.GossipCommand:
+		return s.gossipCommand(c)
+	case *command.ClusterNodesCommand:
+		return s.clusterNodesCommand()
+	case *command.TrackingCommand:
+		return s.trackingCommand(cc, user, c)
+	case *command.ClientTrackingCommand:
+		return s.clientTrackingCommand(cc, c)
+	}
+
+	s.trackRead(cc, cmd)
+	return s.execute(cmd, rawCmd)
+}
+
+// Runs cmd against the cache, logging and replicating it when it changes the cache.
+func (s *Server) execute(cmd command.Command, rawCmd []byte) []byte {
+	if !cmd.ModifiesCache() {
+		return cmd.Execute(s.cache)
+	}
+
+	// An ADD that succeeds is logged and replicated as a SET, which replays the same whatever keys are stored
+	if c, ok := cmd.(*command.AddCommand); ok {
+		rawCmd = command.SetCmdAsBytes(c.Key, c.Value, uint32(c.TTL.Milliseconds()))
+	}
+
+	return s.write(time.Now(), cmd, rawCmd)
+}
+
+// Runs cmd, a command changing the cache, and if it succeeds appends rawCmd to the log and sends it to
+// followers with the time it ran at. Writes are serialized so the log and followers see them in the order
+// they changed the cache.
+func (s *Server) write(t time.Time, cmd command.Command, rawCmd []byte) []byte {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	res := cmd.Execute(s.cache)
+	if res[0] != core.CMD_EXEC_SUCCEEDED {
+		return res
 	}
 
-	conn.Write(cmd.Execute(s.cache))
+	if key, ok := commandKey(cmd); ok {
+		s.tracking.invalidate(key)
+	}
+
+	if s.aof != nil {
+		if err := s.aof.Append(t, rawCmd); err != nil {
+			s.errorf("aof: failed to append %s: %s\n", cmd, err)
+			return []byte{core.CMD_EXEC_FAILED}
+		}
+	}
+
+	s.replicate(t, rawCmd)
+	return res
+}
+
+// Checks the command key and value against the configured maximum lengths.
+func (s *Server) withinLimits(cmd command.Command) bool {
+	cfg := s.config()
+
+	switch c := cmd.(type) {
+	case *command.SetCommand:
+		if uint(len(c.Value)) > cfg.MaxValueLength {
+			return false
+		}
+	case *command.AddCommand:
+		if uint(len(c.Value)) > cfg.MaxValueLength {
+			return false
+		}
+	}
+
+	key, _ := commandKey(cmd)
+	return uint(len(key)) <= cfg.MaxKeyLength
+}
+
+// Returns the key the command acts on, false if it doesn't act on a key.
+func commandKey(cmd command.Command) (string, bool) {
+	switch c := cmd.(type) {
+	case *command.SetCommand:
+		return c.Key, true
+	case *command.AddCommand:
+		return c.Key, true
+	case *command.GetCommand:
+		return c.Key, true
+	case *command.HasCommand:
+		return c.Key, true
+	case *command.DeleteCommand:
+		return c.Key, true
+	case *command.DumpCommand:
+		return c.Key, true
+	}
+
+	return "", false
+}
+
+// How often the keys that expired or were evicted are forgotten
+const sweepInterval = time.Second
+
+// Forgets the keys that expired or were evicted every sweepInterval until the server is shut down, so
+// tracking streams hear about them without waiting for them to be read.
+func (s *Server) sweepLoop() {
+	defer s.background.Done()
+
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.keys.Sweep()
+		case <-s.done:
+			return
+		}
+	}
 }
@@ -0,0 +1,214 @@
+package dcache
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joaovictorsl/dcache/core"
+	"github.com/joaovictorsl/dcache/core/command"
+	"github.com/joaovictorsl/dcache/core/protocol"
+)
+
+// Starts a server with opts on a free port and waits until it's listening.
+func startTestServer(t *testing.T, opts ...Option) (*Server, chan error) {
+	s := NewServerWithOptions(append([]Option{WithBindAddr("127.0.0.1"), WithPort(0), WithLogLevel(LOG_NONE)}, opts...)...)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.Start()
+	}()
+
+	for deadline := time.Now().Add(5 * time.Second); s.Addr() == nil; time.Sleep(time.Millisecond) {
+		select {
+		case err := <-errs:
+			t.Fatalf("server did not start: %s", err)
+		default:
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("server did not start")
+		}
+	}
+
+	return s, errs
+}
+
+// Returns the address s listens on, once started by startTestServer.
+func testAddr(s *Server) string {
+	return s.Addr().String()
+}
+
+// Returns n distinct free ports, for servers that must know their address before starting.
+func freePorts(t *testing.T, n int) []uint16 {
+	ports := make([]uint16, n)
+	for i := range ports {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("failed to find a free port: %s", err)
+		}
+		// Kept open until every port is picked so none is picked twice
+		defer ln.Close()
+
+		ports[i] = uint16(ln.Addr().(*net.TCPAddr).Port)
+	}
+
+	return ports
+}
+
+type testConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func dialTestServer(t *testing.T, s *Server) *testConn {
+	conn, err := net.Dial("tcp", testAddr(s))
+	if err != nil {
+		t.Fatalf("failed to connect: %s", err)
+	}
+
+	return &testConn{Conn: conn, r: bufio.NewReader(conn)}
+}
+
+func (tc *testConn) exec(cmd []byte) ([]byte, error) {
+	if err := protocol.WriteFrame(tc, cmd); err != nil {
+		return nil, err
+	}
+
+	return protocol.ReadFrame(tc.r, 0)
+}
+
+func TestServerShutdown(t *testing.T) {
+	s, errs := startTestServer(t)
+	conn := dialTestServer(t, s)
+	defer conn.Close()
+
+	res, err := conn.exec(command.SetCmdAsBytes("Foo", []byte("Bar"), 1000))
+	if err != nil || res[0] != core.CMD_EXEC_SUCCEEDED {
+		t.Fatalf("SET = %v, %v, want success", res, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown returned error %q", err)
+	}
+
+	select {
+	case err := <-errs:
+		if err != ErrServerClosed {
+			t.Errorf("Start returned %v, want %v", err, ErrServerClosed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Start did not return after Shutdown")
+	}
+
+	if _, err := conn.exec(command.GetCmdAsBytes("Foo")); err == nil {
+		t.Error("expected idle connection to be closed after Shutdown")
+	}
+}
+
+func TestServerReload(t *testing.T) {
+	s, _ := startTestServer(t, WithMaxValueLength(10))
+	defer s.Shutdown(context.Background())
+
+	conn := dialTestServer(t, s)
+	defer conn.Close()
+
+	big := command.SetCmdAsBytes("Foo", make([]byte, 20), 1000)
+	res, err := conn.exec(big)
+	if err != nil || res[0] != core.INVALID_COMMAND_CODE {
+		t.Fatalf("SET with value over limit = %v, %v, want invalid command", res, err)
+	}
+
+	cfg := s.config()
+	cfg.MaxValueLength = 100
+	cfg.Port = 3102
+	if err := s.Reload(cfg); err != nil {
+		t.Fatalf("Reload returned error %q", err)
+	}
+
+	if s.config().Port != 0 {
+		t.Errorf("expected port to require a restart, got %d", s.config().Port)
+	}
+
+	res, err = conn.exec(big)
+	if err != nil || res[0] != core.CMD_EXEC_SUCCEEDED {
+		t.Errorf("SET after reload = %v, %v, want success", res, err)
+	}
+
+	cfg.LogLevel = "verbose"
+	if err := s.Reload(cfg); err == nil {
+		t.Error("Reload with invalid config should return error")
+	}
+}
+
+func TestServerReloadBounded(t *testing.T) {
+	s, _ := startTestServer(t, WithMaxValueLength(10), func(s *Server) {
+		s.cfg.MaxMemory = 1024
+	})
+	defer s.Shutdown(context.Background())
+
+	cfg := s.config()
+	cfg.MaxValueLength = 100
+	if err := s.Reload(cfg); err != nil {
+		t.Fatalf("Reload returned error %q", err)
+	}
+
+	// The cache's buckets only fit values of the length it started with
+	if s.config().MaxValueLength != 10 {
+		t.Errorf("expected max value length to require a restart, got %d", s.config().MaxValueLength)
+	}
+}
+
+func TestServerMaxConns(t *testing.T) {
+	s, _ := startTestServer(t, WithMaxConns(3), WithMaxConnsPerClient(2))
+	defer s.Shutdown(context.Background())
+
+	// startTestServer's probe connection may still be registered
+	for i := 0; i < 50 && len(s.Clients()) != 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	first := dialTestServer(t, s)
+	defer first.Close()
+	second := dialTestServer(t, s)
+	defer second.Close()
+
+	for _, conn := range []*testConn{first, second} {
+		if res, err := conn.exec(command.HasCmdAsBytes("Foo")); err != nil || res[0] != core.CMD_EXEC_FAILED {
+			t.Fatalf("HAS = %v, %v, want key not found", res, err)
+		}
+	}
+
+	rejected := dialTestServer(t, s)
+	defer rejected.Close()
+
+	res, err := protocol.ReadFrame(rejected.r, 0)
+	if err != nil || res[0] != core.MAX_CONNS_REACHED_CODE {
+		t.Fatalf("expected third conn from same host to be rejected, got %v, %v", res, err)
+	}
+
+	clients := s.Clients()
+	if len(clients) != 2 {
+		t.Fatalf("expected 2 clients, got %d", len(clients))
+	}
+
+	for _, c := range clients {
+		if c.HostConns != 2 || c.Commands != 1 {
+			t.Errorf("expected client with 2 host conns and 1 command, got %+v", c)
+		}
+	}
+
+	res, err = first.exec(command.ClientListCmdAsBytes())
+	if err != nil || res[0] != core.CMD_EXEC_SUCCEEDED {
+		t.Fatalf("CLIENT LIST = %v, %v, want success", res, err)
+	}
+
+	lines := strings.Split(string(res[1:]), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], fmt.Sprintf("id=%d addr=%s host_conns=2", clients[0].ID, clients[0].Addr)) {
+		t.Errorf("unexpected CLIENT LIST output %q", res[1:])
+	}
+}
@@ -0,0 +1,131 @@
+package dcache
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/joaovictorsl/dcache/core"
+	"github.com/joaovictorsl/dcache/core/persist"
+)
+
+var errSnapshotsDisabled = errors.New("snapshots are disabled, set snapshot_file to enable them")
+
+// Loads the snapshot file into the cache, skipping keys that expired since it was written.
+//
+// A missing or corrupted snapshot is logged and the server starts empty.
+func (s *Server) loadSnapshot(cfg Config) {
+	if cfg.SnapshotFile == "" {
+		return
+	}
+
+	now := time.Now()
+	loaded, expired := 0, 0
+	createdAt, err := persist.LoadSnapshotFile(cfg.SnapshotFile, func(e persist.Entry) error {
+		var ttl time.Duration
+		if !e.ExpiresAt.IsZero() {
+			if ttl = e.ExpiresAt.Sub(now); ttl <= 0 {
+				expired++
+				return nil
+			}
+		}
+
+		if err := s.cache.Set(e.Key, e.Value, ttl); err != nil {
+			return fmt.Errorf("key %s: %s", e.Key, err)
+		}
+		loaded++
+		return nil
+	})
+
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		s.infof("no snapshot found at %s, starting empty\n", cfg.SnapshotFile)
+	case err != nil:
+		s.errorf("failed to load snapshot: %s\n", err)
+	default:
+		s.infof("loaded %d keys from snapshot written at %s, skipped %d expired\n", loaded, createdAt.Format(time.RFC3339), expired)
+	}
+}
+
+// Writes a snapshot of the cache to the snapshot file, returning how many keys were written.
+//
+// With the append-only log enabled, the commands it has are replaced by the snapshot.
+func (s *Server) save() (int, error) {
+	path := s.config().SnapshotFile
+	if path == "" {
+		return 0, errSnapshotsDisabled
+	}
+
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+
+	compacted, err := s.rotateAOF()
+	if err != nil {
+		return 0, err
+	}
+
+	start := time.Now()
+	saved := 0
+	err = persist.SaveSnapshotFile(path, start, func(write func(persist.Entry) error) (err error) {
+		s.keys.Range(func(key string, value []byte, expiresAt time.Time) bool {
+			if err = write(persist.Entry{Key: key, Value: value, ExpiresAt: expiresAt}); err != nil {
+				return false
+			}
+			saved++
+			return true
+		})
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	compacted()
+
+	s.infof("saved %d keys to %s in %s\n", saved, path, time.Since(start).Round(time.Millisecond))
+	return saved, nil
+}
+
+// Answers SAVE once the snapshot is written, with the amount of saved keys.
+func (s *Server) saveCommand() []byte {
+	saved, err := s.save()
+	if err != nil {
+		s.errorf("save failed: %s\n", err)
+		return append([]byte{core.CMD_EXEC_FAILED}, err.Error()...)
+	}
+
+	return append([]byte{core.CMD_EXEC_SUCCEEDED}, fmt.Sprintf("saved %d keys", saved)...)
+}
+
+// Saves the cache every interval until the server is shut down.
+func (s *Server) snapshotLoop(interval time.Duration) {
+	defer s.background.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := s.save(); err != nil {
+				s.errorf("periodic save failed: %s\n", err)
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *Server) saveOnShutdown() {
+	s.mu.Lock()
+	started := s.keys != nil
+	s.mu.Unlock()
+
+	if !started || s.config().SnapshotFile == "" {
+		return
+	}
+
+	if _, err := s.save(); err != nil {
+		s.errorf("save on shutdown failed: %s\n", err)
+	}
+}
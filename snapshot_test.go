@@ -0,0 +1,67 @@
+package dcache
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/joaovictorsl/dcache/core"
+	"github.com/joaovictorsl/dcache/core/command"
+)
+
+func TestServerSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dcache.snap")
+
+	s, _ := startTestServer(t, WithSnapshotFile(path, 0))
+	conn := dialTestServer(t, s)
+
+	shortExpires := time.Now().Add(50 * time.Millisecond)
+	for _, cmd := range [][]byte{
+		command.SetCmdAsBytes("Foo", []byte("Bar"), 60000),
+		command.SetCmdAsBytes("Short", []byte("lived"), 50),
+		command.SetCmdAsBytes("Deleted", []byte("value"), 60000),
+		command.DeleteCmdAsBytes("Deleted"),
+	} {
+		if res, err := conn.exec(cmd); err != nil || res[0] != core.CMD_EXEC_SUCCEEDED {
+			t.Fatalf("%v = %q, %v, want success", cmd, res, err)
+		}
+	}
+
+	res, err := conn.exec(command.SaveCmdAsBytes())
+	if err != nil || string(res) != "\x04saved 2 keys" {
+		t.Fatalf("SAVE = %q, %v, want 2 saved keys", res, err)
+	}
+
+	// Written after SAVE, saved on shutdown
+	if res, err := conn.exec(command.SetCmdAsBytes("Baz", []byte("Qux"), 60000)); err != nil || res[0] != core.CMD_EXEC_SUCCEEDED {
+		t.Fatalf("SET = %q, %v, want success", res, err)
+	}
+	conn.Close()
+
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown returned error %q", err)
+	}
+
+	// Short expires while the server is down
+	time.Sleep(time.Until(shortExpires))
+
+	restarted, _ := startTestServer(t, WithSnapshotFile(path, 0))
+	defer restarted.Shutdown(context.Background())
+	conn = dialTestServer(t, restarted)
+	defer conn.Close()
+
+	expected := map[string]string{"Foo": "Bar", "Baz": "Qux", "Short": "", "Deleted": ""}
+	for key, value := range expected {
+		res, err := conn.exec(command.GetCmdAsBytes(key))
+		if err != nil {
+			t.Fatalf("GET %s returned error %q", key, err)
+		}
+
+		if value == "" && res[0] != core.CMD_EXEC_FAILED {
+			t.Errorf("expected %s not to be loaded, got %q", key, res)
+		} else if value != "" && string(res[1:]) != value {
+			t.Errorf("GET %s = %q, want %q", key, res, value)
+		}
+	}
+}
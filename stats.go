@@ -0,0 +1,121 @@
+package dcache
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/joaovictorsl/dcache/core"
+)
+
+// State of the server as returned by Server.Stats.
+type Stats struct {
+	// Stored keys, including the ones not yet known to be evicted or expired
+	Keys    int
+	Clients int
+	// Open invalidation streams and keys read by connections tracking their reads
+	TrackingStreams int
+	TrackedKeys     int
+
+	// ROLE_LEADER or ROLE_FOLLOWER
+	Role string
+	// Address of the leader, followers only
+	Leader string
+	// Status of the link to the leader, followers only
+	LinkStatus string
+	// Time between the leader sending the last command or heartbeat and the follower receiving it
+	Lag time.Duration
+	// Time since the follower last heard from its leader
+	LastIO time.Duration
+
+	// Followers replicating this server, followers can have their own
+	Followers []FollowerStats
+}
+
+type FollowerStats struct {
+	Addr string
+	// Whether every key was sent and the follower is receiving commands as they run
+	Synced bool
+	// Commands waiting to be sent
+	Pending int
+}
+
+// Returns the amount of keys and clients and the replication state of the server.
+func (s *Server) Stats() Stats {
+	s.mu.Lock()
+	stats := Stats{Clients: len(s.clients), Role: ROLE_LEADER}
+	if s.keys != nil {
+		stats.Keys = s.keys.Len()
+	}
+	r := s.replica
+	s.mu.Unlock()
+	stats.TrackingStreams, stats.TrackedKeys = s.tracking.stats()
+
+	if r != nil {
+		r.mu.Lock()
+		stats.Role = ROLE_FOLLOWER
+		stats.Leader = r.leader
+		stats.LinkStatus = r.status
+		stats.Lag = r.lag
+		if !r.lastIO.IsZero() {
+			stats.LastIO = time.Since(r.lastIO)
+		}
+		r.mu.Unlock()
+	}
+
+	s.writeMu.Lock()
+	stats.Followers = make([]FollowerStats, 0, len(s.followers))
+	for f := range s.followers {
+		stats.Followers = append(stats.Followers, FollowerStats{
+			Addr:    f.cc.RemoteAddr().String(),
+			Synced:  f.synced.Load(),
+			Pending: len(f.records),
+		})
+	}
+	s.writeMu.Unlock()
+
+	sort.Slice(stats.Followers, func(i, j int) bool {
+		return stats.Followers[i].Addr < stats.Followers[j].Addr
+	})
+
+	return stats
+}
+
+// Answers STATS with a line per stat followed by a line per follower, e.g.
+//
+//	keys=1500
+//	clients=3
+//	tracking_streams=1
+//	tracked_keys=200
+//	role=leader
+//	follower=10.0.0.2:52000 state=online pending=0
+func (s *Server) statsCommand() []byte {
+	stats := s.Stats()
+	lines := []string{
+		fmt.Sprintf("keys=%d", stats.Keys),
+		fmt.Sprintf("clients=%d", stats.Clients),
+		fmt.Sprintf("tracking_streams=%d", stats.TrackingStreams),
+		fmt.Sprintf("tracked_keys=%d", stats.TrackedKeys),
+		fmt.Sprintf("role=%s", stats.Role),
+	}
+
+	if stats.Role == ROLE_FOLLOWER {
+		lines = append(lines,
+			fmt.Sprintf("leader=%s", stats.Leader),
+			fmt.Sprintf("link_status=%s", stats.LinkStatus),
+			fmt.Sprintf("lag_ms=%d", stats.Lag.Milliseconds()),
+			fmt.Sprintf("last_io_ms=%d", stats.LastIO.Milliseconds()),
+		)
+	}
+
+	for _, f := range stats.Followers {
+		state := "syncing"
+		if f.Synced {
+			state = "online"
+		}
+		lines = append(lines, fmt.Sprintf("follower=%s state=%s pending=%d", f.Addr, state, f.Pending))
+	}
+
+	return append([]byte{core.CMD_EXEC_SUCCEEDED}, strings.Join(lines, "\n")...)
+}
@@ -0,0 +1,153 @@
+package dcache
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+)
+
+// Serves the certificate and client CAs loaded from files, so they can be replaced without restarting
+// the server by calling load again.
+type tlsReloader struct {
+	certFile     string
+	keyFile      string
+	clientCAFile string
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+}
+
+func newTLSReloader(certFile, keyFile, clientCAFile string) (*tlsReloader, error) {
+	r := &tlsReloader{
+		certFile:     certFile,
+		keyFile:      keyFile,
+		clientCAFile: clientCAFile,
+	}
+
+	return r, r.load()
+}
+
+// Reads the certificate, key and client CAs files. On failure the previously loaded ones are kept.
+func (r *tlsReloader) load() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load tls certificate: %s", err)
+	}
+
+	var clientCAs *x509.CertPool
+	if r.clientCAFile != "" {
+		pem, err := os.ReadFile(r.clientCAFile)
+		if err != nil {
+			return fmt.Errorf("load tls client CAs: %s", err)
+		}
+
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("load tls client CAs: no certificates found in %s", r.clientCAFile)
+		}
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.clientCAs = clientCAs
+	r.mu.Unlock()
+
+	return nil
+}
+
+// Builds a tls.Config that picks the latest loaded certificate and client CAs on every handshake.
+//
+// Client certificates are required and verified when a client CAs file is set.
+func (r *tlsReloader) tlsConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+
+			cfg := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*r.cert},
+			}
+
+			if r.clientCAs != nil {
+				cfg.ClientCAs = r.clientCAs
+				cfg.ClientAuth = tls.RequireAndVerifyClientCert
+			}
+
+			return cfg, nil
+		},
+	}
+}
+
+// Returns the TLS configuration used by the listener, nil if TLS is disabled.
+func (s *Server) listenerTLSConfig(cfg Config) (*tls.Config, error) {
+	if s.tlsConfig != nil {
+		return s.tlsConfig, nil
+	} else if !cfg.tlsEnabled() {
+		return nil, nil
+	}
+
+	r, err := newTLSReloader(cfg.TLSCertFile, cfg.TLSKeyFile, cfg.TLSClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.tlsReloader = r
+	s.mu.Unlock()
+
+	return r.tlsConfig(), nil
+}
+
+// Reads the TLS files again, new connections use the new certificate while open ones are not affected.
+func (s *Server) reloadTLS() error {
+	s.mu.Lock()
+	r := s.tlsReloader
+	s.mu.Unlock()
+
+	if r == nil {
+		return nil
+	}
+
+	if err := r.load(); err != nil {
+		return fmt.Errorf("tls not reloaded: %s", err)
+	}
+
+	return nil
+}
+
+// Returns the TLS configuration used to connect to the leader, nil if the link is not encrypted.
+//
+// The leader's certificate is verified against ReplicaTLSCAFile, and the follower presents its own
+// certificate when one is configured, so leaders requiring client certificates accept it.
+func replicaTLSConfig(cfg Config) (*tls.Config, error) {
+	if cfg.ReplicaTLSCAFile == "" {
+		return nil, nil
+	}
+
+	pem, err := os.ReadFile(cfg.ReplicaTLSCAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	host, _, _ := net.SplitHostPort(cfg.ReplicaOf)
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12, ServerName: host, RootCAs: x509.NewCertPool()}
+	if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", cfg.ReplicaTLSCAFile)
+	}
+
+	if cfg.tlsEnabled() {
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,188 @@
+package dcache
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/joaovictorsl/dcache/client"
+	"github.com/joaovictorsl/dcache/core/command"
+	"github.com/joaovictorsl/dcache/core/protocol"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// Creates a certificate for 127.0.0.1 signed by parent, or self-signed if parent is nil.
+func newTestCert(t *testing.T, serial int64, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "dcache test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testCert{cert: cert, key: key}
+}
+
+// Writes the certificate and key in PEM format to name.crt and name.key in dir.
+func (tc *testCert) write(t *testing.T, dir, name string) (certFile, keyFile string) {
+	keyDER, err := x509.MarshalECPrivateKey(tc.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile = filepath.Join(dir, name+".crt")
+	keyFile = filepath.Join(dir, name+".key")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tc.cert.Raw})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(certFile, certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return certFile, keyFile
+}
+
+func TestServerMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, 1, nil)
+	caFile, _ := ca.write(t, dir, "ca")
+	serverCert, serverKey := newTestCert(t, 2, ca).write(t, dir, "server")
+	clientCert, clientKey := newTestCert(t, 3, ca).write(t, dir, "client")
+
+	s, _ := startTestServer(t, WithTLSFiles(serverCert, serverKey, caFile))
+	defer s.Shutdown(context.Background())
+
+	tlsConfig, err := client.NewTLSConfig(caFile, clientCert, clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := client.NewWithOptions(client.WithNodes(testAddr(s)), client.WithTLS(tlsConfig))
+	defer c.End()
+	if err := c.Connect(0, 0); err != nil {
+		t.Fatalf("Connect returned error %q", err)
+	}
+
+	if err := c.Set("Foo", []byte("Bar"), 10000); err != nil {
+		t.Fatalf("Set returned error %q", err)
+	}
+	if v, ok, err := c.Get("Foo"); err != nil || !ok || string(v) != "Bar" {
+		t.Errorf("Get = %q, %v, %v, want Bar", v, ok, err)
+	}
+
+	// Without a client certificate the server closes the connection after the handshake
+	noCert, err := client.NewTLSConfig(caFile, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := tls.Dial("tcp", testAddr(s), noCert)
+	if err == nil {
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(time.Second))
+		err = protocol.WriteFrame(conn, command.GetCmdAsBytes("Foo"))
+		if err == nil {
+			_, err = protocol.ReadFrame(conn, 0)
+		}
+	}
+	if err == nil {
+		t.Error("expected connection without client certificate to fail")
+	}
+
+	plain := dialTestServer(t, s)
+	defer plain.Close()
+	plain.SetDeadline(time.Now().Add(time.Second))
+	if _, err := plain.exec(command.GetCmdAsBytes("Foo")); err == nil {
+		t.Error("expected connection without TLS to fail")
+	}
+}
+
+func TestServerTLSReload(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, 1, nil)
+	caFile, _ := ca.write(t, dir, "ca")
+	certFile, keyFile := newTestCert(t, 2, ca).write(t, dir, "server")
+
+	s, _ := startTestServer(t, WithTLSFiles(certFile, keyFile, ""))
+	defer s.Shutdown(context.Background())
+
+	tlsConfig, err := client.NewTLSConfig(caFile, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	servedSerial := func() int64 {
+		conn, err := tls.Dial("tcp", testAddr(s), tlsConfig)
+		if err != nil {
+			t.Fatalf("failed to connect: %s", err)
+		}
+		defer conn.Close()
+
+		return conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64()
+	}
+
+	if serial := servedSerial(); serial != 2 {
+		t.Fatalf("expected certificate with serial 2, got %d", serial)
+	}
+
+	newTestCert(t, 4, ca).write(t, dir, "server")
+	if err := s.Reload(s.config()); err != nil {
+		t.Fatalf("Reload returned error %q", err)
+	}
+
+	if serial := servedSerial(); serial != 4 {
+		t.Errorf("expected reloaded certificate with serial 4, got %d", serial)
+	}
+
+	os.WriteFile(keyFile, []byte("not a key"), 0600)
+	if err := s.Reload(s.config()); err == nil {
+		t.Error("Reload with invalid key should return error")
+	}
+
+	if serial := servedSerial(); serial != 4 {
+		t.Errorf("expected previous certificate to be kept, got serial %d", serial)
+	}
+}
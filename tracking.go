@@ -0,0 +1,285 @@
+package dcache
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/joaovictorsl/dcache/core"
+	"github.com/joaovictorsl/dcache/core/command"
+	"github.com/joaovictorsl/dcache/core/protocol"
+)
+
+const (
+	// Invalidated keys queued per stream, a stream falling further behind is closed
+	trackingBacklog = 16 * 1024
+	// Keys sent in a single invalidation message at most
+	trackingBatch = 256
+)
+
+// A connection that sent TRACKING, receiving the keys to invalidate.
+type trackingStream struct {
+	id uint64
+	cc *clientConn
+	// User that opened the stream, only its connections can track reads with it
+	username string
+	// Every key starting with one of them is sent, when empty the keys read by connections tracking with
+	// the stream are sent instead
+	prefixes []string
+	keys     chan string
+	// Closed once the stream is disconnected
+	gone     chan struct{}
+	goneOnce sync.Once
+}
+
+func (ts *trackingStream) disconnect() {
+	ts.goneOnce.Do(func() {
+		close(ts.gone)
+		ts.cc.Close()
+	})
+}
+
+// Queues key, disconnecting the stream if it's too far behind. Clients find out from the disconnection
+// that they missed invalidations.
+func (ts *trackingStream) send(key string) {
+	select {
+	case ts.keys <- key:
+	default:
+		ts.disconnect()
+	}
+}
+
+func (ts *trackingStream) matches(key string) bool {
+	for _, prefix := range ts.prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Remembers which streams to tell about changes to each key. Like an invalidation, reading a key again
+// is needed to hear about it again.
+type tracker struct {
+	mu      sync.Mutex
+	streams map[uint64]*trackingStream
+	nextID  uint64
+	// Streams of the connections that read each key since it last changed
+	readers map[string]map[uint64]bool
+	// Streams sending keys by prefix
+	broadcasting map[uint64]*trackingStream
+}
+
+func newTracker() *tracker {
+	return &tracker{
+		streams:      make(map[uint64]*trackingStream),
+		readers:      make(map[string]map[uint64]bool),
+		broadcasting: make(map[uint64]*trackingStream),
+	}
+}
+
+func (t *tracker) open(cc *clientConn, prefixes []string) *trackingStream {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.nextID++
+	ts := &trackingStream{
+		id:       t.nextID,
+		cc:       cc,
+		username: cc.username,
+		prefixes: prefixes,
+		keys:     make(chan string, trackingBacklog),
+		gone:     make(chan struct{}),
+	}
+	t.streams[ts.id] = ts
+	if len(prefixes) != 0 {
+		t.broadcasting[ts.id] = ts
+	}
+
+	return ts
+}
+
+// Unregisters ts and forgets the reads tracked for it.
+func (t *tracker) close(ts *trackingStream) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.streams, ts.id)
+	delete(t.broadcasting, ts.id)
+	for key, streams := range t.readers {
+		if delete(streams, ts.id); len(streams) == 0 {
+			delete(t.readers, key)
+		}
+	}
+}
+
+// Whether the stream id exists and was opened by username.
+func (t *tracker) owns(id uint64, username string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ts, ok := t.streams[id]
+	return ok && ts.username == username
+}
+
+// Records that a connection tracking with stream id is reading key. When maxKeys are tracked already
+// another key is invalidated to make room.
+func (t *tracker) read(key string, id uint64, maxKeys uint) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.streams[id]; !ok || maxKeys == 0 {
+		return
+	}
+
+	streams, ok := t.readers[key]
+	if !ok {
+		for evicted := range t.readers {
+			if uint(len(t.readers)) < maxKeys {
+				break
+			}
+			t.invalidateLocked(evicted)
+		}
+
+		streams = make(map[uint64]bool)
+		t.readers[key] = streams
+	}
+	streams[id] = true
+}
+
+// Tells the streams of the connections that read key, and the ones sending its prefix, that it changed.
+func (t *tracker) invalidate(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.invalidateLocked(key)
+}
+
+func (t *tracker) invalidateLocked(key string) {
+	for id := range t.readers[key] {
+		if ts, ok := t.streams[id]; ok {
+			ts.send(key)
+		}
+	}
+	delete(t.readers, key)
+
+	for _, ts := range t.broadcasting {
+		if ts.matches(key) {
+			ts.send(key)
+		}
+	}
+}
+
+// Amount of open streams and of keys read by connections tracking with them.
+func (t *tracker) stats() (streams, keys int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return len(t.streams), len(t.readers)
+}
+
+// Serves a connection that sent TRACKING until it disconnects or the server shuts down, sending the keys
+// to invalidate as they change. Returns the response when the stream can't be opened, nil otherwise.
+func (s *Server) trackingCommand(cc *clientConn, user *aclUser, c *command.TrackingCommand) []byte {
+	if s.config().TrackingMaxKeys == 0 {
+		return append([]byte{core.CMD_EXEC_FAILED}, "tracking disabled"...)
+	}
+	for _, prefix := range c.Prefixes {
+		if !user.allowsPrefix(prefix) {
+			return append([]byte{core.NO_PERMISSION_CODE}, core.NO_PERMISSION...)
+		}
+	}
+
+	ts := s.tracking.open(cc, c.Prefixes)
+	defer func() {
+		s.tracking.close(ts)
+		ts.disconnect()
+	}()
+
+	// Clients send nothing after TRACKING, reading only tells when they disconnect or the server shuts down
+	if !s.prepareRead(cc, 0) {
+		return nil
+	}
+	go func() {
+		io.Copy(io.Discard, cc)
+		ts.disconnect()
+	}()
+
+	s.debugf("conn %d from %s streams invalidations as %d\n", cc.id, cc.RemoteAddr(), ts.id)
+	w := bufio.NewWriter(cc)
+	res := binary.LittleEndian.AppendUint64([]byte{core.CMD_EXEC_SUCCEEDED}, ts.id)
+	err := protocol.WriteFrame(w, res)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = streamInvalidations(ts, w)
+	}
+
+	select {
+	case <-ts.gone:
+		s.debugf("invalidation stream %d closed\n", ts.id)
+	default:
+		s.errorf("invalidation stream %d failed: %s\n", ts.id, err)
+	}
+	return nil
+}
+
+// Sends the stream's queued keys as they come, in messages of up to trackingBatch keys.
+func streamInvalidations(ts *trackingStream, w *bufio.Writer) error {
+	keys := make([]string, 0, trackingBatch)
+	for {
+		select {
+		case key := <-ts.keys:
+			keys = append(keys[:0], key)
+			// Batches the keys queued meanwhile, only this goroutine receives so it doesn't block
+			for len(keys) < trackingBatch && len(ts.keys) != 0 {
+				keys = append(keys, <-ts.keys)
+			}
+
+			err := protocol.WriteFrame(w, command.KeysAsBytes(keys))
+			// Flushes once caught up, so messages are batched while keys keep changing
+			if err == nil && len(ts.keys) == 0 {
+				err = w.Flush()
+			}
+			if err != nil {
+				return err
+			}
+		case <-ts.gone:
+			return nil
+		}
+	}
+}
+
+// Makes the connection's reads tracked for the stream given, or stops tracking them.
+func (s *Server) clientTrackingCommand(cc *clientConn, c *command.ClientTrackingCommand) []byte {
+	if c.StreamID != 0 && !s.tracking.owns(c.StreamID, cc.username) {
+		return append([]byte{core.CMD_EXEC_FAILED}, "unknown tracking stream"...)
+	}
+
+	cc.trackingID = c.StreamID
+	return []byte{core.CMD_EXEC_SUCCEEDED}
+}
+
+// Records the key cmd reads when the connection tracks its reads. Done before running the command, so
+// a write changing the key afterwards is sent to the stream.
+func (s *Server) trackRead(cc *clientConn, cmd command.Command) {
+	if cc.trackingID == 0 {
+		return
+	}
+
+	var key string
+	switch c := cmd.(type) {
+	case *command.GetCommand:
+		key = c.Key
+	case *command.HasCommand:
+		key = c.Key
+	default:
+		return
+	}
+
+	s.tracking.read(key, cc.trackingID, s.config().TrackingMaxKeys)
+}
@@ -0,0 +1,123 @@
+package dcache
+
+import (
+	"context"
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joaovictorsl/dcache/core"
+	"github.com/joaovictorsl/dcache/core/command"
+	"github.com/joaovictorsl/dcache/core/protocol"
+)
+
+func TestServerTracking(t *testing.T) {
+	s, _ := startTestServer(t, WithTrackingMaxKeys(2))
+	defer s.Shutdown(context.Background())
+
+	exec := func(conn *testConn, cmd []byte, status byte) []byte {
+		t.Helper()
+		res, err := conn.exec(cmd)
+		if err != nil || res[0] != status {
+			t.Fatalf("%v = %q, %v, want status %d", cmd, res, err, status)
+		}
+		return res[1:]
+	}
+
+	// Returns the keys of the next invalidation message
+	invalidated := func(stream *testConn) string {
+		t.Helper()
+		stream.SetReadDeadline(time.Now().Add(3 * time.Second))
+		msg, err := protocol.ReadFrame(stream.r, 0)
+		if err != nil {
+			t.Fatalf("failed to read invalidation: %s", err)
+		}
+		keys, err := protocol.ParseKeys(msg)
+		if err != nil {
+			t.Fatalf("invalid invalidation %q: %s", msg, err)
+		}
+		return strings.Join(keys, ",")
+	}
+
+	stream := dialTestServer(t, s)
+	defer stream.Close()
+	id := binary.LittleEndian.Uint64(exec(stream, command.TrackingCmdAsBytes(), core.CMD_EXEC_SUCCEEDED))
+
+	reader := dialTestServer(t, s)
+	defer reader.Close()
+	writer := dialTestServer(t, s)
+	defer writer.Close()
+
+	exec(reader, command.ClientTrackingCmdAsBytes(id+1), core.CMD_EXEC_FAILED)
+	exec(reader, command.ClientTrackingCmdAsBytes(id), core.CMD_EXEC_SUCCEEDED)
+
+	t.Run("should send keys read once they are written", func(t *testing.T) {
+		exec(reader, command.GetCmdAsBytes("Foo"), core.CMD_EXEC_FAILED)
+		exec(writer, command.SetCmdAsBytes("Foo", []byte("Bar"), 60000), core.CMD_EXEC_SUCCEEDED)
+		if keys := invalidated(stream); keys != "Foo" {
+			t.Errorf("invalidated %q, want Foo", keys)
+		}
+
+		// Foo must be read again to be sent again
+		exec(writer, command.DeleteCmdAsBytes("Foo"), core.CMD_EXEC_SUCCEEDED)
+		exec(writer, command.SetCmdAsBytes("Bar", []byte("Bar"), 60000), core.CMD_EXEC_SUCCEEDED)
+		exec(reader, command.HasCmdAsBytes("Bar"), core.CMD_EXEC_SUCCEEDED)
+		exec(writer, command.DeleteCmdAsBytes("Bar"), core.CMD_EXEC_SUCCEEDED)
+		if keys := invalidated(stream); keys != "Bar" {
+			t.Errorf("invalidated %q, want Bar", keys)
+		}
+	})
+
+	t.Run("should send keys read once they expire", func(t *testing.T) {
+		exec(writer, command.SetCmdAsBytes("Expiring", []byte("Bar"), 50), core.CMD_EXEC_SUCCEEDED)
+		exec(reader, command.GetCmdAsBytes("Expiring"), core.CMD_EXEC_SUCCEEDED)
+		if keys := invalidated(stream); keys != "Expiring" {
+			t.Errorf("invalidated %q, want Expiring", keys)
+		}
+	})
+
+	t.Run("should send every key starting with the prefixes of broadcasting streams", func(t *testing.T) {
+		broadcast := dialTestServer(t, s)
+		defer broadcast.Close()
+		exec(broadcast, command.TrackingCmdAsBytes("orders:"), core.CMD_EXEC_SUCCEEDED)
+
+		exec(writer, command.SetCmdAsBytes("carts:1", []byte("Bar"), 60000), core.CMD_EXEC_SUCCEEDED)
+		exec(writer, command.SetCmdAsBytes("orders:1", []byte("Bar"), 60000), core.CMD_EXEC_SUCCEEDED)
+		if keys := invalidated(broadcast); keys != "orders:1" {
+			t.Errorf("invalidated %q, want orders:1", keys)
+		}
+
+		if stats := s.Stats(); stats.TrackingStreams != 2 {
+			t.Errorf("tracking streams = %d, want 2", stats.TrackingStreams)
+		}
+	})
+
+	t.Run("should send a key to make room once the maximum is reached", func(t *testing.T) {
+		eventually(t, "tracked keys to be sent", func() bool {
+			return s.Stats().TrackedKeys == 0
+		})
+
+		for _, key := range []string{"A", "B", "C"} {
+			exec(reader, command.GetCmdAsBytes(key), core.CMD_EXEC_FAILED)
+		}
+		if keys := invalidated(stream); keys != "A" && keys != "B" {
+			t.Errorf("invalidated %q, want A or B", keys)
+		}
+		if stats := s.Stats(); stats.TrackedKeys != 2 {
+			t.Errorf("tracked keys = %d, want 2", stats.TrackedKeys)
+		}
+	})
+
+	t.Run("should forget the reads of closed streams", func(t *testing.T) {
+		stream.Close()
+		eventually(t, "stream to be closed", func() bool {
+			stats := s.Stats()
+			return stats.TrackingStreams == 0 && stats.TrackedKeys == 0
+		})
+		exec(reader, command.GetCmdAsBytes("A"), core.CMD_EXEC_FAILED)
+		if stats := s.Stats(); stats.TrackedKeys != 0 {
+			t.Errorf("tracked keys = %d, want 0", stats.TrackedKeys)
+		}
+	})
+}